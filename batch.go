@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// HardwareMetricsWrite is one FRU metrics update, as passed to UpdateHardwareMetrics
+type HardwareMetricsWrite struct {
+	FruType  string
+	Instance int
+	Metrics  string
+}
+
+// hardwareMetricsBatcher is implemented by stores that can write many metrics updates
+// in one round trip. It returns the updates that failed, and the error.
+type hardwareMetricsBatcher interface {
+	UpdateHardwareMetricsBatch(ctx context.Context, writes []HardwareMetricsWrite) ([]HardwareMetricsWrite, error)
+}
+
+// metricsBatch is the store the FRUs write through. While a batch is open their metrics
+// updates are queued, and flush writes them all at once; otherwise they pass straight
+// through to the underlying store.
+type metricsBatch struct {
+	StatusStore
+
+	mu     sync.Mutex
+	open   bool
+	writes []HardwareMetricsWrite
+}
+
+// UpdateHardwareMetrics queues a metrics update while a batch is open
+func (b *metricsBatch) UpdateHardwareMetrics(ctx context.Context, fruType string, instance int, metrics string) error {
+	b.mu.Lock()
+	if !b.open {
+		b.mu.Unlock()
+		return b.StatusStore.UpdateHardwareMetrics(ctx, fruType, instance, metrics)
+	}
+	b.writes = append(b.writes, HardwareMetricsWrite{FruType: fruType, Instance: instance, Metrics: metrics})
+	b.mu.Unlock()
+	return nil
+}
+
+// begin starts queueing metrics updates
+func (b *metricsBatch) begin() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.open = true
+	b.writes = nil
+}
+
+// flush ends the batch and writes the queued updates, in one round trip if the store
+// supports it. If the batched write fails, only the updates that didn't go through are
+// retried on their own.
+func (b *metricsBatch) flush(ctx context.Context, logger *Logger) {
+	b.mu.Lock()
+	writes := b.writes
+	b.open = false
+	b.writes = nil
+	b.mu.Unlock()
+
+	if len(writes) == 0 {
+		return
+	}
+	if batcher, ok := b.StatusStore.(hardwareMetricsBatcher); ok {
+		failed, err := batcher.UpdateHardwareMetricsBatch(ctx, writes)
+		if err == nil {
+			return
+		}
+		logger.Error("Failed to store %d of %d hardware metrics in one batch, writing them one by one: %v",
+			len(failed), len(writes), err)
+		writes = failed
+	}
+
+	for _, w := range writes {
+		if err := b.StatusStore.UpdateHardwareMetrics(ctx, w.FruType, w.Instance, w.Metrics); err != nil {
+			logger.Error("Failed to store %s %d metrics in Redis: %v", w.FruType, w.Instance, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// countingStore is a MemoryStore that supports batched metrics writes and counts the
+// round trips made, one per batch and one per individual write. Writes of the FRUs in
+// failing go through individually but fail within a batch.
+type countingStore struct {
+	*MemoryStore
+
+	mu      sync.Mutex
+	failing map[string]bool
+	batches int
+	singles map[string]int // individual writes by FRU
+}
+
+func newCountingStore(failing ...string) *countingStore {
+	s := &countingStore{MemoryStore: NewMemoryStore(), failing: make(map[string]bool), singles: make(map[string]int)}
+	for _, fru := range failing {
+		s.failing[fru] = true
+	}
+	return s
+}
+
+func (s *countingStore) UpdateHardwareMetrics(ctx context.Context, fruType string, instance int, metrics string) error {
+	s.mu.Lock()
+	s.singles[fmt.Sprintf("%s-%d", fruType, instance)]++
+	s.mu.Unlock()
+	return s.MemoryStore.UpdateHardwareMetrics(ctx, fruType, instance, metrics)
+}
+
+func (s *countingStore) UpdateHardwareMetricsBatch(ctx context.Context, writes []HardwareMetricsWrite) ([]HardwareMetricsWrite, error) {
+	s.mu.Lock()
+	s.batches++
+	s.mu.Unlock()
+
+	var failed []HardwareMetricsWrite
+	for _, w := range writes {
+		if s.failing[fmt.Sprintf("%s-%d", w.FruType, w.Instance)] {
+			failed = append(failed, w)
+			continue
+		}
+		if err := s.MemoryStore.UpdateHardwareMetrics(ctx, w.FruType, w.Instance, w.Metrics); err != nil {
+			return nil, err
+		}
+	}
+	if len(failed) > 0 {
+		return failed, errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	}
+	return nil, nil
+}
+
+// roundTrips returns the batches and individual writes made so far
+func (s *countingStore) roundTrips() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.batches
+	for _, count := range s.singles {
+		n += count
+	}
+	return n
+}
+
+// pollFans queues a metrics write for each of n fans in one batch and flushes it
+func pollFans(b *metricsBatch, n int) {
+	ctx := context.Background()
+	b.begin()
+	for i := 1; i <= n; i++ {
+		b.UpdateHardwareMetrics(ctx, "fan", i, fmt.Sprintf(`{"rpm":%d}`, 1000*i))
+	}
+	b.flush(ctx, newTestLogger())
+}
+
+func TestMetricsBatchOneRoundTrip(t *testing.T) {
+	const fans = 8
+	unbatched := newCountingStore()
+	for i := 1; i <= fans; i++ {
+		unbatched.UpdateHardwareMetrics(context.Background(), "fan", i, "{}")
+	}
+
+	store := newCountingStore()
+	pollFans(&metricsBatch{StatusStore: store}, fans)
+
+	// Eight FRUs cost eight round trips without the batch and one with it
+	if got, without := store.roundTrips(), unbatched.roundTrips(); got != 1 || without != fans {
+		t.Errorf("round trips = %d batched and %d unbatched, want 1 and %d", got, without, fans)
+	}
+	for i := 1; i <= fans; i++ {
+		if _, err := store.Get(fmt.Sprintf("hardware:fan:%d:metrics", i)); err != nil {
+			t.Errorf("metrics of fan %d not stored: %v", i, err)
+		}
+	}
+}
+
+func TestMetricsBatchRetriesOnlyFailedWrites(t *testing.T) {
+	store := newCountingStore("fan-2", "fan-5")
+	pollFans(&metricsBatch{StatusStore: store}, 6)
+
+	want := map[string]int{"fan-2": 1, "fan-5": 1}
+	if len(store.singles) != len(want) || store.singles["fan-2"] != 1 || store.singles["fan-5"] != 1 {
+		t.Errorf("individual writes %v, want only the failed FRUs %v", store.singles, want)
+	}
+	if got := store.roundTrips(); got != 3 {
+		t.Errorf("round trips = %d, want the batch and two retries", got)
+	}
+	for i := 1; i <= 6; i++ {
+		if _, err := store.Get(fmt.Sprintf("hardware:fan:%d:metrics", i)); err != nil {
+			t.Errorf("metrics of fan %d not stored: %v", i, err)
+		}
+	}
+}
+
+func TestMetricsBatchPassesThroughWhenClosed(t *testing.T) {
+	store := newCountingStore()
+	b := &metricsBatch{StatusStore: store}
+	b.UpdateHardwareMetrics(context.Background(), "psu", 1, "{}")
+
+	if store.batches != 0 || store.singles["psu-1"] != 1 {
+		t.Errorf("batches = %d and individual writes %v, want the write passed straight through", store.batches, store.singles)
+	}
+	b.flush(context.Background(), newTestLogger())
+	if store.batches != 0 {
+		t.Errorf("flush without a batch made %d batched writes", store.batches)
+	}
+}
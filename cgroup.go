@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// cgroupRoot is where the cgroup hierarchy is mounted
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroupDirs returns the directories a configured cgroup may live in, most likely first:
+// the unified (v2) hierarchy, then the v1 memory and systemd hierarchies. An absolute
+// path is used as is.
+func cgroupDirs(cgroup string) []string {
+	if filepath.IsAbs(cgroup) {
+		return []string{cgroup}
+	}
+	return []string{
+		filepath.Join(cgroupRoot, cgroup),
+		filepath.Join(cgroupRoot, "memory", cgroup),
+		filepath.Join(cgroupRoot, "systemd", cgroup),
+	}
+}
+
+// cgroupPIDs returns the PIDs in a cgroup and its descendants, in ascending order. A
+// cgroup that doesn't exist has no processes; systemd removes a service's cgroup once it stops.
+func cgroupPIDs(cgroup string) ([]int, error) {
+	for _, dir := range cgroupDirs(cgroup) {
+		if _, err := os.Stat(filepath.Join(dir, "cgroup.procs")); err != nil {
+			continue
+		}
+
+		var pids []int
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil // child cgroup removed during the walk
+				}
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+
+			data, err := os.ReadFile(filepath.Join(path, "cgroup.procs"))
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			for _, field := range strings.Fields(string(data)) {
+				pid, err := strconv.Atoi(field)
+				if err != nil {
+					return fmt.Errorf("invalid PID format in %s: %v", path, err)
+				}
+				pids = append(pids, pid)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading cgroup %s: %v", cgroup, err)
+		}
+
+		sort.Ints(pids)
+		return pids, nil
+	}
+	return nil, nil
+}
+
+// cgroupMemory returns the memory charged to a cgroup in bytes, from memory.current on
+// cgroup v2 or memory.usage_in_bytes of the v1 memory controller. Unlike the summed RSS
+// of its processes, this counts shared pages once and includes the page cache.
+func cgroupMemory(cgroup string) (int64, error) {
+	var files []string
+	for _, dir := range cgroupDirs(cgroup) {
+		files = append(files, filepath.Join(dir, "memory.current"), filepath.Join(dir, "memory.usage_in_bytes"))
+	}
+
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error reading cgroup memory: %v", err)
+		}
+
+		mem, err := strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing cgroup memory value: %v", err)
+		}
+		return mem, nil
+	}
+	return 0, fmt.Errorf("no memory accounting found for cgroup %s", cgroup)
+}
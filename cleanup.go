@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"strings"
+)
+
+// cleanupStaleKeys deletes the Redis keys of processes that are no longer configured,
+// such as those left behind when a process was removed while hostd was not running.
+// Without a key prefix it does nothing, since the keys of other instances sharing the
+// Redis DB would look stale.
+func cleanupStaleKeys(ctx context.Context, redis *RedisClient, monitor *ProcessMonitor, logger *Logger) {
+	if redis.keyPrefix == "" {
+		logger.Error("Skipping cleanup of stale Redis keys, redis.keyPrefix is empty")
+		return
+	}
+
+	keep := make(map[string]bool)
+	for _, proc := range monitor.Processes() {
+		keep[proc.Name] = true
+	}
+
+	deleted, err := redis.DeleteStaleProcessKeys(ctx, keep)
+	if len(deleted) > 0 {
+		logger.Info("Deleted Redis keys of %d processes no longer configured: %s", len(deleted), strings.Join(deleted, ", "))
+	}
+	if err != nil {
+		logger.Error("Error cleaning up stale Redis keys: %v", err)
+	}
+}
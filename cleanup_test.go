@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCleanupStaleKeysNeedsKeyPrefix(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := startFakeRedis(t, listener)
+	host, port := server.addr()
+
+	config := RedisConfig{Host: host, Port: port}
+	config.OpTimeout.Duration = time.Second
+	redis, err := NewRedisClient(&config, newTestLogger())
+	if err != nil {
+		t.Fatalf("NewRedisClient: %v", err)
+	}
+	defer redis.Close()
+
+	pm, _, _ := newTestMonitor([]Process{{Name: "api"}}, MonitoringConfig{})
+	cleanupStaleKeys(context.Background(), redis, pm, newTestLogger())
+
+	for _, command := range server.received() {
+		if len(command) > 0 && (command[0] == "scan" || command[0] == "del" || command[0] == "unlink") {
+			t.Errorf("cleanup without a key prefix sent %v", command)
+		}
+	}
+}
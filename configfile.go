@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// unmarshalConfigFile parses a config file into v, as YAML if the file name ends in
+// .yaml or .yml and as JSON otherwise. YAML is converted to JSON first, so both formats
+// share the json struct tags and custom decoders such as Duration.
+func unmarshalConfigFile(filename string, data []byte, v interface{}) error {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(converted, v)
+	default:
+		return json.Unmarshal(data, v)
+	}
+}
+
+// Environment variables overriding config file settings
+const (
+	envConfigFile    = "HOSTD_CONFIG"
+	envProcessFile   = "HOSTD_PROCESSES"
+	envRedisHost     = "HOSTD_REDIS_HOST"
+	envRedisPort     = "HOSTD_REDIS_PORT"
+	envRedisPassword = "HOSTD_REDIS_PASSWORD"
+)
+
+// configPath resolves a config file path: the flag value if given, then the
+// environment variable, then the default
+func configPath(flagValue, envVar, def string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if path := os.Getenv(envVar); path != "" {
+		return path
+	}
+	return def
+}
+
+// applyEnvOverrides replaces Redis settings loaded from the config file with those set
+// in the environment
+func (c *Config) applyEnvOverrides() error {
+	if host := os.Getenv(envRedisHost); host != "" {
+		c.Redis.Host = host
+	}
+	if value := os.Getenv(envRedisPort); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("%s must be a port number, got %q", envRedisPort, value)
+		}
+		c.Redis.Port = port
+	}
+	if password, ok := os.LookupEnv(envRedisPassword); ok {
+		c.Redis.Password = password
+	}
+	return nil
+}
+
+// String describes the Redis connection settings with the password redacted, for logging
+func (c RedisConfig) String() string {
+	password := "none"
+	if c.Password != "" {
+		password = "redacted"
+	}
+	if c.Sentinel != nil {
+		return fmt.Sprintf("master %s via sentinels %s (db %d, password %s)",
+			c.Sentinel.MasterName, strings.Join(c.Sentinel.SentinelAddrs, ","), c.DB, password)
+	}
+	return fmt.Sprintf("%s:%d (db %d, password %s)", c.Host, c.Port, c.DB, password)
+}
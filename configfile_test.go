@@ -0,0 +1,232 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeConfigFile writes data to a file named name in a fresh directory and returns its path
+func writeConfigFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigYAMLMatchesJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		yaml string
+	}{
+		{
+			name: "redis and intervals",
+			json: `{"redis": {"host": "redis.internal", "port": 6380, "db": 2, "tls": true, "opTimeout": "3s", "keyPrefix": "web-1"},
+				"checkInterval": "30s", "hardwareInterval": "1m30s"}`,
+			yaml: `
+# Comments are the reason to use YAML
+redis:
+  host: redis.internal
+  port: 6380
+  db: 2
+  tls: true
+  opTimeout: 3s
+  keyPrefix: web-1
+checkInterval: 30s
+hardwareInterval: 1m30s
+`,
+		},
+		{
+			name: "nested optional sections",
+			json: `{"redis": {"host": "localhost", "port": 6379, "readReplica": {"host": "replica", "port": 6379}},
+				"logging": {"level": "debug", "format": "json"},
+				"kafka": {"restProxy": "http://kafka-rest:8082", "topic": "hostd", "events": ["process_transition"]}}`,
+			yaml: `
+redis:
+  host: localhost
+  port: 6379
+  readReplica:
+    host: replica
+    port: 6379
+logging:
+  level: debug
+  format: json
+kafka:
+  restProxy: http://kafka-rest:8082
+  topic: hostd
+  events: [process_transition]
+`,
+		},
+		{
+			name: "empty",
+			json: `{}`,
+			yaml: "{}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fromJSON, err := loadConfig(writeConfigFile(t, "config.json", tt.json))
+			if err != nil {
+				t.Fatalf("JSON: %v", err)
+			}
+			for _, name := range []string{"config.yaml", "config.yml"} {
+				fromYAML, err := loadConfig(writeConfigFile(t, name, tt.yaml))
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if !reflect.DeepEqual(fromYAML, fromJSON) {
+					t.Errorf("%s decoded to\n%+v\nJSON to\n%+v", name, fromYAML, fromJSON)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessConfigYAMLMatchesJSON(t *testing.T) {
+	jsonData := `{"processes": [
+		{"name": "api", "restart": true, "maxRetries": 5, "command": "/usr/bin/api", "args": ["-v"],
+		 "env": {"MODE": "prod"}, "labels": {"team": "payments"}, "startupGrace": "20s", "restartGroup": "web"},
+		{"name": "nginx", "matchMode": "exact"}]}`
+	yamlData := `
+processes:
+  - name: api
+    restart: true
+    maxRetries: 5
+    command: /usr/bin/api
+    args: ["-v"]
+    env:
+      MODE: prod
+    labels:
+      team: payments
+    startupGrace: 20s
+    restartGroup: web
+  - name: nginx
+    matchMode: exact
+`
+
+	fromJSON, err := loadProcessConfig(writeConfigFile(t, "processes.json", jsonData))
+	if err != nil {
+		t.Fatalf("JSON: %v", err)
+	}
+	fromYAML, err := loadProcessConfig(writeConfigFile(t, "processes.yaml", yamlData))
+	if err != nil {
+		t.Fatalf("YAML: %v", err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Errorf("YAML decoded to\n%+v\nJSON to\n%+v", fromYAML, fromJSON)
+	}
+	if len(fromYAML.Processes) != 2 || fromYAML.Processes[0].StartupGrace.Duration.Seconds() != 20 {
+		t.Errorf("processes %+v, want api with a 20s startup grace and nginx", fromYAML.Processes)
+	}
+}
+
+func TestConfigYAMLErrors(t *testing.T) {
+	if _, err := loadConfig(writeConfigFile(t, "config.yaml", "redis: [unclosed\n")); err == nil {
+		t.Error("loadConfig accepted malformed YAML")
+	}
+	// A JSON file is never parsed as YAML, even though JSON is mostly valid YAML
+	if _, err := loadConfig(writeConfigFile(t, "config.json", "redis:\n  host: localhost\n")); err == nil {
+		t.Error("loadConfig parsed YAML in a .json file")
+	}
+}
+
+// setEnv sets an environment variable for the test, or unsets it if value is nil
+func setEnv(t *testing.T, key string, value *string) {
+	t.Helper()
+	t.Setenv(key, "") // restores the original value when the test ends
+	if value == nil {
+		os.Unsetenv(key)
+		return
+	}
+	os.Setenv(key, *value)
+}
+
+func TestConfigPathPrecedence(t *testing.T) {
+	env := "/etc/hostd/env.json"
+	tests := []struct {
+		name string
+		flag string
+		env  *string
+		want string
+	}{
+		{"flag over env", "/etc/hostd/flag.json", &env, "/etc/hostd/flag.json"},
+		{"flag alone", "/etc/hostd/flag.json", nil, "/etc/hostd/flag.json"},
+		{"env over default", "", &env, env},
+		{"default", "", nil, "config.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, envConfigFile, tt.env)
+			if got := configPath(tt.flag, envConfigFile, "config.json"); got != tt.want {
+				t.Errorf("configPath = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedisSettingsPrecedence(t *testing.T) {
+	file := writeConfigFile(t, "config.json", `{"redis": {"host": "file-host", "port": 6380, "password": "file-secret"}}`)
+	empty := writeConfigFile(t, "empty.json", `{}`)
+	envHost, envPort, envPassword, blank := "env-host", "7000", "env-secret", ""
+
+	tests := []struct {
+		name                 string
+		file                 string
+		host, port, password *string
+		wantHost             string
+		wantPort             int
+		wantPassword         string
+	}{
+		{"env over file", file, &envHost, &envPort, &envPassword, "env-host", 7000, "env-secret"},
+		{"file without env", file, nil, nil, nil, "file-host", 6380, "file-secret"},
+		{"env over default", empty, &envHost, &envPort, &envPassword, "env-host", 7000, "env-secret"},
+		// Host and port have no default, so validation rejects a config that sets neither
+		{"unset everywhere", empty, nil, nil, nil, "", 0, ""},
+		{"env mixed with file", file, nil, &envPort, nil, "file-host", 7000, "file-secret"},
+		{"empty host and port keep file", file, &blank, &blank, nil, "file-host", 6380, "file-secret"},
+		{"empty password clears file", file, nil, nil, &blank, "file-host", 6380, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, envRedisHost, tt.host)
+			setEnv(t, envRedisPort, tt.port)
+			setEnv(t, envRedisPassword, tt.password)
+
+			config, err := loadConfig(tt.file)
+			if err != nil {
+				t.Fatalf("loadConfig: %v", err)
+			}
+			if config.Redis.Host != tt.wantHost || config.Redis.Port != tt.wantPort || config.Redis.Password != tt.wantPassword {
+				t.Errorf("redis %s:%d password %q, want %s:%d password %q", config.Redis.Host, config.Redis.Port,
+					config.Redis.Password, tt.wantHost, tt.wantPort, tt.wantPassword)
+			}
+		})
+	}
+}
+
+func TestRedisPortEnvRejectsInvalid(t *testing.T) {
+	file := writeConfigFile(t, "config.json", `{"redis": {"host": "localhost", "port": 6379}}`)
+	for _, value := range []string{"redis", "0", "65536"} {
+		value := value
+		setEnv(t, envRedisPort, &value)
+		if _, err := loadConfig(file); err == nil {
+			t.Errorf("loadConfig accepted %s=%q", envRedisPort, value)
+		}
+	}
+}
+
+func TestRedisConfigStringRedactsPassword(t *testing.T) {
+	config := RedisConfig{Host: "redis", Port: 6379, Password: "hunter2"}
+	if got := config.String(); got != "redis:6379 (db 0, password redacted)" {
+		t.Errorf("String() = %q", got)
+	}
+	config.Password = ""
+	if got := config.String(); got != "redis:6379 (db 0, password none)" {
+		t.Errorf("String() without a password = %q", got)
+	}
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Command actions accepted on the hostd:commands channel
+const (
+	ActionStart      = "start"
+	ActionStop       = "stop"
+	ActionRestart    = "restart"
+	ActionStatus     = "status"
+	ActionResetStats = "reset-stats"
+
+	ActionReloadHardware = "reload-hardware" // re-reads the hardware config, takes no process
+	ActionInjectStatus   = "inject-status"   // forces a synthetic status to test alerting
+)
+
+// defaultStopTimeout is how long a process has to exit after SIGTERM before it is killed
+const defaultStopTimeout = 10 * time.Second
+
+// defaultShutdownTimeout bounds stopping processes while hostd shuts down
+const defaultShutdownTimeout = 30 * time.Second
+
+// GroupRestartResult records the outcome of restarting one member of a restart group
+type GroupRestartResult struct {
+	Process string `json:"process"`
+	Error   string `json:"error,omitempty"`
+}
+
+// HandleCommand executes a command received over Redis pub/sub. It returns the
+// result sent back to commands with a reply channel, nil for actions without one.
+func (pm *ProcessMonitor) HandleCommand(ctx context.Context, cmd Command) (interface{}, error) {
+	switch cmd.Action {
+	case ActionStart:
+		return nil, pm.StartProcess(ctx, cmd.Process)
+	case ActionStop:
+		return nil, pm.StopProcess(ctx, cmd.Process)
+	case ActionRestart:
+		return nil, pm.RestartProcess(ctx, cmd.Process)
+	case ActionResetStats:
+		return nil, pm.ResetStats(ctx, cmd.Process)
+	case ActionInjectStatus:
+		return nil, pm.InjectStatus(ctx, cmd)
+	case ActionStatus:
+		status, err := pm.ProcessStatus(ctx, cmd.Process)
+		if err != nil {
+			return nil, err
+		}
+		return status, nil
+	default:
+		pm.logger.Error("Rejected command with unknown action %q for process %s", cmd.Action, cmd.Process)
+		return nil, fmt.Errorf("unknown action %q", cmd.Action)
+	}
+}
+
+// ResetStats restarts the memory and CPU min/max tracking of a process from its current
+// sample, for example after a known-good deployment. The rest of the status is kept.
+func (pm *ProcessMonitor) ResetStats(ctx context.Context, name string) error {
+	if _, err := pm.findProcess(name); err != nil {
+		return err
+	}
+
+	unlock := pm.lockProcess(name)
+	defer unlock()
+
+	data, err := pm.redis.GetProcessStatusFresh(ctx, name)
+	if err == redis.Nil {
+		return fmt.Errorf("no status recorded yet for process %s", name)
+	}
+	if err != nil {
+		return fmt.Errorf("error getting status of process %s: %v", name, err)
+	}
+	var status ProcessStatus
+	if err := json.Unmarshal([]byte(data), &status); err != nil {
+		return fmt.Errorf("error parsing status: %v", err)
+	}
+
+	now := time.Now()
+	status.MemoryStats = MemoryStats{
+		MinMemory:    status.CurrentMemory,
+		MaxMemory:    status.CurrentMemory,
+		MinTimestamp: now,
+		MaxTimestamp: now,
+	}
+	if status.Status == "up" {
+		status.CPUStats = CPUStats{
+			MinCPU:       status.CurrentCPU,
+			MaxCPU:       status.CurrentCPU,
+			MinTimestamp: now,
+			MaxTimestamp: now,
+		}
+	} else {
+		// No sample to start from; the next check initializes the stats
+		status.CPUStats = CPUStats{}
+	}
+
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("error marshaling status: %v", err)
+	}
+	if err := pm.redis.UpdateProcessStatus(ctx, name, string(statusJSON)); err != nil {
+		return fmt.Errorf("error updating Redis: %v", err)
+	}
+
+	pm.logger.Info("Reset memory and CPU stats for process %s", name)
+	return nil
+}
+
+// ProcessStatus returns the current status of a configured process as stored in Redis
+func (pm *ProcessMonitor) ProcessStatus(ctx context.Context, name string) (json.RawMessage, error) {
+	if _, err := pm.findProcess(name); err != nil {
+		return nil, err
+	}
+
+	status, err := pm.redis.GetProcessStatus(ctx, name)
+	if err == redis.Nil {
+		return nil, fmt.Errorf("no status recorded yet for process %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting status of process %s: %v", name, err)
+	}
+	return json.RawMessage(status), nil
+}
+
+// findProcess looks up a configured process by name, logging an error if it isn't configured
+func (pm *ProcessMonitor) findProcess(name string) (Process, error) {
+	if proc, ok := pm.lookupProcess(name); ok {
+		return proc, nil
+	}
+
+	pm.logger.Error("Rejected command for unknown process %s", name)
+	return Process{}, fmt.Errorf("process %s is not configured", name)
+}
+
+// lookupProcess finds a configured process by name
+func (pm *ProcessMonitor) lookupProcess(name string) (Process, bool) {
+	for _, proc := range pm.Processes() {
+		if proc.Name == name {
+			return proc, true
+		}
+	}
+	return Process{}, false
+}
+
+// isConfigured reports whether a process is in the monitored list
+func (pm *ProcessMonitor) isConfigured(name string) bool {
+	for _, proc := range pm.Processes() {
+		if proc.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// StartProcess launches a configured process, after starting any of its dependencies that
+// aren't running, and refreshes their status in Redis
+func (pm *ProcessMonitor) StartProcess(ctx context.Context, name string) error {
+	proc, err := pm.findProcess(name)
+	if err != nil {
+		return err
+	}
+
+	chain := pm.withDependencies(proc)
+	for _, dep := range chain[:len(chain)-1] {
+		if dep.Container != "" {
+			continue // can't be started by hostd; startProcess refuses if it is down
+		}
+		err := pm.startProcess(ctx, dep)
+		pm.updateProcStatus(ctx, dep)
+		if err != nil {
+			return fmt.Errorf("error starting dependency %s of process %s: %v", dep.Name, proc.Name, err)
+		}
+	}
+
+	err = pm.startProcess(ctx, proc)
+	pm.updateProcStatus(ctx, proc)
+	return err
+}
+
+// StopProcess stops a configured process, after stopping the processes that depend on
+// it, and refreshes their status in Redis
+func (pm *ProcessMonitor) StopProcess(ctx context.Context, name string) error {
+	proc, err := pm.findProcess(name)
+	if err != nil {
+		return err
+	}
+
+	for _, dependent := range pm.dependents(proc) {
+		if dependent.Container != "" {
+			continue
+		}
+		pm.logger.Info("Stopping process %s, which depends on %s", dependent.Name, proc.Name)
+		err := pm.stopProcess(ctx, dependent)
+		pm.updateProcStatus(ctx, dependent)
+		if err != nil {
+			return fmt.Errorf("error stopping dependent %s of process %s: %v", dependent.Name, proc.Name, err)
+		}
+	}
+
+	err = pm.stopProcess(ctx, proc)
+	pm.updateProcStatus(ctx, proc)
+	return err
+}
+
+// StopOnShutdown stops every process marked stopOnShutdown, giving up on any still
+// running once the shutdown timeout has passed. Processes are stopped in parallel, except
+// that a process is only stopped once everything depending on it has been.
+func (pm *ProcessMonitor) StopOnShutdown(ctx context.Context) {
+	var procs []Process
+	for _, proc := range pm.Processes() {
+		if proc.StopOnShutdown && proc.Container == "" {
+			procs = append(procs, proc)
+		}
+	}
+	if len(procs) == 0 {
+		return
+	}
+
+	timeout := pm.config.ShutdownTimeout.Duration
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	pm.logger.Info("Stopping %d processes before shutdown (timeout: %v)", len(procs), timeout)
+	depths := dependencyDepths(pm.Processes())
+	maxDepth := 0
+	for _, proc := range procs {
+		if depths[proc.Name] > maxDepth {
+			maxDepth = depths[proc.Name]
+		}
+	}
+	for depth := maxDepth; depth >= 0; depth-- {
+		var wg sync.WaitGroup
+		for _, proc := range procs {
+			if depths[proc.Name] != depth {
+				continue
+			}
+			wg.Add(1)
+			go func(proc Process) {
+				defer wg.Done()
+				if err := pm.stopProcess(ctx, proc); err != nil {
+					pm.logger.Error("Error stopping process %s on shutdown: %v", proc.Name, err)
+					return
+				}
+				pm.logger.Info("Stopped process %s on shutdown", proc.Name)
+			}(proc)
+		}
+		wg.Wait()
+	}
+}
+
+// RestartProcess restarts a configured process together with the rest of its restart
+// group and refreshes their status in Redis. Members are stopped in reverse config
+// order and started in config order; if any member fails the error lists each result.
+func (pm *ProcessMonitor) RestartProcess(ctx context.Context, name string) error {
+	proc, err := pm.findProcess(name)
+	if err != nil {
+		return err
+	}
+
+	members := pm.restartGroupMembers(proc)
+	if len(members) == 1 {
+		err := pm.restartProcess(ctx, proc)
+		pm.updateProcStatus(ctx, proc)
+		return err
+	}
+
+	pm.logger.Info("Restarting group %s (%d processes) triggered by %s", proc.RestartGroup, len(members), proc.Name)
+	results := pm.restartGroup(ctx, members)
+	for _, member := range members {
+		pm.updateProcStatus(ctx, member)
+	}
+
+	var failed int
+	details := make([]string, 0, len(results))
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+			details = append(details, fmt.Sprintf("%s: %s", result.Process, result.Error))
+		} else {
+			details = append(details, fmt.Sprintf("%s: ok", result.Process))
+		}
+	}
+	if failed > 0 {
+		pm.logger.Error("Restart of group %s partially failed (%d of %d): %s",
+			proc.RestartGroup, failed, len(results), strings.Join(details, "; "))
+		return fmt.Errorf("restart of group %s partially failed: %s", proc.RestartGroup, strings.Join(details, "; "))
+	}
+
+	pm.logger.Info("Restarted group %s", proc.RestartGroup)
+	return nil
+}
+
+// restartGroup stops group members in reverse order, then starts them in order. Members
+// are ordered by config, except that dependencies come before the members needing them.
+func (pm *ProcessMonitor) restartGroup(ctx context.Context, members []Process) []GroupRestartResult {
+	members = dependencyOrder(members)
+	results := make([]GroupRestartResult, len(members))
+	for i, member := range members {
+		results[i].Process = member.Name
+	}
+
+	for i := len(members) - 1; i >= 0; i-- {
+		if err := pm.stopProcess(ctx, members[i]); err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+	for i, member := range members {
+		if results[i].Error != "" {
+			continue
+		}
+		if err := pm.startProcess(ctx, member); err != nil {
+			results[i].Error = err.Error()
+		}
+	}
+
+	return results
+}
+
+// restartProcess stops and then starts a single process
+func (pm *ProcessMonitor) restartProcess(ctx context.Context, proc Process) error {
+	if err := pm.stopProcess(ctx, proc); err != nil {
+		return err
+	}
+	return pm.startProcess(ctx, proc)
+}
+
+// startProcess launches a process unless it is already running and waits for it to come up
+func (pm *ProcessMonitor) startProcess(ctx context.Context, proc Process) error {
+	if proc.Container != "" {
+		return fmt.Errorf("process %s runs in container %s, which is managed by Docker", proc.Name, proc.Container)
+	}
+
+	pid, err := pm.getProcessPID(proc)
+	if err != nil {
+		return err
+	}
+	if pid > 0 {
+		pm.logger.Info("Process %s already running (PID: %d)", proc.Name, pid)
+		return nil
+	}
+	if err := pm.checkDependencies(ctx, proc); err != nil {
+		return err
+	}
+
+	if proc.Command == "" {
+		return fmt.Errorf("process %s has no command configured", proc.Name)
+	}
+	pm.setManualStop(ctx, proc, false)
+	pm.takeChildExit(proc.Name)
+
+	cmd := exec.Command(proc.Command, proc.Args...)
+	cmd.Dir = proc.WorkingDir
+	if len(proc.Env) > 0 {
+		cmd.Env = os.Environ()
+		for key, value := range proc.Env {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+	}
+	// Run in its own process group so signals sent to hostd don't reach it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	output, closeOutput, err := pm.captureOutput(proc)
+	if err != nil {
+		return err
+	}
+	if output != nil {
+		cmd.Stdout = output
+		cmd.Stderr = output
+	}
+	if err := cmd.Start(); err != nil {
+		closeOutput()
+		return fmt.Errorf("error starting process %s: %v", proc.Name, err)
+	}
+	pm.logger.Info("Started process %s (PID: %d)", proc.Name, cmd.Process.Pid)
+	pm.recordLaunch(proc.Name)
+	pm.trackChild(proc, cmd, closeOutput)
+
+	pid, err = pm.verifyProcessStarted(ctx, proc)
+	if err != nil {
+		pm.logger.Error("Process %s failed to start: %v", proc.Name, err)
+		return err
+	}
+
+	pm.logger.Info("Process %s is up (PID: %d)", proc.Name, pid)
+	return nil
+}
+
+// trackChild reaps a child hostd started, logs how it exited and then calls done
+func (pm *ProcessMonitor) trackChild(proc Process, cmd *exec.Cmd, done func()) {
+	go func() {
+		err := cmd.Wait()
+		done()
+
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			pm.logger.Error("Error waiting for process %s (PID: %d): %v", proc.Name, cmd.Process.Pid, err)
+			return
+		}
+		pm.logger.Info("Process %s (PID: %d) started by hostd exited with code %d",
+			proc.Name, cmd.Process.Pid, cmd.ProcessState.ExitCode())
+		pm.recordChildExit(proc.Name, cmd.ProcessState.ExitCode())
+	}()
+}
+
+// stopProcess sends SIGTERM to every instance of a running process and SIGKILL to
+// those still running after the process's stop timeout
+func (pm *ProcessMonitor) stopProcess(ctx context.Context, proc Process) error {
+	if proc.Container != "" {
+		return fmt.Errorf("process %s runs in container %s, which is managed by Docker", proc.Name, proc.Container)
+	}
+
+	pids, err := pm.prober.PIDs(proc)
+	if err != nil {
+		return err
+	}
+	if len(pids) == 0 {
+		pm.logger.Info("Process %s already stopped", proc.Name)
+		return nil
+	}
+
+	timeout := proc.StopTimeout.Duration
+	if timeout <= 0 {
+		timeout = defaultStopTimeout
+	}
+
+	pm.setManualStop(ctx, proc, true)
+	pm.logger.Info("Stopping process %s (PIDs: %v, timeout: %v)", proc.Name, pids, timeout)
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, syscall.SIGTERM); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("error sending SIGTERM to process %s (PID: %d): %v", proc.Name, pid, err)
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		pids, err = pm.prober.PIDs(proc)
+		if err != nil {
+			return err
+		}
+		if len(pids) == 0 {
+			pm.logger.Info("Process %s exited cleanly after SIGTERM", proc.Name)
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	pm.logger.Error("Process %s did not exit within %v, sending SIGKILL to PIDs %v", proc.Name, timeout, pids)
+	for _, pid := range pids {
+		if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("error sending SIGKILL to process %s (PID: %d): %v", proc.Name, pid, err)
+		}
+	}
+	pm.logger.Info("Process %s force-killed", proc.Name)
+	return nil
+}
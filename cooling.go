@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const defaultCoolingLowDuty = 50
+
+// CoolingConfig pairs temperature sensors with the fans that cool them, to catch airflow
+// problems that no single FRU reports: a hot sensor whose fans aren't ramping up
+type CoolingConfig struct {
+	Sensors []TemperatureSensorConfig `json:"sensors,omitempty"`
+	LowDuty int                       `json:"lowDuty"` // fans below this duty percentage aren't cooling hard, default 50
+}
+
+// TemperatureSensorConfig is a temperature sensor and the fans associated with it
+type TemperatureSensorConfig struct {
+	Name     string  `json:"name"`
+	Path     string  `json:"path"`     // sysfs temp*_input file, in millidegrees Celsius
+	Warn     float64 `json:"warn"`     // degrees Celsius where the yellow band starts
+	Critical float64 `json:"critical"` // degrees Celsius where the red band starts
+	Fans     []int   `json:"fans"`     // slots of the fans cooling this sensor
+
+	BoostDuty int `json:"boostDuty"` // duty the fans are boosted to while the sensor is critical, 0 disables
+}
+
+// validate checks the sensors and their fan associations against the fan population
+func (c CoolingConfig) validate(fans FruGroupConfig) error {
+	if c.LowDuty < 0 || c.LowDuty > 100 {
+		return fmt.Errorf("cooling lowDuty %d is outside 0-100", c.LowDuty)
+	}
+
+	seen := make(map[string]bool, len(c.Sensors))
+	for _, sensor := range c.Sensors {
+		switch {
+		case sensor.Name == "":
+			return fmt.Errorf("cooling sensor with path %q has no name", sensor.Path)
+		case seen[sensor.Name]:
+			return fmt.Errorf("duplicate cooling sensor name %s", sensor.Name)
+		case sensor.Path == "":
+			return fmt.Errorf("cooling sensor %s has no path", sensor.Name)
+		case sensor.Warn >= sensor.Critical:
+			return fmt.Errorf("cooling sensor %s warn %.1f must be below critical %.1f", sensor.Name, sensor.Warn, sensor.Critical)
+		case len(sensor.Fans) == 0:
+			return fmt.Errorf("cooling sensor %s has no fans", sensor.Name)
+		case sensor.BoostDuty != 0 && (sensor.BoostDuty < fanSafeDutyFloor || sensor.BoostDuty > 100):
+			return fmt.Errorf("cooling sensor %s boostDuty %d is outside %d-100", sensor.Name, sensor.BoostDuty, fanSafeDutyFloor)
+		}
+		seen[sensor.Name] = true
+
+		for _, slot := range sensor.Fans {
+			if slot < 0 || slot >= fans.Count || !fans.expected(slot) {
+				return fmt.Errorf("cooling sensor %s lists fan %d, which is not an expected fan", sensor.Name, slot)
+			}
+		}
+	}
+	return nil
+}
+
+// coolingCheck correlates a temperature sensor with the duty of its fans
+type coolingCheck struct {
+	sensor  TemperatureSensorConfig
+	fans    []*Fan
+	lowDuty int
+	status  FruStatus // status from the last poll
+	boosted bool      // whether the fans are boosted for this sensor
+}
+
+// newCoolingChecks returns a check for every configured sensor, with its fans looked up by slot
+func newCoolingChecks(config CoolingConfig, fans map[int]*Fan) []*coolingCheck {
+	lowDuty := config.LowDuty
+	if lowDuty == 0 {
+		lowDuty = defaultCoolingLowDuty
+	}
+
+	checks := make([]*coolingCheck, 0, len(config.Sensors))
+	for _, sensor := range config.Sensors {
+		check := &coolingCheck{sensor: sensor, lowDuty: lowDuty, status: FruStatusGreen}
+		for _, slot := range sensor.Fans {
+			if fan, ok := fans[slot]; ok {
+				check.fans = append(check.fans, fan)
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// name is the component the check appears as in the chassis status
+func (c *coolingCheck) name() string {
+	return "cooling:" + c.sensor.Name
+}
+
+// evaluate reads the sensor and reports insufficient cooling: yellow or red when the
+// temperature is in the warning or critical band while none of the present fans runs
+// at lowDuty or above. A hot sensor with its fans ramped up is left to the fan and
+// temperature thresholds. A change is logged apart from single-FRU faults.
+func (c *coolingCheck) evaluate(logger *Logger) (FruStatus, error) {
+	milli, err := readSysfsInt(c.sensor.Path)
+	if err != nil {
+		return c.status, err
+	}
+	temp := float64(milli) / 1000
+	c.applyBoost(temp, logger)
+
+	var duties []string
+	ramped := false
+	for _, fan := range c.fans {
+		if !fan.available() {
+			continue
+		}
+		duties = append(duties, fmt.Sprintf("%s=%d%%", fan.getName(), fan.duty))
+		if fan.duty >= c.lowDuty {
+			ramped = true
+		}
+	}
+
+	status := FruStatusGreen
+	switch {
+	case ramped:
+	case temp >= c.sensor.Critical:
+		status = FruStatusRed
+	case temp >= c.sensor.Warn:
+		status = FruStatusYellow
+	}
+
+	if status != c.status {
+		switch status {
+		case FruStatusRed:
+			logger.Critical("Insufficient cooling at %s: %.1f°C is above critical %.1f°C but its fans are below %d%% duty (%s)",
+				c.sensor.Name, temp, c.sensor.Critical, c.lowDuty, strings.Join(duties, ", "))
+		case FruStatusYellow:
+			logger.Error("Insufficient cooling at %s: %.1f°C is above warn %.1f°C but its fans are below %d%% duty (%s)",
+				c.sensor.Name, temp, c.sensor.Warn, c.lowDuty, strings.Join(duties, ", "))
+		default:
+			logger.Info("Cooling at %s sufficient again: %.1f°C (%s)", c.sensor.Name, temp, strings.Join(duties, ", "))
+		}
+		c.status = status
+	}
+	return status, nil
+}
+
+// applyBoost boosts the sensor's fans to BoostDuty once it reaches critical, and restores
+// their previous duty only once it has cooled below warn, so the boost doesn't flap
+// around the critical threshold
+func (c *coolingCheck) applyBoost(temp float64, logger *Logger) {
+	switch {
+	case c.sensor.BoostDuty == 0:
+	case !c.boosted && temp >= c.sensor.Critical:
+		logger.Critical("Thermal red at %s: %.1f°C is above critical %.1f°C, boosting its fans to %d%% duty",
+			c.sensor.Name, temp, c.sensor.Critical, c.sensor.BoostDuty)
+		for _, fan := range c.fans {
+			if err := fan.boost(c.sensor.Name, c.sensor.BoostDuty); err != nil {
+				logger.Error("Failed to boost fan %s for %s: %v", fan.getName(), c.sensor.Name, err)
+			}
+		}
+		c.boosted = true
+	case c.boosted && temp < c.sensor.Warn:
+		logger.Info("%s cooled to %.1f°C, below warn %.1f°C, ending the fan boost", c.sensor.Name, temp, c.sensor.Warn)
+		c.release(logger)
+	}
+}
+
+// release restores the duty the sensor's fans had before it boosted them
+func (c *coolingCheck) release(logger *Logger) {
+	if !c.boosted {
+		return
+	}
+	for _, fan := range c.fans {
+		if err := fan.unboost(c.sensor.Name); err != nil {
+			logger.Error("Failed to restore duty of fan %s after boost for %s: %v", fan.getName(), c.sensor.Name, err)
+		}
+	}
+	c.boosted = false
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newTestCoolingCheck returns a cooling check of one sensor file, warn at 70°C and
+// critical at 85°C, with one fan at 40% duty, below the default lowDuty
+func newTestCoolingCheck(t *testing.T, boostDuty int) (*coolingCheck, *Fan, func(celsius float64)) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "temp1_input")
+	setTemp := func(celsius float64) {
+		if err := os.WriteFile(path, []byte(strconv.Itoa(int(celsius*1000))), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fan := NewFan("fan", 0, ThresholdsConfig{}.withDefaults().Fan, newTestLogger(), NewMemoryStore(), nil)
+	fan.duty = 40
+	config := CoolingConfig{Sensors: []TemperatureSensorConfig{{
+		Name: "cpu", Path: path, Warn: 70, Critical: 85, Fans: []int{0}, BoostDuty: boostDuty,
+	}}}
+	return newCoolingChecks(config, map[int]*Fan{0: fan})[0], fan, setTemp
+}
+
+func TestCoolingBands(t *testing.T) {
+	tests := []struct {
+		name    string
+		celsius float64
+		duty    int
+		want    FruStatus
+	}{
+		{"cool", 50, 40, FruStatusGreen},
+		{"just below warn", 69.999, 40, FruStatusGreen},
+		{"at warn", 70, 40, FruStatusYellow},
+		{"just below critical", 84.999, 40, FruStatusYellow},
+		{"at critical", 85, 40, FruStatusRed},
+		{"critical with fans ramped", 90, defaultCoolingLowDuty, FruStatusGreen},
+		{"warn with fans just below lowDuty", 75, defaultCoolingLowDuty - 1, FruStatusYellow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check, fan, setTemp := newTestCoolingCheck(t, 0)
+			fan.duty = tt.duty
+			setTemp(tt.celsius)
+			got, err := check.evaluate(newTestLogger())
+			if err != nil {
+				t.Fatalf("evaluate: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("%.3f°C with fans at %d%% is %s, want %s", tt.celsius, tt.duty, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCoolingBoostHysteresis walks the temperature up past critical and back down,
+// checking the boost starts at critical and only ends below warn
+func TestCoolingBoostHysteresis(t *testing.T) {
+	check, fan, setTemp := newTestCoolingCheck(t, 100)
+	logger := newTestLogger()
+
+	steps := []struct {
+		celsius float64
+		boosted bool
+		duty    int
+		status  FruStatus
+	}{
+		{60, false, 40, FruStatusGreen},
+		{84.999, false, 40, FruStatusYellow},
+		{85, true, 100, FruStatusGreen}, // boosted fans count as ramped
+		{80, true, 100, FruStatusGreen},
+		{70, true, 100, FruStatusGreen}, // at warn the boost holds
+		{84.999, true, 100, FruStatusGreen},
+		{69.999, false, 40, FruStatusGreen}, // below warn the previous duty returns
+		{75, false, 40, FruStatusYellow},
+	}
+	for i, step := range steps {
+		setTemp(step.celsius)
+		status, err := check.evaluate(logger)
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if check.boosted != step.boosted || fan.duty != step.duty || status != step.status {
+			t.Errorf("step %d at %.3f°C: boosted %v duty %d status %s, want boosted %v duty %d status %s",
+				i, step.celsius, check.boosted, fan.duty, status, step.boosted, step.duty, step.status)
+		}
+	}
+}
+
+// newTestPWMFan returns a fan whose pwm1 and pwm1_enable files are in a temporary
+// directory, with pwm_enable set to enable
+func newTestPWMFan(t *testing.T, enable string) (*Fan, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	pwm, pwmEnable := filepath.Join(dir, "pwm1"), filepath.Join(dir, "pwm1_enable")
+	for path, value := range map[string]string{pwm: "102", pwmEnable: enable} {
+		if err := os.WriteFile(path, []byte(value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fan := NewFan("fan", 0, ThresholdsConfig{}.withDefaults().Fan, newTestLogger(), NewMemoryStore(), nil)
+	fan.setHwmon(hwmonFan{inputs: []string{filepath.Join(dir, "fan1_input")}, pwm: pwm, pwmEnable: pwmEnable})
+	fan.duty = 40
+	return fan, pwm, pwmEnable
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func TestFanBoostTakesManualControl(t *testing.T) {
+	fan, pwm, pwmEnable := newTestPWMFan(t, "2")
+
+	if err := fan.boost("cpu", 100); err != nil {
+		t.Fatalf("boost: %v", err)
+	}
+	if got := readTestFile(t, pwmEnable); got != "1" {
+		t.Errorf("pwm_enable %s while boosted, want 1 (manual)", got)
+	}
+	if got := readTestFile(t, pwm); got != "255" {
+		t.Errorf("pwm %s while boosted, want 255", got)
+	}
+
+	// A second sensor doesn't save the manual mode as the one to restore
+	if err := fan.boost("gpu", 80); err != nil {
+		t.Fatalf("second boost: %v", err)
+	}
+	fan.unboost("cpu")
+	if got := readTestFile(t, pwmEnable); got != "1" {
+		t.Errorf("pwm_enable %s while another sensor still boosts, want 1", got)
+	}
+	if err := fan.unboost("gpu"); err != nil {
+		t.Fatalf("unboost: %v", err)
+	}
+	if got := readTestFile(t, pwmEnable); got != "2" {
+		t.Errorf("pwm_enable %s after the boost, want the driver's automatic mode 2 back", got)
+	}
+}
+
+func TestFanBoostRestoresManualDuty(t *testing.T) {
+	fan, pwm, pwmEnable := newTestPWMFan(t, "1")
+
+	if err := fan.boost("cpu", 100); err != nil {
+		t.Fatalf("boost: %v", err)
+	}
+	if err := fan.unboost("cpu"); err != nil {
+		t.Fatalf("unboost: %v", err)
+	}
+	if got := readTestFile(t, pwmEnable); got != "1" {
+		t.Errorf("pwm_enable %s after the boost, want manual kept", got)
+	}
+	if got := readTestFile(t, pwm); got != "102" || fan.duty != 40 {
+		t.Errorf("pwm %s duty %d after the boost, want the previous 102 (40%%)", got, fan.duty)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+const defaultCtlTimeout = 30 * time.Second
+
+// runCtl implements "hostd ctl <action> <process>": it sends a command to the running
+// daemon over Redis, waits for the reply and prints it. It returns the exit code.
+func runCtl(args []string) int {
+	flags := flag.NewFlagSet("ctl", flag.ContinueOnError)
+	configFile := flags.String("config", "", "daemon config file with the Redis settings (default $HOSTD_CONFIG or config.json)")
+	timeout := flags.Duration("timeout", defaultCtlTimeout, "how long to wait for the reply")
+	status := flags.String("status", "down", "inject-status: status to force, up, down or unhealthy")
+	duration := flags.Duration("duration", defaultInjectDuration, "inject-status: how long before the status reverts")
+	token := flags.String("token", os.Getenv("HOSTD_INJECT_TOKEN"), "inject-status: the daemon's injectStatusToken (default $HOSTD_INJECT_TOKEN)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: hostd ctl [flags] start|stop|restart|status|reset-stats <process>\n")
+		fmt.Fprintf(flags.Output(), "       hostd ctl [flags] inject-status <process>\n")
+		fmt.Fprintf(flags.Output(), "       hostd ctl [flags] reload-hardware\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	reloadHardware := flags.NArg() == 1 && flags.Arg(0) == ActionReloadHardware
+	if flags.NArg() != 2 && !reloadHardware {
+		flags.Usage()
+		return 2
+	}
+
+	config, err := loadConfig(configPath(*configFile, envConfigFile, "config.json"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 1
+	}
+
+	redisClient, err := NewRedisClient(&config.Redis, newConsoleLogger())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to Redis: %v\n", err)
+		return 1
+	}
+	defer redisClient.Close()
+
+	id, err := newUUID()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate a request ID: %v\n", err)
+		return 1
+	}
+	cmd := Command{
+		Action:  flags.Arg(0),
+		Process: flags.Arg(1),
+		ReplyTo: redisClient.key("hostd", "reply", id),
+		ID:      id,
+	}
+	if cmd.Action == ActionInjectStatus {
+		cmd.Status = *status
+		cmd.Duration = Duration{*duration}
+		cmd.Token = *token
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	reply, err := redisClient.SendCommand(ctx, cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %v\n", cmd.Action, cmd.Process, err)
+		return 1
+	}
+	if reply.Status != ReplyStatusOK {
+		fmt.Fprintf(os.Stderr, "%s %s failed: %s\n", cmd.Action, cmd.Process, reply.Error)
+		return 1
+	}
+
+	if reply.Result == nil {
+		fmt.Printf("%s %s: ok\n", cmd.Action, cmd.Process)
+		return 0
+	}
+	out, err := json.MarshalIndent(reply.Result, "", "    ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to format result: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(out))
+	return 0
+}
+
+// newUUID returns a random (version 4) UUID for identifying a command
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
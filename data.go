@@ -1,49 +1,906 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+// gzipMagic starts every gzip stream and marks compressed values; JSON can never start with it
+const gzipMagic = "\x1f\x8b"
+
+const (
+	defaultEventStream       = "hostd:events"
+	defaultEventStreamMaxLen = 10000
+
+	commandResultStream       = "hostd:command_results"
+	commandResultStreamMaxLen = 10000
+)
+
+const (
+	redisRetryAttempts     = 4                      // tries per operation when Redis is unreachable
+	redisRetryBaseDelay    = 100 * time.Millisecond // first backoff delay, doubled on every retry
+	redisReconnectMaxDelay = 30 * time.Second       // cap on the delay between resubscribe attempts
+	defaultRedisOpTimeout  = 5 * time.Second        // limit on a single attempt of an operation
+
+	redisSubscriptionStable = 10 * time.Second // subscription uptime after which the resubscribe backoff resets
+)
+
 // RedisClient wraps Redis operations
 type RedisClient struct {
-	client *redis.Client
+	client            *redis.Client
+	readClient        *redis.Client // optional read replica for status queries, nil if not configured
+	compressThreshold int           // values larger than this are gzipped, 0 disables
+	eventStream       string        // stream process state transitions are added to
+	eventStreamMaxLen int64         // approximate length the event stream is trimmed to
+	historyLength     int64         // metrics samples kept per FRU, 0 disables history
+	keyPrefix         string        // namespace prepended to every key and channel, empty for none
+	keyTTL            time.Duration // expiry of status and metrics keys, 0 for none
+	opTimeout         time.Duration // limit on a single attempt of an operation
+	healthy           atomic.Bool   // whether the last operation on the primary reached Redis
+	logger            *Logger
+
+	subscribing atomic.Bool   // whether a SubscribeToCommands loop is running
+	subMu       sync.Mutex    // guards commandSub
+	commandSub  *redis.PubSub // the open command subscription, nil between subscriptions
 }
 
 // NewRedisClient creates a new Redis client
-func NewRedisClient(config *RedisConfig) (*RedisClient, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", config.Host, config.Port),
-		Password: config.Password,
-		DB:       config.DB,
-	})
+func NewRedisClient(config *RedisConfig, logger *Logger) (*RedisClient, error) {
+	tlsConfig, err := redisTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	client := redisPrimaryClient(config, tlsConfig)
 
 	// Test connection
-	if err := client.Ping(context.Background()).Err(); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
 		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
 	}
 
-	return &RedisClient{
-		client: client,
-	}, nil
+	r := &RedisClient{
+		client:            client,
+		compressThreshold: config.CompressThreshold,
+		eventStream:       config.EventStream,
+		eventStreamMaxLen: config.EventStreamMaxLen,
+		historyLength:     int64(config.HardwareHistoryLength),
+		opTimeout:         config.OpTimeout.Duration,
+		keyPrefix:         config.KeyPrefix,
+		keyTTL:            config.KeyTTL.Duration,
+		logger:            logger,
+	}
+	if r.opTimeout <= 0 {
+		r.opTimeout = defaultRedisOpTimeout
+	}
+	if r.eventStream == "" {
+		r.eventStream = defaultEventStream
+	}
+	if r.eventStreamMaxLen <= 0 {
+		r.eventStreamMaxLen = defaultEventStreamMaxLen
+	}
+	r.healthy.Store(true)
+
+	if config.ReadReplica != nil {
+		r.readClient = redis.NewClient(redisOptions(config, config.ReadReplica.Host, config.ReadReplica.Port, tlsConfig))
+
+		// Reads fall back to the primary, so an unreachable replica is not fatal
+		if err := r.readClient.Ping(ctx).Err(); err != nil {
+			r.logger.Error("Redis read replica %s unreachable, reads will fall back to primary: %v",
+				r.readClient.Options().Addr, err)
+		}
+	}
+
+	return r, nil
+}
+
+// redisPrimaryClient creates the client for the primary: a failover client that asks the
+// sentinels for the current primary when Sentinel is configured, a single-node client otherwise
+func redisPrimaryClient(config *RedisConfig, tlsConfig *tls.Config) *redis.Client {
+	if config.Sentinel == nil {
+		return redis.NewClient(redisOptions(config, config.Host, config.Port, tlsConfig))
+	}
+	return redis.NewFailoverClient(&redis.FailoverOptions{
+		MasterName:       config.Sentinel.MasterName,
+		SentinelAddrs:    config.Sentinel.SentinelAddrs,
+		SentinelPassword: config.Sentinel.SentinelPassword,
+		Username:         config.Username,
+		Password:         config.Password,
+		DB:               config.DB,
+		TLSConfig:        tlsConfig,
+	})
+}
+
+// redisOptions builds the connection options for a Redis server at host:port
+func redisOptions(config *RedisConfig, host string, port int, tlsConfig *tls.Config) *redis.Options {
+	return &redis.Options{
+		Addr:      fmt.Sprintf("%s:%d", host, port),
+		Username:  config.Username,
+		Password:  config.Password,
+		DB:        config.DB,
+		TLSConfig: tlsConfig,
+	}
+}
+
+// redisTLSConfig builds the TLS settings for Redis connections, nil if TLS is disabled
+func redisTLSConfig(config *RedisConfig) (*tls.Config, error) {
+	if !config.TLS {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.TLSSkipVerify,
+	}
+
+	if config.CACertFile != "" {
+		pem, err := os.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading Redis CA certificate: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in Redis CA file %s", config.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// key builds a Redis key or channel name from its parts, namespaced by the configured
+// key prefix. Every key and channel hostd uses must be built with it.
+func (r *RedisClient) key(parts ...string) string {
+	if r.keyPrefix != "" {
+		parts = append([]string{r.keyPrefix}, parts...)
+	}
+	return strings.Join(parts, ":")
 }
 
-// Close closes the Redis connection
+// Close closes the Redis connections
 func (r *RedisClient) Close() error {
+	r.closeCommandSub(nil)
+	if r.readClient != nil {
+		r.readClient.Close()
+	}
 	return r.client.Close()
 }
 
+// Healthy reports whether the primary was reachable on the last operation
+func (r *RedisClient) Healthy() bool {
+	return r.healthy.Load()
+}
+
+// setHealthy records the connection state of the primary, logging transitions
+func (r *RedisClient) setHealthy(healthy bool) {
+	if r.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		r.logger.Info("Redis connection restored")
+	} else {
+		r.logger.Error("Redis connection lost, retrying with backoff")
+	}
+}
+
+// isConnectionError reports whether err means Redis could not be reached, as opposed to
+// a missing key, an error reply from the server or a cancelled context
+func isConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var replyErr redis.Error
+	return !errors.As(err, &replyErr)
+}
+
+// withTimeout bounds a single Redis call, so a stuck server can't block the caller
+// for longer than the operation timeout
+func (r *RedisClient) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, r.opTimeout)
+}
+
+// timedOut reports whether err is the operation timeout expiring, as opposed to the
+// caller's context, and logs it
+func (r *RedisClient) timedOut(ctx, opCtx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil || opCtx.Err() != context.DeadlineExceeded {
+		return false
+	}
+	r.logger.Error("Redis operation timed out after %v: %v", r.opTimeout, err)
+	return true
+}
+
+// withRetry runs op against the primary, retrying connection errors with exponential
+// backoff. The client's connection pool redials on each attempt, so once Redis is back
+// the next retry succeeds. Each attempt gets the operation timeout; an attempt that
+// times out is not retried, since a server that stopped answering rarely recovers
+// within the backoff.
+func (r *RedisClient) withRetry(ctx context.Context, op func(ctx context.Context) error) error {
+	delay := redisRetryBaseDelay
+	for attempt := 1; ; attempt++ {
+		opCtx, cancel := r.withTimeout(ctx)
+		err := op(opCtx)
+		cancel()
+		if r.timedOut(ctx, opCtx, err) {
+			r.setHealthy(false)
+			return err
+		}
+		if !isConnectionError(err) {
+			// Any reply, even an error, proves Redis is reachable; a cancelled context proves nothing
+			if ctx.Err() == nil {
+				r.setHealthy(true)
+			}
+			return err
+		}
+		r.setHealthy(false)
+
+		if attempt == redisRetryAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// set writes a key on the primary, expiring it after ttl unless ttl is 0
+func (r *RedisClient) set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return r.withRetry(ctx, func(ctx context.Context) error {
+		return r.client.Set(ctx, key, value, ttl).Err()
+	})
+}
+
+// getPrimary reads a key from the primary
+func (r *RedisClient) getPrimary(ctx context.Context, key string) (string, error) {
+	var value string
+	err := r.withRetry(ctx, func(ctx context.Context) (err error) {
+		value, err = r.client.Get(ctx, key).Result()
+		return err
+	})
+	return value, err
+}
+
+// get reads a key from the read replica if one is configured, falling back to the primary
+// on any replica error other than a missing key
+func (r *RedisClient) get(ctx context.Context, key string) (string, error) {
+	if r.readClient != nil {
+		opCtx, cancel := r.withTimeout(ctx)
+		value, err := r.readClient.Get(opCtx, key).Result()
+		cancel()
+		r.timedOut(ctx, opCtx, err)
+		if err == nil || err == redis.Nil {
+			return value, err
+		}
+		r.logger.Error("Error reading %s from Redis read replica, falling back to primary: %v", key, err)
+	}
+	return r.getPrimary(ctx, key)
+}
+
+// mget reads several keys in one round trip, from the read replica if one is configured
+// and falling back to the primary on any replica error. Missing keys read as nil.
+func (r *RedisClient) mget(ctx context.Context, keys []string) ([]interface{}, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	if r.readClient != nil {
+		opCtx, cancel := r.withTimeout(ctx)
+		values, err := r.readClient.MGet(opCtx, keys...).Result()
+		cancel()
+		r.timedOut(ctx, opCtx, err)
+		if err == nil {
+			return values, nil
+		}
+		r.logger.Error("Error reading %d keys from Redis read replica, falling back to primary: %v", len(keys), err)
+	}
+
+	var values []interface{}
+	err := r.withRetry(ctx, func(ctx context.Context) (err error) {
+		values, err = r.client.MGet(ctx, keys...).Result()
+		return err
+	})
+	return values, err
+}
+
+// compress gzips a value if it is larger than the configured threshold
+func (r *RedisClient) compress(value string) (string, error) {
+	if r.compressThreshold <= 0 || len(value) <= r.compressThreshold {
+		return value, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(value)); err != nil {
+		return "", fmt.Errorf("error compressing value: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("error compressing value: %v", err)
+	}
+
+	return buf.String(), nil
+}
+
+// decompress transparently inflates values written by compress, leaving others untouched
+func decompress(value string) (string, error) {
+	if !strings.HasPrefix(value, gzipMagic) {
+		return value, nil
+	}
+
+	zr, err := gzip.NewReader(strings.NewReader(value))
+	if err != nil {
+		return "", fmt.Errorf("error decompressing value: %v", err)
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return "", fmt.Errorf("error decompressing value: %v", err)
+	}
+
+	return string(data), nil
+}
+
 // UpdateProcessStatus updates the status of a process in Redis
 func (r *RedisClient) UpdateProcessStatus(ctx context.Context, processName string, status string) error {
-	key := fmt.Sprintf("process:%s:status", processName)
-	return r.client.Set(ctx, key, status, 0).Err()
+	key := r.key("process", processName, "status")
+	value, err := r.compress(status)
+	if err != nil {
+		return err
+	}
+	return r.set(ctx, key, value, r.keyTTL)
 }
 
-// GetProcessStatus gets the status of a process from Redis
+// GetProcessStatus gets the status of a process from Redis, preferring the read replica
 func (r *RedisClient) GetProcessStatus(ctx context.Context, processName string) (string, error) {
-	key := fmt.Sprintf("process:%s:status", processName)
-	return r.client.Get(ctx, key).Result()
+	key := r.key("process", processName, "status")
+	value, err := r.get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return decompress(value)
+}
+
+// GetAllProcessStatuses gets the status of several processes in one round trip, preferring
+// the read replica. Processes without a stored status are left out of the result.
+func (r *RedisClient) GetAllProcessStatuses(ctx context.Context, processNames []string) (map[string]*ProcessStatus, error) {
+	keys := make([]string, len(processNames))
+	for i, name := range processNames {
+		keys[i] = r.key("process", name, "status")
+	}
+	values, err := r.mget(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[string]*ProcessStatus, len(values))
+	for i, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue // missing key
+		}
+		data, err := decompress(raw)
+		if err != nil {
+			return nil, err
+		}
+		var status ProcessStatus
+		if err := json.Unmarshal([]byte(data), &status); err != nil {
+			return nil, fmt.Errorf("error decoding status of process %s: %v", processNames[i], err)
+		}
+		statuses[processNames[i]] = &status
+	}
+	return statuses, nil
+}
+
+// GetProcessStatusFresh gets the status of a process from the primary, for callers that
+// cannot tolerate replication lag such as read-modify-write updates
+func (r *RedisClient) GetProcessStatusFresh(ctx context.Context, processName string) (string, error) {
+	key := r.key("process", processName, "status")
+	value, err := r.getPrimary(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return decompress(value)
+}
+
+// GetUptimeStats gets uptime accumulators stored by older versions under their own key,
+// used only to migrate them into the runtime state
+func (r *RedisClient) GetUptimeStats(ctx context.Context, processName string) (string, error) {
+	key := r.key("process", processName, "uptime")
+	return r.getPrimary(ctx, key)
+}
+
+// UpdateUptimeRatio stores the uptime ratio of a process in Redis
+func (r *RedisClient) UpdateUptimeRatio(ctx context.Context, processName string, ratio float64) error {
+	key := r.key("process", processName, "uptime_ratio")
+	return r.set(ctx, key, strconv.FormatFloat(ratio, 'f', 6, 64), r.keyTTL)
+}
+
+// GetRuntimeState gets the persisted runtime state of a process from the primary
+func (r *RedisClient) GetRuntimeState(ctx context.Context, processName string) (string, error) {
+	key := r.key("process", processName, "runtime")
+	return r.getPrimary(ctx, key)
+}
+
+// UpdateRuntimeState stores the runtime state of a process in Redis. It never expires,
+// so restart and flapping history survive a daemon that is down for longer than the TTL.
+func (r *RedisClient) UpdateRuntimeState(ctx context.Context, processName string, state string) error {
+	key := r.key("process", processName, "runtime")
+	return r.set(ctx, key, state, 0)
+}
+
+// DeleteProcessKeys removes everything stored in Redis for a process
+func (r *RedisClient) DeleteProcessKeys(ctx context.Context, processName string) error {
+	return r.withRetry(ctx, func(ctx context.Context) error {
+		return r.client.Del(ctx,
+			r.key("process", processName, "status"),
+			r.key("process", processName, "uptime"),
+			r.key("process", processName, "uptime_ratio"),
+			r.key("process", processName, "runtime"),
+		).Err()
+	})
+}
+
+// AddEvent appends an event to the event stream, trimming it to about the configured length
+func (r *RedisClient) AddEvent(ctx context.Context, values map[string]interface{}) error {
+	return r.withRetry(ctx, func(ctx context.Context) error {
+		return r.client.XAdd(ctx, &redis.XAddArgs{
+			Stream: r.key(r.eventStream),
+			MaxLen: r.eventStreamMaxLen,
+			Approx: true,
+			Values: values,
+		}).Err()
+	})
+}
+
+// UpdateHardwareMetrics stores the latest metrics of a FRU in Redis. With history enabled
+// the sample is also pushed onto the FRU's capped history list in the same transaction.
+func (r *RedisClient) UpdateHardwareMetrics(ctx context.Context, fruType string, instance int, metrics string) error {
+	key := r.key("hardware", fruType, strconv.Itoa(instance), "metrics")
+	if r.historyLength <= 0 {
+		return r.set(ctx, key, metrics, r.keyTTL)
+	}
+
+	historyKey := r.key("hardware", fruType, strconv.Itoa(instance), "history")
+	return r.withRetry(ctx, func(ctx context.Context) error {
+		_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, metrics, r.keyTTL)
+			pipe.LPush(ctx, historyKey, metrics)
+			pipe.LTrim(ctx, historyKey, 0, r.historyLength-1)
+			if r.keyTTL > 0 {
+				pipe.Expire(ctx, historyKey, r.keyTTL)
+			}
+			return nil
+		})
+		return err
+	})
+}
+
+// UpdateHardwareMetricsBatch stores the metrics of many FRUs in one pipelined round trip,
+// pushing each sample onto its history list as UpdateHardwareMetrics does. It makes a
+// single attempt and returns the writes that failed, leaving their retries to the
+// caller's fallback; writes that went through must not be repeated, or their samples
+// would be pushed onto the history twice.
+func (r *RedisClient) UpdateHardwareMetricsBatch(ctx context.Context, writes []HardwareMetricsWrite) ([]HardwareMetricsWrite, error) {
+	opCtx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	cmds := make([][]redis.Cmder, len(writes))
+	_, err := r.client.Pipelined(opCtx, func(pipe redis.Pipeliner) error {
+		for i, w := range writes {
+			instance := strconv.Itoa(w.Instance)
+			cmds[i] = append(cmds[i], pipe.Set(opCtx, r.key("hardware", w.FruType, instance, "metrics"), w.Metrics, r.keyTTL))
+			if r.historyLength <= 0 {
+				continue
+			}
+			historyKey := r.key("hardware", w.FruType, instance, "history")
+			cmds[i] = append(cmds[i], pipe.LPush(opCtx, historyKey, w.Metrics))
+			cmds[i] = append(cmds[i], pipe.LTrim(opCtx, historyKey, 0, r.historyLength-1))
+			if r.keyTTL > 0 {
+				cmds[i] = append(cmds[i], pipe.Expire(opCtx, historyKey, r.keyTTL))
+			}
+		}
+		return nil
+	})
+	r.timedOut(ctx, opCtx, err)
+	if err == nil {
+		return nil, nil
+	}
+
+	// A write failed if any of its commands did; on a connection error that is all of them
+	var failed []HardwareMetricsWrite
+	for i, w := range writes {
+		for _, cmd := range cmds[i] {
+			if cmd.Err() != nil {
+				failed = append(failed, w)
+				break
+			}
+		}
+	}
+	return failed, err
+}
+
+// GetHardwareHistory returns the stored metrics samples of a FRU, newest first,
+// preferring the read replica
+func (r *RedisClient) GetHardwareHistory(ctx context.Context, fruType string, instance int) ([]string, error) {
+	key := r.key("hardware", fruType, strconv.Itoa(instance), "history")
+	if r.readClient != nil {
+		opCtx, cancel := r.withTimeout(ctx)
+		samples, err := r.readClient.LRange(opCtx, key, 0, -1).Result()
+		cancel()
+		r.timedOut(ctx, opCtx, err)
+		if err == nil {
+			return samples, nil
+		}
+		r.logger.Error("Error reading %s from Redis read replica, falling back to primary: %v", key, err)
+	}
+
+	var samples []string
+	err := r.withRetry(ctx, func(ctx context.Context) (err error) {
+		samples, err = r.client.LRange(ctx, key, 0, -1).Result()
+		return err
+	})
+	return samples, err
+}
+
+// UpdateHardwareReadFailures stores the failed read counts of a FRU in Redis
+func (r *RedisClient) UpdateHardwareReadFailures(ctx context.Context, fruType string, instance int, failures string) error {
+	return r.set(ctx, r.key("hardware", fruType, strconv.Itoa(instance), "read_failures"), failures, r.keyTTL)
+}
+
+// UpdateChassisStatus stores the aggregate status of all FRUs in Redis
+func (r *RedisClient) UpdateChassisStatus(ctx context.Context, status string) error {
+	return r.set(ctx, r.key("hardware", "overall", "status"), status, r.keyTTL)
+}
+
+// UpdatePowerRedundancy stores the power redundancy of the chassis in Redis
+func (r *RedisClient) UpdatePowerRedundancy(ctx context.Context, status string) error {
+	return r.set(ctx, r.key("hardware", "power", "redundancy"), status, r.keyTTL)
+}
+
+// UpdateDiskStatus stores the usage of a mount point in Redis
+func (r *RedisClient) UpdateDiskStatus(ctx context.Context, mount string, status string) error {
+	return r.set(ctx, r.key("system", "disk", mount), status, r.keyTTL)
+}
+
+// UpdateSystemStatus stores a system-wide reading, such as the load average, in Redis
+func (r *RedisClient) UpdateSystemStatus(ctx context.Context, name string, status string) error {
+	return r.set(ctx, r.key("system", name), status, r.keyTTL)
+}
+
+// scanKeys lists the keys matching a pattern, preferring the read replica
+func (r *RedisClient) scanKeys(ctx context.Context, pattern string) ([]string, error) {
+	scan := func(ctx context.Context, client *redis.Client) ([]string, error) {
+		var keys []string
+		iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		return keys, iter.Err()
+	}
+
+	if r.readClient != nil {
+		opCtx, cancel := r.withTimeout(ctx)
+		keys, err := scan(opCtx, r.readClient)
+		cancel()
+		r.timedOut(ctx, opCtx, err)
+		if err == nil {
+			return keys, nil
+		}
+		r.logger.Error("Error scanning %s on Redis read replica, falling back to primary: %v", pattern, err)
+	}
+
+	var keys []string
+	err := r.withRetry(ctx, func(ctx context.Context) (err error) {
+		keys, err = scan(ctx, r.client)
+		return err
+	})
+	return keys, err
+}
+
+// getByPattern returns the decompressed values of all keys matching pattern, indexed by
+// the part of the key between prefix and suffix, reading them in one round trip. Keys
+// that vanish while reading are skipped.
+func (r *RedisClient) getByPattern(ctx context.Context, prefix, suffix string) (map[string]string, error) {
+	keys, err := r.scanKeys(ctx, prefix+"*"+suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := r.mget(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(keys))
+	for i, key := range keys {
+		value, ok := raw[i].(string)
+		if !ok {
+			continue
+		}
+		if value, err = decompress(value); err != nil {
+			return nil, err
+		}
+		values[strings.TrimSuffix(strings.TrimPrefix(key, prefix), suffix)] = value
+	}
+
+	return values, nil
+}
+
+// DeleteStaleProcessKeys deletes the keys of every process with a stored status that is
+// not in keep, returning the names of the processes cleaned up. Only keys under this
+// client's prefix are scanned, so other daemons sharing Redis with their own prefix are
+// left alone.
+func (r *RedisClient) DeleteStaleProcessKeys(ctx context.Context, keep map[string]bool) ([]string, error) {
+	prefix, suffix := r.key("process")+":", ":status"
+	keys, err := r.scanKeys(ctx, prefix+"*"+suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []string
+	for _, key := range keys {
+		name := strings.TrimSuffix(strings.TrimPrefix(key, prefix), suffix)
+		if keep[name] {
+			continue
+		}
+		if err := r.DeleteProcessKeys(ctx, name); err != nil {
+			return deleted, fmt.Errorf("error deleting keys of process %s: %v", name, err)
+		}
+		deleted = append(deleted, name)
+	}
+	return deleted, nil
+}
+
+// ListHardwareMetrics returns the stored metrics of every FRU, keyed by "<type>:<instance>"
+func (r *RedisClient) ListHardwareMetrics(ctx context.Context) (map[string]string, error) {
+	return r.getByPattern(ctx, r.key("hardware")+":", ":metrics")
+}
+
+// ListSystemStatus returns the stored system-wide readings, keyed "load", "memory" and
+// "disk:<mount>"
+func (r *RedisClient) ListSystemStatus(ctx context.Context) (map[string]string, error) {
+	return r.getByPattern(ctx, r.key("system")+":", "")
+}
+
+// CommandHandler executes a command and returns the result to reply with, if any
+type CommandHandler func(ctx context.Context, cmd Command) (interface{}, error)
+
+// SubscribeToCommands listens on the hostd:commands channel and passes each parsed command
+// to handler until ctx is cancelled. If the subscription cannot be set up or is lost, it
+// resubscribes with exponential backoff. Only one loop runs per client: a second call
+// while one is running returns straight away, so a command is never handled twice.
+func (r *RedisClient) SubscribeToCommands(ctx context.Context, handler CommandHandler) {
+	if !r.subscribing.CompareAndSwap(false, true) {
+		r.logger.Error("Already subscribed to commands, ignoring a second subscription")
+		return
+	}
+	defer r.subscribing.Store(false)
+
+	delay := redisRetryBaseDelay
+	for {
+		started := time.Now()
+		subscribed, err := r.subscribeOnce(ctx, handler)
+		if ctx.Err() != nil {
+			return
+		}
+		// A subscription the server accepts and then drops straight away keeps backing
+		// off, so a flapping connection can't turn into a tight resubscribe loop
+		if subscribed && time.Since(started) >= redisSubscriptionStable {
+			delay = redisRetryBaseDelay
+		}
+
+		r.logger.Error("Command subscription lost, resubscribing in %v: %v", delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if delay *= 2; delay > redisReconnectMaxDelay {
+			delay = redisReconnectMaxDelay
+		}
+	}
+}
+
+// recordCommandResult adds the outcome of a command to the command result stream, an
+// audit trail of control actions that doesn't depend on anyone listening for a reply
+func (r *RedisClient) recordCommandResult(ctx context.Context, cmd Command, cmdErr error) {
+	values := map[string]interface{}{
+		"id":      cmd.ID,
+		"action":  cmd.Action,
+		"process": cmd.Process,
+		"status":  ReplyStatusOK,
+		"ts":      time.Now().Format(time.RFC3339Nano),
+	}
+	if cmdErr != nil {
+		values["status"] = ReplyStatusError
+		values["error"] = cmdErr.Error()
+	}
+
+	if err := r.withRetry(ctx, func(ctx context.Context) error {
+		return r.client.XAdd(ctx, &redis.XAddArgs{
+			Stream: r.key(commandResultStream),
+			MaxLen: commandResultStreamMaxLen,
+			Approx: true,
+			Values: values,
+		}).Err()
+	}); err != nil {
+		r.logger.Error("Error recording result of command %s: %v", cmd.ID, err)
+	}
+}
+
+// reply publishes the outcome of a command on its reply channel
+func (r *RedisClient) reply(ctx context.Context, cmd Command, result interface{}, err error) {
+	reply := CommandReply{
+		ID:      cmd.ID,
+		Action:  cmd.Action,
+		Process: cmd.Process,
+		Status:  ReplyStatusOK,
+		Result:  result,
+	}
+	if err != nil {
+		reply.Status = ReplyStatusError
+		reply.Error = err.Error()
+	}
+
+	payload, err := json.Marshal(reply)
+	if err != nil {
+		r.logger.Error("Error marshaling reply to %s: %v", cmd.ReplyTo, err)
+		return
+	}
+	if err := r.withRetry(ctx, func(ctx context.Context) error {
+		return r.client.Publish(ctx, cmd.ReplyTo, payload).Err()
+	}); err != nil {
+		r.logger.Error("Error publishing reply to %s: %v", cmd.ReplyTo, err)
+	}
+}
+
+// SendCommand publishes a command and waits for its reply until ctx is done. The command
+// must carry a ReplyTo channel, which is subscribed before publishing so the reply can't be missed.
+func (r *RedisClient) SendCommand(ctx context.Context, cmd Command) (*CommandReply, error) {
+	if cmd.ReplyTo == "" {
+		return nil, errors.New("command has no reply channel")
+	}
+
+	pubsub := r.client.Subscribe(ctx, cmd.ReplyTo)
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return nil, fmt.Errorf("error subscribing to %s: %v", cmd.ReplyTo, err)
+	}
+
+	payload, err := json.Marshal(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling command: %v", err)
+	}
+	channel := r.key("hostd", "commands")
+	receivers, err := r.client.Publish(ctx, channel, payload).Result()
+	if err != nil {
+		return nil, fmt.Errorf("error publishing command: %v", err)
+	}
+	if receivers == 0 {
+		return nil, fmt.Errorf("no hostd daemon is subscribed to %s", channel)
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return nil, errors.New("reply subscription closed")
+			}
+
+			var reply CommandReply
+			if err := json.Unmarshal([]byte(msg.Payload), &reply); err != nil {
+				return nil, fmt.Errorf("error parsing reply: %v", err)
+			}
+			if reply.ID != cmd.ID {
+				continue
+			}
+			return &reply, nil
+		case <-ctx.Done():
+			return nil, fmt.Errorf("no reply within the timeout: %v", ctx.Err())
+		}
+	}
+}
+
+// subscribeOnce runs a single subscription until it breaks or ctx is cancelled. It reports
+// whether the subscription was established and why it ended. The subscription is closed
+// on every way out, so a reconnect never leaves the previous one open.
+func (r *RedisClient) subscribeOnce(ctx context.Context, handler CommandHandler) (bool, error) {
+	pubsub := r.client.Subscribe(ctx, r.key("hostd", "commands"))
+	r.setCommandSub(pubsub)
+	defer r.closeCommandSub(pubsub)
+
+	// Wait for confirmation that subscription is created before publishing anything
+	_, err := pubsub.Receive(ctx)
+	if err != nil {
+		if isConnectionError(err) {
+			r.setHealthy(false)
+		}
+		return false, fmt.Errorf("error receiving subscription confirmation: %v", err)
+	}
+	r.setHealthy(true)
+
+	ch := pubsub.Channel()
+
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return true, errors.New("subscription channel closed")
+			}
+
+			var cmd Command
+			if err := json.Unmarshal([]byte(msg.Payload), &cmd); err != nil {
+				r.logger.Error("Error parsing command: %v", err)
+				continue
+			}
+			if cmd.ID == "" {
+				if cmd.ID, err = newUUID(); err != nil {
+					r.logger.Error("Error generating command ID: %v", err)
+				}
+			}
+
+			result, err := handler(ctx, cmd)
+			if err != nil {
+				r.logger.Error("Error handling command: %v", err)
+			}
+			r.recordCommandResult(ctx, cmd, err)
+			if cmd.ReplyTo != "" {
+				r.reply(ctx, cmd, result, err)
+			}
+		case <-ctx.Done():
+			return true, ctx.Err()
+		}
+	}
+}
+
+// setCommandSub records the open command subscription, closing any earlier one still
+// recorded so at most one is open at a time
+func (r *RedisClient) setCommandSub(pubsub *redis.PubSub) {
+	r.subMu.Lock()
+	defer r.subMu.Unlock()
+	if r.commandSub != nil && r.commandSub != pubsub {
+		r.commandSub.Close()
+	}
+	r.commandSub = pubsub
+}
+
+// closeCommandSub closes pubsub and forgets it if it is the recorded subscription. A nil
+// pubsub closes whichever subscription is recorded.
+func (r *RedisClient) closeCommandSub(pubsub *redis.PubSub) {
+	r.subMu.Lock()
+	defer r.subMu.Unlock()
+	if pubsub == nil {
+		pubsub = r.commandSub
+	}
+	if pubsub == nil {
+		return
+	}
+	if pubsub == r.commandSub {
+		r.commandSub = nil
+	}
+	if err := pubsub.Close(); err != nil && !errors.Is(err, redis.ErrClosed) {
+		r.logger.Error("Error closing command subscription: %v", err)
+	}
 }
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// benchmarkStatus returns the JSON of a process status padded with drifted environment
+// entries until it is about size bytes, like a status carrying a captured environment
+func benchmarkStatus(size int) string {
+	status := ProcessStatus{
+		SchemaVersion: processStatusSchemaVersion,
+		Name:          "api",
+		CurrentPID:    4242,
+		PIDs:          []int{4242},
+		InstanceCount: 1,
+		Status:        "up",
+		LastChange:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		StartTime:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		CurrentMemory: 512 << 20,
+		CurrentCPU:    12.5,
+		UptimeRatio:   0.999,
+	}
+	for i := 0; ; i++ {
+		data, _ := json.Marshal(status)
+		if len(data) >= size {
+			return string(data)
+		}
+		status.EnvDrift = true
+		status.DriftedEnv = append(status.DriftedEnv,
+			fmt.Sprintf("APP_SETTING_%d: expected=/srv/app/config/value-%d actual=/srv/app/config/value-%d", i, i, i+1))
+	}
+}
+
+// BenchmarkCompress measures the CPU cost of gzipping status values of several sizes
+// and reports the compressed size as a ratio of the original, to weigh when
+// redis.compressThreshold pays off
+func BenchmarkCompress(b *testing.B) {
+	r := &RedisClient{compressThreshold: 1}
+	for _, size := range []int{512, 4 << 10, 64 << 10} {
+		value := benchmarkStatus(size)
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			b.SetBytes(int64(len(value)))
+			var compressed string
+			for i := 0; i < b.N; i++ {
+				var err error
+				if compressed, err = r.compress(value); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(compressed))/float64(len(value)), "ratio")
+		})
+	}
+}
+
+// BenchmarkDecompress measures the CPU cost of inflating compressed status values on read
+func BenchmarkDecompress(b *testing.B) {
+	r := &RedisClient{compressThreshold: 1}
+	for _, size := range []int{512, 4 << 10, 64 << 10} {
+		value := benchmarkStatus(size)
+		compressed, err := r.compress(value)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			b.SetBytes(int64(len(value)))
+			for i := 0; i < b.N; i++ {
+				if _, err := decompress(compressed); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// startTLSRedis starts a fake Redis behind TLS with a certificate for 127.0.0.1 signed
+// by a freshly generated CA, and returns the fake and the path of the CA certificate
+func startTLSRedis(t *testing.T) (*fakeRedis, string) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "hostd test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "redis"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	serverDER, err := x509.CreateCertificate(rand.Reader, serverTemplate, ca, &serverKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{serverDER}, PrivateKey: serverKey}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return startFakeRedis(t, listener), caFile
+}
+
+func TestNewRedisClientTLS(t *testing.T) {
+	server, caFile := startTLSRedis(t)
+	host, port := server.addr()
+
+	tests := []struct {
+		name    string
+		config  RedisConfig
+		wantErr string
+	}{
+		{"verified by CA file", RedisConfig{TLS: true, CACertFile: caFile}, ""},
+		{"skip verify", RedisConfig{TLS: true, TLSSkipVerify: true}, ""},
+		{"unknown CA", RedisConfig{TLS: true}, "certificate"},
+		{"plaintext to TLS server", RedisConfig{}, "failed to connect"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.Host, config.Port = host, port
+			config.OpTimeout.Duration = time.Second
+
+			client, err := NewRedisClient(&config, newTestLogger())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewRedisClient: %v", err)
+				}
+				client.Close()
+				return
+			}
+			if err == nil {
+				client.Close()
+				t.Fatalf("NewRedisClient connected, want an error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewRedisClient error %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewRedisClientTLSSendsACLUsername(t *testing.T) {
+	server, caFile := startTLSRedis(t)
+	host, port := server.addr()
+
+	config := RedisConfig{Host: host, Port: port, TLS: true, CACertFile: caFile, Username: "hostd", Password: "secret"}
+	client, err := NewRedisClient(&config, newTestLogger())
+	if err != nil {
+		t.Fatalf("NewRedisClient: %v", err)
+	}
+	client.Close()
+
+	commands := server.received()
+	if len(commands) == 0 || strings.Join(commands[0], " ") != "auth hostd secret" {
+		t.Errorf("first command %v, want AUTH with the username and password", commands)
+	}
+}
+
+func TestRedisTLSConfigRejectsBadCAFile(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := redisTLSConfig(&RedisConfig{TLS: true, CACertFile: caFile}); err == nil {
+		t.Error("redisTLSConfig accepted a CA file without certificates")
+	}
+	if _, err := redisTLSConfig(&RedisConfig{TLS: true, CACertFile: caFile + ".missing"}); err == nil {
+		t.Error("redisTLSConfig accepted a missing CA file")
+	}
+}
+
+func TestSubscribeToCommandsDoesNotLeak(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := startFakeRedis(t, listener)
+	host, port := server.addr()
+
+	config := RedisConfig{Host: host, Port: port}
+	config.OpTimeout.Duration = time.Second
+	client, err := NewRedisClient(&config, newTestLogger())
+	if err != nil {
+		t.Fatalf("NewRedisClient: %v", err)
+	}
+	defer client.Close()
+	baseline := runtime.NumGoroutine()
+
+	// Watch the server for more than one subscription open at once
+	var maxSubscribers atomic.Int32
+	stopWatching := make(chan struct{})
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		for {
+			if n := int32(server.subscribers()); n > maxSubscribers.Load() {
+				maxSubscribers.Store(n)
+			}
+			select {
+			case <-stopWatching:
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	subscribes := func() int {
+		n := 0
+		for _, command := range server.received() {
+			if command[0] == "subscribe" {
+				n++
+			}
+		}
+		return n
+	}
+	subscribed := func() bool { return server.subscribers() == 1 }
+
+	// The first subscriptions are refused, so the loop has to resubscribe
+	server.rejectSubscribes(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		client.SubscribeToCommands(ctx, func(context.Context, Command) (interface{}, error) { return nil, nil })
+	}()
+	waitFor("the first subscription", subscribed)
+
+	// A second loop must not open a subscription of its own
+	client.SubscribeToCommands(ctx, func(context.Context, Command) (interface{}, error) { return nil, nil })
+
+	// Dropped connections are resubscribed
+	for i := 0; i < 3; i++ {
+		before := subscribes()
+		server.dropConnections()
+		waitFor("a resubscribe", func() bool { return subscribes() > before && subscribed() })
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SubscribeToCommands did not return after cancel")
+	}
+	waitFor("the subscription to close", func() bool { return server.subscribers() == 0 })
+	close(stopWatching)
+	<-watched
+
+	if n := maxSubscribers.Load(); n != 1 {
+		t.Errorf("up to %d subscriptions open at once, want 1", n)
+	}
+	client.subMu.Lock()
+	if client.commandSub != nil {
+		t.Error("command subscription still recorded after the loop returned")
+	}
+	client.subMu.Unlock()
+
+	if n := subscribes(); n < 6 {
+		t.Errorf("%d SUBSCRIBE commands, want at least 2 refused, 1 accepted and 3 after reconnects", n)
+	}
+
+	var goroutines int
+	deadline := time.Now().Add(5 * time.Second)
+	for goroutines = runtime.NumGoroutine(); goroutines > baseline && time.Now().Before(deadline); goroutines = runtime.NumGoroutine() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if goroutines > baseline {
+		buf := make([]byte, 1<<20)
+		t.Errorf("%d goroutines after the loop returned, %d before it started:\n%s",
+			goroutines, baseline, buf[:runtime.Stack(buf, true)])
+	}
+}
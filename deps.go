@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// dependencyCycle returns the names along a dependency cycle among procs, starting and
+// ending with the same process, or nil if there is none. Unknown dependencies are ignored.
+func dependencyCycle(procs []Process) []string {
+	byName := make(map[string]Process, len(procs))
+	for _, proc := range procs {
+		byName[proc.Name] = proc
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(procs))
+	var path []string
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case done:
+			return nil
+		case visiting:
+			// The cycle runs from the earlier visit of name to here
+			for i, seen := range path {
+				if seen == name {
+					return append(append([]string(nil), path[i:]...), name)
+				}
+			}
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range byName[name].DependsOn {
+			if _, ok := byName[dep]; !ok {
+				continue
+			}
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+
+	for _, proc := range procs {
+		if cycle := visit(proc.Name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// dependencyOrder returns procs ordered so that every process comes after its
+// dependencies, keeping config order otherwise. The config must be free of cycles.
+func dependencyOrder(procs []Process) []Process {
+	byName := make(map[string]Process, len(procs))
+	for _, proc := range procs {
+		byName[proc.Name] = proc
+	}
+
+	added := make(map[string]bool, len(procs))
+	ordered := make([]Process, 0, len(procs))
+	var add func(proc Process)
+	add = func(proc Process) {
+		if added[proc.Name] {
+			return
+		}
+		added[proc.Name] = true
+		for _, dep := range proc.DependsOn {
+			if depProc, ok := byName[dep]; ok {
+				add(depProc)
+			}
+		}
+		ordered = append(ordered, proc)
+	}
+
+	for _, proc := range procs {
+		add(proc)
+	}
+	return ordered
+}
+
+// dependencyDepths returns how deep each process sits in the dependency graph: 0 for
+// processes without dependencies, otherwise one more than their deepest dependency
+func dependencyDepths(procs []Process) map[string]int {
+	depths := make(map[string]int, len(procs))
+	for _, proc := range dependencyOrder(procs) {
+		depth := 0
+		for _, dep := range proc.DependsOn {
+			if d, ok := depths[dep]; ok && d+1 > depth {
+				depth = d + 1
+			}
+		}
+		depths[proc.Name] = depth
+	}
+	return depths
+}
+
+// withDependencies returns the processes proc depends on, directly or not, in start
+// order, followed by proc itself
+func (pm *ProcessMonitor) withDependencies(proc Process) []Process {
+	procs := pm.Processes()
+	byName := make(map[string]Process, len(procs))
+	for _, p := range procs {
+		byName[p.Name] = p
+	}
+
+	needed := map[string]bool{proc.Name: true}
+	pending := []Process{proc}
+	for len(pending) > 0 {
+		p := pending[0]
+		pending = pending[1:]
+		for _, dep := range p.DependsOn {
+			if depProc, ok := byName[dep]; ok && !needed[dep] {
+				needed[dep] = true
+				pending = append(pending, depProc)
+			}
+		}
+	}
+
+	var chain []Process
+	for _, p := range dependencyOrder(procs) {
+		if needed[p.Name] && p.Name != proc.Name {
+			chain = append(chain, p)
+		}
+	}
+	return append(chain, proc)
+}
+
+// dependents returns the processes that depend on proc, directly or not, in stop order
+func (pm *ProcessMonitor) dependents(proc Process) []Process {
+	ordered := dependencyOrder(pm.Processes())
+
+	// In start order every dependency comes first, so one pass finds them all
+	affected := map[string]bool{proc.Name: true}
+	var found []Process
+	for _, p := range ordered {
+		for _, dep := range p.DependsOn {
+			if affected[dep] && !affected[p.Name] {
+				affected[p.Name] = true
+				found = append(found, p)
+			}
+		}
+	}
+
+	for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+		found[i], found[j] = found[j], found[i]
+	}
+	return found
+}
+
+// checkDependencies returns an error naming the dependencies of proc that aren't running
+func (pm *ProcessMonitor) checkDependencies(ctx context.Context, proc Process) error {
+	if len(proc.DependsOn) == 0 {
+		return nil
+	}
+
+	var down []string
+	for _, name := range proc.DependsOn {
+		dep, ok := pm.lookupProcess(name)
+		if !ok {
+			continue // removed by a reload, nothing to wait for
+		}
+		running, err := pm.running(ctx, dep)
+		if err != nil {
+			return fmt.Errorf("error checking dependency %s of process %s: %v", name, proc.Name, err)
+		}
+		if !running {
+			down = append(down, name)
+		}
+	}
+	if len(down) > 0 {
+		return fmt.Errorf("process %s depends on %s, which is not running", proc.Name, strings.Join(down, ", "))
+	}
+	return nil
+}
+
+// running reports whether a process or its container is running
+func (pm *ProcessMonitor) running(ctx context.Context, proc Process) (bool, error) {
+	if proc.Container != "" {
+		pid, _, err := pm.docker.containerState(ctx, proc.Container)
+		return pid > 0, err
+	}
+	pid, err := pm.getProcessPID(proc)
+	return pid > 0, err
+}
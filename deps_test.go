@@ -0,0 +1,157 @@
+package main
+
+import (
+	"testing"
+)
+
+// processNames returns the names of procs in order
+func processNames(procs []Process) []string {
+	names := make([]string, len(procs))
+	for i, proc := range procs {
+		names[i] = proc.Name
+	}
+	return names
+}
+
+func TestDependencyCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		procs []Process
+		want  []string
+	}{
+		{"no dependencies", []Process{{Name: "a"}, {Name: "b"}}, nil},
+		{"chain", []Process{
+			{Name: "a", DependsOn: []string{"b"}},
+			{Name: "b", DependsOn: []string{"c"}},
+			{Name: "c"},
+		}, nil},
+		{"diamond", []Process{
+			{Name: "api", DependsOn: []string{"cache", "db"}},
+			{Name: "cache", DependsOn: []string{"db"}},
+			{Name: "db"},
+		}, nil},
+		{"self", []Process{{Name: "a", DependsOn: []string{"a"}}}, []string{"a", "a"}},
+		{"two processes", []Process{
+			{Name: "a", DependsOn: []string{"b"}},
+			{Name: "b", DependsOn: []string{"a"}},
+		}, []string{"a", "b", "a"}},
+		{"behind a chain", []Process{
+			{Name: "web", DependsOn: []string{"a"}},
+			{Name: "a", DependsOn: []string{"b"}},
+			{Name: "b", DependsOn: []string{"c"}},
+			{Name: "c", DependsOn: []string{"a"}},
+		}, []string{"a", "b", "c", "a"}},
+		{"missing dependency ignored", []Process{
+			{Name: "a", DependsOn: []string{"gone"}},
+			{Name: "b", DependsOn: []string{"a", "gone"}},
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dependencyCycle(tt.procs); !equalStrings(got, tt.want) {
+				t.Errorf("dependencyCycle = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDependencyOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		procs []Process
+		want  []string
+	}{
+		{"config order kept", []Process{{Name: "b"}, {Name: "a"}, {Name: "c"}}, []string{"b", "a", "c"}},
+		{"dependency moved first", []Process{
+			{Name: "api", DependsOn: []string{"db"}},
+			{Name: "web"},
+			{Name: "db"},
+		}, []string{"db", "api", "web"}},
+		{"transitive", []Process{
+			{Name: "web", DependsOn: []string{"api"}},
+			{Name: "api", DependsOn: []string{"db"}},
+			{Name: "db"},
+		}, []string{"db", "api", "web"}},
+		{"shared dependency once", []Process{
+			{Name: "api", DependsOn: []string{"cache", "db"}},
+			{Name: "cache", DependsOn: []string{"db"}},
+			{Name: "db"},
+		}, []string{"db", "cache", "api"}},
+		{"missing dependency ignored", []Process{
+			{Name: "api", DependsOn: []string{"gone"}},
+			{Name: "db"},
+		}, []string{"api", "db"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := processNames(dependencyOrder(tt.procs)); !equalStrings(got, tt.want) {
+				t.Errorf("dependencyOrder = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDependencyDepths checks the depths StopOnShutdown stops processes by, deepest
+// first, so a process only stops once everything depending on it has
+func TestDependencyDepths(t *testing.T) {
+	procs := []Process{
+		{Name: "web", DependsOn: []string{"api", "cache"}},
+		{Name: "api", DependsOn: []string{"db", "gone"}},
+		{Name: "cache"},
+		{Name: "db"},
+		{Name: "worker", DependsOn: []string{"db"}},
+		{Name: "cron"},
+	}
+	want := map[string]int{"db": 0, "cache": 0, "cron": 0, "api": 1, "worker": 1, "web": 2}
+
+	got := dependencyDepths(procs)
+	if len(got) != len(want) {
+		t.Errorf("dependencyDepths = %v, want %v", got, want)
+	}
+	for name, depth := range want {
+		if got[name] != depth {
+			t.Errorf("depth of %s = %d, want %d", name, got[name], depth)
+		}
+	}
+	for _, proc := range procs {
+		for _, dep := range proc.DependsOn {
+			if d, ok := got[dep]; ok && d >= got[proc.Name] {
+				t.Errorf("%s (depth %d) would stop no earlier than its dependent %s (depth %d)", dep, d, proc.Name, got[proc.Name])
+			}
+		}
+	}
+}
+
+func TestStartAndStopChains(t *testing.T) {
+	pm, _, _ := newTestMonitor([]Process{
+		{Name: "web", DependsOn: []string{"api"}},
+		{Name: "api", DependsOn: []string{"db", "gone"}},
+		{Name: "worker", DependsOn: []string{"db"}},
+		{Name: "db"},
+		{Name: "cron"},
+	}, MonitoringConfig{})
+
+	tests := []struct {
+		name            string
+		withDeps, stops []string
+	}{
+		{"db", []string{"db"}, []string{"worker", "web", "api"}},
+		{"api", []string{"db", "api"}, []string{"web"}},
+		{"web", []string{"db", "api", "web"}, nil},
+		{"cron", []string{"cron"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proc, err := pm.findProcess(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := processNames(pm.withDependencies(proc)); !equalStrings(got, tt.withDeps) {
+				t.Errorf("withDependencies = %v, want %v", got, tt.withDeps)
+			}
+			if got := processNames(pm.dependents(proc)); !equalStrings(got, tt.stops) {
+				t.Errorf("dependents = %v, want %v", got, tt.stops)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DiskConfig lists the mount points whose usage is checked
+type DiskConfig struct {
+	Mounts          []string `json:"mounts"`          // mount points to check, none disables disk monitoring
+	WarnPercent     float64  `json:"warnPercent"`     // yellow above this usage percentage, default 85
+	CriticalPercent float64  `json:"criticalPercent"` // red above this usage percentage, default 95
+	Interval        Duration `json:"interval"`        // how often usage is checked, default checkInterval
+}
+
+// withDefaults fills unset thresholds with the defaults
+func (c DiskConfig) withDefaults() DiskConfig {
+	if c.WarnPercent == 0 {
+		c.WarnPercent = 85
+	}
+	if c.CriticalPercent == 0 {
+		c.CriticalPercent = 95
+	}
+	return c
+}
+
+// validate checks the mount points and that the thresholds are well-formed once
+// defaults are applied
+func (c DiskConfig) validate() error {
+	for _, mount := range c.Mounts {
+		if !filepath.IsAbs(mount) {
+			return fmt.Errorf("mount %q is not an absolute path", mount)
+		}
+	}
+	if c.Interval.Duration < 0 {
+		return fmt.Errorf("interval %v is negative", c.Interval.Duration)
+	}
+
+	c = c.withDefaults()
+	if c.WarnPercent >= c.CriticalPercent {
+		return fmt.Errorf("warnPercent %.1f must be below criticalPercent %.1f", c.WarnPercent, c.CriticalPercent)
+	}
+	if c.CriticalPercent > 100 {
+		return fmt.Errorf("criticalPercent %.1f must not exceed 100", c.CriticalPercent)
+	}
+	return nil
+}
+
+// DiskStatus is the usage of a mount point stored in Redis
+type DiskStatus struct {
+	Mount       string    `json:"mount"`
+	TotalBytes  uint64    `json:"total_bytes"`
+	UsedBytes   uint64    `json:"used_bytes"`
+	FreeBytes   uint64    `json:"free_bytes"` // available to unprivileged users
+	UsedPercent float64   `json:"used_percent"`
+	Status      FruStatus `json:"status"`
+	Timestamp   string    `json:"timestamp"`
+}
+
+// DiskMonitor checks the usage of the configured mount points
+type DiskMonitor struct {
+	config DiskConfig
+	redis  StatusStore
+	logger *Logger
+
+	mu       sync.Mutex
+	statuses map[string]FruStatus // by mount point, from the last check
+}
+
+// NewDiskMonitor creates a monitor for the mount points listed in config
+func NewDiskMonitor(config DiskConfig, redis StatusStore, logger *Logger) *DiskMonitor {
+	if len(config.Mounts) > 0 {
+		logger.Info("Monitoring disk usage of %d mount points", len(config.Mounts))
+	}
+	return &DiskMonitor{
+		config:   config.withDefaults(),
+		redis:    redis,
+		logger:   logger,
+		statuses: make(map[string]FruStatus, len(config.Mounts)),
+	}
+}
+
+// diskUsage reads the usage of the filesystem mounted at mount
+func diskUsage(mount string) (DiskStatus, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(mount, &st); err != nil {
+		return DiskStatus{}, err
+	}
+
+	blockSize := uint64(st.Bsize)
+	usage := DiskStatus{
+		Mount:      mount,
+		TotalBytes: st.Blocks * blockSize,
+		UsedBytes:  (st.Blocks - st.Bfree) * blockSize,
+		FreeBytes:  st.Bavail * blockSize,
+	}
+
+	// Like df, count reserved blocks as neither used nor available
+	if usable := usage.UsedBytes + usage.FreeBytes; usable > 0 {
+		usage.UsedPercent = float64(usage.UsedBytes) / float64(usable) * 100
+	}
+	return usage, nil
+}
+
+// Check reads the usage of every mount point, stores it in Redis and logs status changes.
+// A mount point that can't be read is red.
+func (dm *DiskMonitor) Check(ctx context.Context) {
+	now := time.Now()
+	for _, mount := range dm.config.Mounts {
+		usage, err := diskUsage(mount)
+		switch {
+		case err != nil:
+			usage = DiskStatus{Mount: mount, Status: FruStatusRed}
+		case usage.UsedPercent > dm.config.CriticalPercent:
+			usage.Status = FruStatusRed
+		case usage.UsedPercent > dm.config.WarnPercent:
+			usage.Status = FruStatusYellow
+		default:
+			usage.Status = FruStatusGreen
+		}
+		usage.Timestamp = now.Format(time.RFC3339)
+
+		dm.mu.Lock()
+		previous, seen := dm.statuses[mount]
+		dm.statuses[mount] = usage.Status
+		dm.mu.Unlock()
+		if !seen {
+			previous = FruStatusGreen
+		}
+
+		switch {
+		case err != nil:
+			if previous != FruStatusRed {
+				dm.logger.Critical("Error checking disk usage of %s: %v", mount, err)
+			}
+		case usage.Status == previous:
+			dm.logger.Debug("Disk %s is %.1f%% used", mount, usage.UsedPercent)
+		case usage.Status == FruStatusRed:
+			dm.logger.Critical("Disk %s is %.1f%% used, above %.1f%% (%.2f GB free)",
+				mount, usage.UsedPercent, dm.config.CriticalPercent, float64(usage.FreeBytes)/(1024*1024*1024))
+		case usage.Status == FruStatusYellow:
+			dm.logger.Error("Disk %s is %.1f%% used, above %.1f%% (%.2f GB free)",
+				mount, usage.UsedPercent, dm.config.WarnPercent, float64(usage.FreeBytes)/(1024*1024*1024))
+		default:
+			dm.logger.Info("Disk %s usage is back to %.1f%%", mount, usage.UsedPercent)
+		}
+
+		statusJSON, err := json.Marshal(usage)
+		if err != nil {
+			dm.logger.Error("Error marshaling disk status of %s: %v", mount, err)
+			continue
+		}
+		if err := dm.redis.UpdateDiskStatus(ctx, mount, string(statusJSON)); err != nil {
+			dm.logger.Error("Error updating disk status of %s in Redis: %v", mount, err)
+		}
+	}
+}
+
+// Statuses returns the status of every mount point checked so far, keyed "disk:<mount>"
+// so they can sit next to the FRUs in the chassis status
+func (dm *DiskMonitor) Statuses() map[string]FruStatus {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	statuses := make(map[string]FruStatus, len(dm.statuses))
+	for mount, status := range dm.statuses {
+		statuses["disk:"+mount] = status
+	}
+	return statuses
+}
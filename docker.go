@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultDockerSocket = "/var/run/docker.sock"
+
+// Container states reported for processes backed by a Docker container
+const (
+	ContainerStateMissing = "missing"
+	ContainerStateExited  = "exited"
+)
+
+// DockerClient queries the Docker Engine API over its unix socket
+type DockerClient struct {
+	http *http.Client
+}
+
+// dockerContainer is the subset of the container inspect response hostd uses
+type dockerContainer struct {
+	ID    string `json:"Id"`
+	State struct {
+		Status  string `json:"Status"`
+		Running bool   `json:"Running"`
+		Pid     int    `json:"Pid"`
+	} `json:"State"`
+}
+
+// NewDockerClient creates a Docker API client for the given socket path
+func NewDockerClient(socket string) *DockerClient {
+	if socket == "" {
+		socket = defaultDockerSocket
+	}
+
+	return &DockerClient{
+		http: &http.Client{
+			Timeout: 5 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// containerState returns the main PID and state of a container by name or ID.
+// The PID is 0 unless the container is running; a container that doesn't exist
+// is reported as ContainerStateMissing.
+func (d *DockerClient) containerState(ctx context.Context, container string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"http://docker/containers/"+url.PathEscape(container)+"/json", nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("error creating Docker request: %v", err)
+	}
+
+	resp, err := d.http.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("error querying Docker: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, ContainerStateMissing, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", fmt.Errorf("unexpected Docker response: %s", resp.Status)
+	}
+
+	var info dockerContainer
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return 0, "", fmt.Errorf("error parsing Docker response: %v", err)
+	}
+
+	if !info.State.Running {
+		return 0, info.State.Status, nil
+	}
+	return info.State.Pid, info.State.Status, nil
+}
+
+// getCgroupMemory gets the memory usage in bytes of the cgroup a process belongs to,
+// supporting both cgroup v2 and the v1 memory controller
+func (pm *ProcessMonitor) getCgroupMemory(pid int) (int64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return 0, fmt.Errorf("error reading cgroup: %v", err)
+	}
+
+	var memFile string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		// Each line is hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			memFile = filepath.Join("/sys/fs/cgroup", parts[2], "memory.current")
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			if controller == "memory" {
+				memFile = filepath.Join("/sys/fs/cgroup/memory", parts[2], "memory.usage_in_bytes")
+			}
+		}
+	}
+	if memFile == "" {
+		return 0, fmt.Errorf("no memory cgroup found for PID %d", pid)
+	}
+
+	raw, err := os.ReadFile(memFile)
+	if err != nil {
+		return 0, fmt.Errorf("error reading cgroup memory: %v", err)
+	}
+
+	mem, err := strconv.ParseInt(strings.TrimSpace(string(raw)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing cgroup memory value: %v", err)
+	}
+
+	return mem, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// dumpStatus logs the live status of every monitored process and the FRU statuses from
+// the latest hardware poll. It only reads, so it can run between regular checks.
+func dumpStatus(ctx context.Context, monitor *ProcessMonitor, hardware *HardwareManager, logger *Logger) {
+	logger.Info("Status dump requested")
+	monitor.LogStatus(ctx)
+	hardware.LogStatus()
+	logger.Info("Status dump complete")
+}
+
+// LogStatus probes every monitored process and logs its state, PID and memory, along
+// with the restart count from its stored status, without touching the stored status or
+// the restart logic
+func (pm *ProcessMonitor) LogStatus(ctx context.Context) {
+	processes := pm.Processes()
+	names := make([]string, 0, len(processes))
+	for _, proc := range processes {
+		names = append(names, proc.Name)
+	}
+	stored, err := pm.redis.GetAllProcessStatuses(ctx, names)
+	if err != nil {
+		pm.logger.Error("Status dump: error getting stored process statuses: %v", err)
+	}
+
+	for _, proc := range processes {
+		restarts := 0
+		if status, ok := stored[proc.Name]; ok {
+			restarts = status.RestartCount
+		}
+
+		var pids []int
+		if proc.Container != "" {
+			var pid int
+			pid, _, err = pm.docker.containerState(ctx, proc.Container)
+			if pid > 0 {
+				pids = []int{pid}
+			}
+		} else {
+			pids, err = pm.prober.PIDs(proc)
+		}
+		if err != nil {
+			pm.logger.Error("Status dump: process %s: error getting PID: %v", proc.Name, err)
+			continue
+		}
+		if len(pids) == 0 {
+			pm.logger.Info("Status dump: process %s is down (restarts: %d)", proc.Name, restarts)
+			continue
+		}
+
+		var memory int64
+		switch {
+		case proc.Container != "":
+			memory, err = pm.getCgroupMemory(pids[0])
+		case proc.Cgroup != "":
+			memory, err = cgroupMemory(proc.Cgroup)
+		default:
+			memory, err = pm.prober.Memory(pids)
+		}
+		if err != nil {
+			pm.logger.Error("Status dump: process %s: error getting memory usage: %v", proc.Name, err)
+		}
+		pm.logger.Info("Status dump: process %s is up (PID: %d, instances: %d, memory: %.2f MB, restarts: %d)",
+			proc.Name, pids[0], len(pids), float64(memory)/(1024*1024), restarts)
+	}
+}
+
+// LogStatus logs the chassis status and the status of every FRU from the latest poll
+func (hm *HardwareManager) LogStatus() {
+	if len(hm.frus) == 0 && len(hm.sources) == 0 {
+		return
+	}
+
+	hm.mu.Lock()
+	chassis := hm.last
+	hm.mu.Unlock()
+	if chassis.Timestamp == "" {
+		hm.logger.Info("Status dump: hardware not polled yet")
+		return
+	}
+
+	names := make([]string, 0, len(chassis.Frus))
+	for name := range chassis.Frus {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	frus := make([]string, 0, len(names))
+	for _, name := range names {
+		frus = append(frus, name+"="+string(chassis.Frus[name]))
+	}
+	hm.logger.Info("Status dump: chassis %s as of %s: %s", chassis.Status, chassis.Timestamp, strings.Join(frus, " "))
+}
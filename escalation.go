@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// EscalationStep is one rung of the escalation ladder for FRUs that stay red
+type EscalationStep struct {
+	After    Duration `json:"after"`    // time continuously red before this step applies
+	Severity string   `json:"severity"` // alert severity or route, e.g. warning, page, page-manager
+}
+
+// FruEscalation tracks how long a FRU has been red and how far it has escalated
+type FruEscalation struct {
+	ladder   []EscalationStep
+	redSince time.Time
+	level    int // index of the last step reached, -1 if not escalated
+}
+
+// newFruEscalation creates an escalation tracker for the given ladder
+func newFruEscalation(ladder []EscalationStep) *FruEscalation {
+	sorted := append([]EscalationStep(nil), ladder...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].After.Duration < sorted[j].After.Duration
+	})
+
+	return &FruEscalation{
+		ladder: sorted,
+		level:  -1,
+	}
+}
+
+// timeInRed returns how long the FRU has been continuously red
+func (e *FruEscalation) timeInRed(now time.Time) time.Duration {
+	if e.redSince.IsZero() {
+		return 0
+	}
+	return now.Sub(e.redSince)
+}
+
+// observe records the latest status of a FRU, escalating while it stays red and
+// resolving the alert once it returns to green
+func (e *FruEscalation) observe(name string, status FruStatus, now time.Time, logger *Logger) {
+	switch status {
+	case FruStatusRed:
+		if e.redSince.IsZero() {
+			e.redSince = now
+		}
+
+		inRed := e.timeInRed(now)
+		for i := e.level + 1; i < len(e.ladder); i++ {
+			if inRed < e.ladder[i].After.Duration {
+				break
+			}
+			e.level = i
+			logger.Critical("FRU %s red for %v, escalating alert to %s", name, inRed.Round(time.Second), e.ladder[i].Severity)
+		}
+	case FruStatusGreen:
+		if e.level >= 0 {
+			logger.Info("FRU %s returned to green, resolving %s alert", name, e.ladder[e.level].Severity)
+		}
+		e.redSince = time.Time{}
+		e.level = -1
+	default:
+		// Leaving red stops the clock, but the alert stays open until the FRU is green
+		e.redSince = time.Time{}
+	}
+}
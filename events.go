@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// publishTransition adds a process state transition to the Redis event stream and
+// notifies the webhooks and Kafka. A synthetic transition, injected to test alerting, is tagged so.
+func (pm *ProcessMonitor) publishTransition(ctx context.Context, proc Process, oldStatus, newStatus string, pid int, synthetic bool) {
+	message := fmt.Sprintf("Process %s changed from %s to %s (PID: %d)", proc.Name, oldStatus, newStatus, pid)
+	if synthetic {
+		message = "[synthetic] " + message
+	}
+	pm.notifier.Notify(WebhookEvent{
+		Event:     WebhookEventProcessTransition,
+		Process:   proc.Name,
+		OldStatus: oldStatus,
+		NewStatus: newStatus,
+		Synthetic: synthetic,
+		Message:   message,
+	})
+
+	event := transitionEvent(proc.Name, oldStatus, newStatus, pid, synthetic)
+	if err := pm.redis.AddEvent(ctx, event); err != nil {
+		pm.logger.Error("Error publishing state transition event for process %s: %v", proc.Name, err)
+	}
+	pm.notifier.PublishEvent(WebhookEventProcessTransition, event)
+}
+
+// transitionEvent returns the event stream entry of a process state transition
+func transitionEvent(name, oldStatus, newStatus string, pid int, synthetic bool) map[string]interface{} {
+	event := map[string]interface{}{
+		"process":    name,
+		"old_status": oldStatus,
+		"new_status": newStatus,
+		"pid":        strconv.Itoa(pid),
+		"ts":         time.Now().Format(time.RFC3339Nano),
+	}
+	if synthetic {
+		event["synthetic"] = "true"
+	}
+	return event
+}
+
+// SetNotifier sets where webhook events about processes are sent
+func (pm *ProcessMonitor) SetNotifier(notifier *Notifier) {
+	pm.notifier = notifier
+}
+
+// publishLimitAlert adds a resource limit alert being raised or cleared to the Redis
+// event stream and publishes it to Kafka
+func (pm *ProcessMonitor) publishLimitAlert(ctx context.Context, proc Process, resource string, raised bool, usage, limit string) {
+	event := limitAlertEvent(proc.Name, resource, raised, usage, limit)
+	if err := pm.redis.AddEvent(ctx, event); err != nil {
+		pm.logger.Error("Error publishing %s limit event for process %s: %v", resource, proc.Name, err)
+	}
+	pm.notifier.PublishEvent(KafkaEventLimitAlert, event)
+}
+
+// limitAlertEvent returns the event stream entry of a resource limit alert
+func limitAlertEvent(name, resource string, raised bool, usage, limit string) map[string]interface{} {
+	state := "cleared"
+	if raised {
+		state = "raised"
+	}
+	return map[string]interface{}{
+		"process":     name,
+		"limit_alert": resource,
+		"state":       state,
+		"usage":       usage,
+		"limit":       limit,
+		"ts":          time.Now().Format(time.RFC3339Nano),
+	}
+}
+
+// fruTransitionEvent returns an entry in the shape of the event stream for a FRU turning
+// red or recovering. FRU changes aren't added to the stream, only published to Kafka.
+func fruTransitionEvent(name string, oldStatus, newStatus FruStatus) map[string]interface{} {
+	return map[string]interface{}{
+		"fru":        name,
+		"old_status": string(oldStatus),
+		"new_status": string(newStatus),
+		"ts":         time.Now().Format(time.RFC3339Nano),
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultExportRows = 1000  // rows in a CSV export unless ?limit= asks for more
+	maxExportRows     = 10000 // upper bound on ?limit=
+	exportFlushRows   = 100   // rows written between flushes to the client
+)
+
+// handleExportCSV serves GET /export.csv?entity={type}/{instance} with the stored metrics
+// history of one FRU as CSV, a header row then one row per sample, oldest first, for
+// pulling into a spreadsheet. Optional parameters:
+//   - since: only samples at or after an RFC 3339 time, or within a duration such as 1h
+//   - columns: comma-separated fields to include, in that order; by default timestamp
+//     and then every field of the newest sample, sorted. A field found in none of the
+//     exported samples is rejected, as it is most likely misspelled.
+//   - limit: most rows to write, default 1000, at most 10000; the newest are kept
+//
+// Rows are decoded and written one at a time and flushed every 100 rows, so a large
+// export is never held in memory as CSV.
+func (a *statusAPI) handleExportCSV(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	query := r.URL.Query()
+
+	fruType, instanceText, ok := strings.Cut(query.Get("entity"), "/")
+	instance, err := strconv.Atoi(instanceText)
+	if !ok || fruType == "" || err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "entity must be {type}/{instance}, such as fan/1"})
+		return
+	}
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		if since, err = parseSince(value, time.Now()); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	limit := defaultExportRows
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > maxExportRows {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("limit must be a number from 1 to %d", maxExportRows)})
+			return
+		}
+	}
+	var columns []string
+	if value := query.Get("columns"); value != "" {
+		for _, column := range strings.Split(value, ",") {
+			column = strings.TrimSpace(column)
+			if column == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "columns must not contain empty names"})
+				return
+			}
+			columns = append(columns, column)
+		}
+	}
+
+	history, err := a.redis.GetHardwareHistory(r.Context(), fruType, instance)
+	if err != nil {
+		a.fail(w, "getting hardware history", err)
+		return
+	}
+
+	selected, fields := a.selectExportSamples(history, since, limit, fruType, instance)
+	if columns == nil {
+		columns = defaultColumns(fields)
+	} else if len(selected) > 0 {
+		for _, column := range columns {
+			if !fields.all[column] {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("column %q is not a field of any exported sample", column)})
+				return
+			}
+		}
+	}
+
+	filename := fmt.Sprintf("%s-%d.csv", fruType, instance)
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	out := csv.NewWriter(w)
+	out.Write(columns)
+	row := make([]string, len(columns))
+	for i := len(selected) - 1; i >= 0; i-- {
+		// Decoded already when selecting, so this can't fail
+		sample, _ := decodeSample(history[selected[i]])
+		for j, column := range columns {
+			row[j] = csvField(sample[column])
+		}
+		out.Write(row)
+		if (len(selected)-i)%exportFlushRows == 0 {
+			out.Flush()
+			if out.Error() != nil {
+				return // client went away
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	out.Flush()
+}
+
+// exportFields are the fields found in the samples selected for an export
+type exportFields struct {
+	newest []string        // fields of the newest sample, unordered
+	all    map[string]bool // fields of any sample
+}
+
+// selectExportSamples picks the history entries to export, newest first: the newest
+// limit samples at or after since, skipping unreadable ones. It returns their indexes
+// in history with the fields they contain, keeping only the raw entries so the rows can
+// be decoded again one at a time as they are written.
+func (a *statusAPI) selectExportSamples(history []string, since time.Time, limit int, fruType string, instance int) ([]int, exportFields) {
+	var selected []int
+	fields := exportFields{all: make(map[string]bool)}
+	for i, data := range history {
+		if len(selected) == limit {
+			break
+		}
+		sample, err := decodeSample(data)
+		if err != nil {
+			a.logger.Error("Skipping unreadable %s/%d history sample in CSV export: %v", fruType, instance, err)
+			continue
+		}
+		if !since.IsZero() {
+			at, err := time.Parse(time.RFC3339, fmt.Sprint(sample["timestamp"]))
+			if err != nil || at.Before(since) {
+				continue
+			}
+		}
+		for field := range sample {
+			if len(selected) == 0 {
+				fields.newest = append(fields.newest, field)
+			}
+			fields.all[field] = true
+		}
+		selected = append(selected, i)
+	}
+	return selected, fields
+}
+
+// parseSince reads the since parameter of an export: an RFC 3339 time or a duration
+// before now
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return time.Time{}, fmt.Errorf("since %q is not an RFC 3339 time or a positive duration", value)
+	}
+	return now.Add(-d), nil
+}
+
+// decodeSample decodes a stored metrics sample keeping numbers as written
+func decodeSample(data string) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	var sample map[string]interface{}
+	if err := decoder.Decode(&sample); err != nil {
+		return nil, err
+	}
+	return sample, nil
+}
+
+// defaultColumns is timestamp followed by the other fields of the newest sample, sorted
+func defaultColumns(fields exportFields) []string {
+	columns := []string{"timestamp"}
+	var rest []string
+	for _, field := range fields.newest {
+		if field != "timestamp" {
+			rest = append(rest, field)
+		}
+	}
+	sort.Strings(rest)
+	return append(columns, rest...)
+}
+
+// csvField formats one sample field as a CSV cell: empty when missing, nested arrays and
+// objects as compact JSON
+func csvField(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		var buf bytes.Buffer
+		json.NewEncoder(&buf).Encode(v)
+		return strings.TrimSuffix(buf.String(), "\n")
+	}
+}
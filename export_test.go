@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelectExportSamples(t *testing.T) {
+	// Newest first, as stored
+	history := []string{
+		`{"timestamp":"2026-10-15T12:03:00Z","speed":1300,"duty":60}`,
+		`not json`,
+		`{"timestamp":"2026-10-15T12:02:00Z","speed":1200,"alarm":true}`,
+		`{"timestamp":"2026-10-15T12:01:00Z","speed":1100}`,
+		`{"timestamp":"2026-10-15T12:00:00Z","speed":1000,"stale":true}`,
+	}
+	api := &statusAPI{logger: newTestLogger()}
+
+	tests := []struct {
+		name  string
+		since time.Time
+		limit int
+		want  []int
+	}{
+		{"all", time.Time{}, 10, []int{0, 2, 3, 4}},
+		{"newest kept", time.Time{}, 2, []int{0, 2}},
+		{"since", time.Date(2026, 10, 15, 12, 1, 0, 0, time.UTC), 10, []int{0, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, _ := api.selectExportSamples(history, tt.since, tt.limit, "fan", 1)
+			if len(selected) != len(tt.want) {
+				t.Fatalf("selected %v, want %v", selected, tt.want)
+			}
+			for i := range selected {
+				if selected[i] != tt.want[i] {
+					t.Fatalf("selected %v, want %v", selected, tt.want)
+				}
+			}
+		})
+	}
+
+	_, fields := api.selectExportSamples(history, time.Time{}, 2, "fan", 1)
+	if got, want := defaultColumns(fields), []string{"timestamp", "duty", "speed"}; !equalStrings(got, want) {
+		t.Errorf("defaultColumns = %v, want %v from the newest sample", got, want)
+	}
+	for _, field := range []string{"duty", "alarm", "speed"} {
+		if !fields.all[field] {
+			t.Errorf("field %s of an exported sample not found", field)
+		}
+	}
+	if fields.all["stale"] {
+		t.Error("field stale found, but only a sample beyond the limit has it")
+	}
+}
@@ -4,36 +4,77 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"strconv"
 	"time"
 )
 
+// fanSafeDutyFloor is the lowest duty cycle a fan can be commanded to
+const fanSafeDutyFloor = 30
+
 // FanMetrics represents the metrics for a fan
 type FanMetrics struct {
-	Speed     int    `json:"speed"`
-	Duty      int    `json:"duty"`
-	Timestamp string `json:"timestamp"`
+	Speed       int    `json:"speed"`        // slowest rotor, used for status
+	RotorSpeeds []int  `json:"rotor_speeds"` // one entry per tach input
+	Duty        int    `json:"duty"`
+	Timestamp   string `json:"timestamp"`
+
+	LastUpdated string `json:"last_updated"` // when the readings last changed
+	Stale       bool   `json:"stale"`        // readings unchanged for longer than the staleness thresholds allow
+
+	SchemaVersion int `json:"schema_version"` // hardwareMetricsSchemaVersion when stored
 }
 
 // Fan represents a cooling fan
 type Fan struct {
-	name      string
-	logger    *Logger
-	redis     *RedisClient
-	speed     int // RPM
-	duty      int // Percentage
-	isPresent bool
-	instance  int
+	name         string
+	logger       *Logger
+	redis        StatusStore
+	metrics      *MetricsExporter // nil when the exporter is disabled
+	speed        int              // RPM of the slowest rotor
+	rotorSpeeds  []int            // RPM per tach input
+	tachInputs   []string
+	duty         int // Percentage
+	isPresent    bool
+	presencePath string // file that exists while the FRU is present, empty to assume present
+	instance     int
+	thresholds   FanThresholds
+	source       FanSource // where rotor speeds and duty are read from
+	freshness    freshness
+	retry        readRetry
+
+	pwmPath    string          // pwm file setDuty writes, empty if the fan has none
+	normalDuty int             // duty to restore once no sensor needs a boost
+	boostedBy  map[string]bool // sensors currently boosting the fan
+
+	pwmEnablePath string // pwm_enable file selecting manual or automatic control, empty if the fan has none
+	normalEnable  int    // pwm_enable mode to restore once no sensor needs a boost
 }
 
-// NewFan creates a new Fan instance
-func NewFan(name string, instance int, logger *Logger, redis *RedisClient) *Fan {
-	return &Fan{
-		name:      name,
-		logger:    logger,
-		redis:     redis,
-		isPresent: true, // Initially assume fan is present
-		instance:  instance,
+// pwmEnableManual is the pwm_enable mode in which the driver accepts duty writes to pwm
+const pwmEnableManual = 1
+
+// Fan must keep satisfying HardwareInterface
+var _ HardwareInterface = (*Fan)(nil)
+
+// FanSource reads a fan's rotor speeds in RPM, one per rotor, and its duty percentage.
+// The fan derives its speed from the slowest rotor.
+type FanSource func(ctx context.Context) (FanMetrics, error)
+
+// NewFan creates a new Fan instance reading its default source
+func NewFan(name string, instance int, thresholds FanThresholds, logger *Logger, redis StatusStore, metrics *MetricsExporter) *Fan {
+	f := &Fan{
+		name:       name,
+		logger:     logger.WithFields(map[string]interface{}{"fru": fmt.Sprintf("%s-%d", name, instance)}),
+		redis:      redis,
+		metrics:    metrics,
+		duty:       60,   // Default duty cycle until commanded otherwise
+		isPresent:  true, // Initially assume fan is present
+		instance:   instance,
+		thresholds: thresholds,
 	}
+	f.source = f.defaultSource
+	return f
 }
 
 func (f *Fan) getName() string {
@@ -41,35 +82,60 @@ func (f *Fan) getName() string {
 }
 
 func (f *Fan) getStatus(ctx context.Context) (FruStatus, error) {
+	if f.presencePath != "" {
+		f.isPresent = fruPresent(f.presencePath)
+	}
 	if !f.isPresent {
 		return FruStatusRed, fmt.Errorf("fan %d not present", f.instance)
 	}
 
-	if err := f.updateMetrics(ctx); err != nil {
+	if err := f.retry.read(ctx, f.redis, f.logger, "fan", f.instance, f.updateMetrics); err != nil {
 		return FruStatusRed, fmt.Errorf("failed to update fan %d metrics", f.instance)
 	}
 
-	// Example thresholds - adjust based on actual requirements
-	if f.speed < 100 { // Fan almost stopped
+	// Readings that stopped changing may come from a stuck sensor
+	stale := f.freshness.status(f.thresholds.StalenessThresholds, time.Now())
+	if stale == FruStatusRed {
+		return FruStatusRed, fmt.Errorf("fan %d readings unchanged since %s", f.instance, f.freshness.lastUpdated.Format(time.RFC3339))
+	}
+
+	if f.speed < f.thresholds.MinSpeed { // Fan almost stopped
 		return FruStatusRed, nil
 	}
-	if f.duty > 90 { // Fan working too hard
+	if f.duty > f.thresholds.MaxDuty { // Fan working too hard
 		return FruStatusYellow, nil
 	}
-	return FruStatusGreen, nil
+	return stale, nil
 }
 
 func (f *Fan) updateMetrics(ctx context.Context) error {
-	// In a real implementation, this would read from hardware
-	// For now, using example values
-	f.speed = 2000 // 2000 RPM
-	f.duty = 60    // 60% duty cycle
+	reading, err := f.source(ctx)
+	if err != nil {
+		f.logger.Error("Failed to read fan %d: %v", f.instance, err)
+		return err
+	}
+	if len(reading.RotorSpeeds) == 0 {
+		err := fmt.Errorf("no rotor speeds")
+		f.logger.Error("Failed to read fan %d: %v", f.instance, err)
+		return err
+	}
+	f.rotorSpeeds = reading.RotorSpeeds
+	f.speed = slowestRotor(reading.RotorSpeeds)
+	f.duty = reading.Duty
+
+	now := time.Now()
+	f.freshness.observe(reading, now)
 
 	// Create metrics structure
 	metrics := FanMetrics{
-		Speed:     f.speed,
-		Duty:      f.duty,
-		Timestamp: time.Now().Format(time.RFC3339),
+		Speed:       f.speed,
+		RotorSpeeds: f.rotorSpeeds,
+		Duty:        f.duty,
+		Timestamp:   now.Format(time.RFC3339),
+		LastUpdated: f.freshness.lastUpdated.Format(time.RFC3339),
+		Stale:       f.freshness.status(f.thresholds.StalenessThresholds, now) != FruStatusGreen,
+
+		SchemaVersion: hardwareMetricsSchemaVersion,
 	}
 
 	// Convert metrics to JSON
@@ -80,22 +146,184 @@ func (f *Fan) updateMetrics(ctx context.Context) error {
 	}
 
 	// Store metrics in Redis
-	key := fmt.Sprintf("hardware:fan:%d:metrics", f.instance)
-	if err := f.redis.client.Set(ctx, key, string(metricsJSON), 0).Err(); err != nil {
+	if err := f.redis.UpdateHardwareMetrics(ctx, "fan", f.instance, string(metricsJSON)); err != nil {
 		f.logger.Error("Failed to store fan %d metrics in Redis: %v", f.instance, err)
 		return err
 	}
 
-	f.logger.Info("Updated fan %d metrics: Speed=%dRPM, Duty=%d%%",
-		f.instance, f.speed, f.duty)
+	f.metrics.ObserveFan(f.instance, metrics)
+
+	f.logger.Debug("Updated fan %d metrics: Speed=%dRPM, Rotors=%v, Duty=%d%%",
+		f.instance, f.speed, f.rotorSpeeds, f.duty)
 	return nil
 }
 
+// defaultSource reads the fan's tach inputs, one per rotor, and its PWM file, or reports
+// simulated readings when it has no tach input. Without a PWM file the duty is the last
+// commanded one.
+func (f *Fan) defaultSource(ctx context.Context) (FanMetrics, error) {
+	if len(f.tachInputs) == 0 {
+		return f.simulatedFan(ctx)
+	}
+
+	speeds, err := readTachInputs(f.tachInputs)
+	if err != nil {
+		return FanMetrics{}, err
+	}
+	duty := f.duty
+	if f.pwmPath != "" {
+		pwm, err := readSysfsInt(f.pwmPath)
+		if err != nil {
+			return FanMetrics{}, err
+		}
+		duty = (pwm*100 + 127) / 255
+	}
+	return FanMetrics{RotorSpeeds: speeds, Duty: duty}, nil
+}
+
+// simulatedFan reports a fixed example speed, for hosts without fan sensors. The duty
+// is the last commanded one.
+func (f *Fan) simulatedFan(ctx context.Context) (FanMetrics, error) {
+	return FanMetrics{RotorSpeeds: []int{2000}, Duty: f.duty}, nil
+}
+
+// readTachInputs reads the RPM of every given tach input
+func readTachInputs(paths []string) ([]int, error) {
+	speeds := make([]int, 0, len(paths))
+	for _, path := range paths {
+		rpm, err := readSysfsInt(path)
+		if err != nil {
+			return nil, err
+		}
+		speeds = append(speeds, rpm)
+	}
+	return speeds, nil
+}
+
+// slowestRotor returns the lowest of the given rotor speeds
+func slowestRotor(speeds []int) int {
+	slowest := speeds[0]
+	for _, rpm := range speeds[1:] {
+		if rpm < slowest {
+			slowest = rpm
+		}
+	}
+	return slowest
+}
+
 func (f *Fan) available() bool {
 	return f.isPresent
 }
 
 func (f *Fan) setInstance(instance int) {
 	f.instance = instance
+	f.logger = f.logger.WithFields(map[string]interface{}{"fru": f.getName()})
 	f.logger.Info("Set fan instance to %d", instance)
 }
+
+// setReadRetry sets how failed metric reads are retried
+func (f *Fan) setReadRetry(config ReadRetryConfig) {
+	f.retry.config = config
+}
+
+// setPresencePath sets the file whose existence shows the fan is present
+func (f *Fan) setPresencePath(path string) {
+	f.presencePath = path
+	f.logger.Info("Set fan %d presence path to %s", f.instance, path)
+}
+
+// setTachInputs sets the sysfs tach input paths for the fan, one per rotor
+func (f *Fan) setTachInputs(paths []string) {
+	f.tachInputs = paths
+	f.logger.Info("Set fan %d tach inputs to %v", f.instance, paths)
+}
+
+// setSource replaces where the fan's readings come from, for example to simulate them
+func (f *Fan) setSource(source FanSource) {
+	f.source = source
+}
+
+// setDuty commands the fan to the given duty cycle, clamped to the safe floor and 100%,
+// by writing its pwm file. A fan without one only records the commanded duty.
+func (f *Fan) setDuty(duty int) error {
+	if duty < fanSafeDutyFloor {
+		duty = fanSafeDutyFloor
+	}
+	if duty > 100 {
+		duty = 100
+	}
+
+	if f.pwmPath != "" {
+		pwm := (duty*255 + 50) / 100
+		if err := os.WriteFile(f.pwmPath, []byte(strconv.Itoa(pwm)), 0644); err != nil {
+			return fmt.Errorf("error writing %s: %v", f.pwmPath, err)
+		}
+	}
+	f.duty = duty
+	f.logger.Info("Set fan %d duty to %d%%", f.instance, duty)
+	return nil
+}
+
+// boost raises the fan to at least duty on behalf of a hot sensor. The first boost
+// switches the fan to manual control, since most drivers ignore duty writes otherwise.
+// The control mode and duty it had before are restored once every boosting sensor has
+// released it.
+func (f *Fan) boost(sensor string, duty int) error {
+	first := len(f.boostedBy) == 0
+	if first {
+		if err := f.takeManualControl(); err != nil {
+			return err
+		}
+		f.normalDuty = f.duty
+		f.boostedBy = make(map[string]bool)
+	}
+	f.boostedBy[sensor] = true
+	if duty <= f.duty && !first {
+		return nil
+	}
+	if duty < f.duty {
+		duty = f.duty
+	}
+	return f.setDuty(duty)
+}
+
+// unboost releases the boost of a sensor. Once no other sensor boosts the fan, control
+// goes back to the driver if it had it, or else the previous duty is restored.
+func (f *Fan) unboost(sensor string) error {
+	if !f.boostedBy[sensor] {
+		return nil
+	}
+	delete(f.boostedBy, sensor)
+	if len(f.boostedBy) > 0 {
+		return nil
+	}
+	if f.pwmEnablePath != "" && f.normalEnable != pwmEnableManual {
+		if err := os.WriteFile(f.pwmEnablePath, []byte(strconv.Itoa(f.normalEnable)), 0644); err != nil {
+			return fmt.Errorf("error writing %s: %v", f.pwmEnablePath, err)
+		}
+		f.duty = f.normalDuty
+		f.logger.Info("Returned fan %d to automatic control (pwm_enable %d)", f.instance, f.normalEnable)
+		return nil
+	}
+	return f.setDuty(f.normalDuty)
+}
+
+// takeManualControl saves the fan's pwm_enable mode and switches it to manual
+func (f *Fan) takeManualControl() error {
+	if f.pwmEnablePath == "" {
+		return nil
+	}
+	mode, err := readSysfsInt(f.pwmEnablePath)
+	if err != nil {
+		return err
+	}
+	f.normalEnable = mode
+	if mode == pwmEnableManual {
+		return nil
+	}
+	if err := os.WriteFile(f.pwmEnablePath, []byte(strconv.Itoa(pwmEnableManual)), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %v", f.pwmEnablePath, err)
+	}
+	f.logger.Info("Switched fan %d to manual control (pwm_enable was %d)", f.instance, mode)
+	return nil
+}
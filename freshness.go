@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// StalenessThresholds decide when a FRU whose readings stopped changing is reported.
+// A stuck sensor can keep returning its last value without erroring, so readings that
+// don't change for this long are treated as stale. Zero disables each level.
+type StalenessThresholds struct {
+	StaleWarn     Duration `json:"staleWarn,omitempty"`     // yellow once readings are unchanged for this long
+	StaleCritical Duration `json:"staleCritical,omitempty"` // red once readings are unchanged for this long
+}
+
+// validate checks that the warning comes before the critical level
+func (t StalenessThresholds) validate(name string) error {
+	if t.StaleWarn.Duration < 0 || t.StaleCritical.Duration < 0 {
+		return fmt.Errorf("%s staleness must not be negative", name)
+	}
+	if t.StaleWarn.Duration > 0 && t.StaleCritical.Duration > 0 && t.StaleWarn.Duration >= t.StaleCritical.Duration {
+		return fmt.Errorf("%s staleWarn %v must be below staleCritical %v", name, t.StaleWarn.Duration, t.StaleCritical.Duration)
+	}
+	return nil
+}
+
+// freshness tracks when a FRU's readings last changed
+type freshness struct {
+	last        interface{} // previous reading
+	lastUpdated time.Time   // when the reading last changed
+}
+
+// observe records a reading taken at now
+func (f *freshness) observe(reading interface{}, now time.Time) {
+	if f.lastUpdated.IsZero() || !reflect.DeepEqual(reading, f.last) {
+		f.last = reading
+		f.lastUpdated = now
+	}
+}
+
+// status returns the FRU status implied by how long the readings have been unchanged
+func (f *freshness) status(t StalenessThresholds, now time.Time) FruStatus {
+	age := now.Sub(f.lastUpdated)
+	switch {
+	case t.StaleCritical.Duration > 0 && age >= t.StaleCritical.Duration:
+		return FruStatusRed
+	case t.StaleWarn.Duration > 0 && age >= t.StaleWarn.Duration:
+		return FruStatusYellow
+	default:
+		return FruStatusGreen
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultGRPCListen = "127.0.0.1:9090"
+
+// grpcControl serves the ProcessControl service defined in hostd.proto. Every RPC goes
+// through ProcessMonitor.HandleCommand, like commands received over Redis.
+type grpcControl struct {
+	UnimplementedProcessControlServer
+	monitor *ProcessMonitor
+}
+
+// StartGRPCServer starts the gRPC control interface on addr. The server stops when ctx
+// is cancelled; the returned channel is closed once it has.
+func StartGRPCServer(ctx context.Context, addr string, monitor *ProcessMonitor, logger *Logger) (<-chan struct{}, error) {
+	if addr == "" {
+		addr = defaultGRPCListen
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := grpc.NewServer()
+	RegisterProcessControlServer(server, &grpcControl{monitor: monitor})
+
+	done := make(chan struct{})
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			logger.Error("gRPC control server error: %v", err)
+		}
+	}()
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+
+	logger.Info("gRPC control interface listening on %s", listener.Addr())
+	return done, nil
+}
+
+// Start launches a process, after starting any of its dependencies that are down
+func (g *grpcControl) Start(ctx context.Context, req *ProcessRequest) (*CommandResponse, error) {
+	if _, err := g.handle(ctx, ActionStart, req); err != nil {
+		return nil, err
+	}
+	return &CommandResponse{}, nil
+}
+
+// Stop stops a process, after stopping the processes that depend on it
+func (g *grpcControl) Stop(ctx context.Context, req *ProcessRequest) (*CommandResponse, error) {
+	if _, err := g.handle(ctx, ActionStop, req); err != nil {
+		return nil, err
+	}
+	return &CommandResponse{}, nil
+}
+
+// Restart restarts a process together with the rest of its restart group
+func (g *grpcControl) Restart(ctx context.Context, req *ProcessRequest) (*CommandResponse, error) {
+	if _, err := g.handle(ctx, ActionRestart, req); err != nil {
+		return nil, err
+	}
+	return &CommandResponse{}, nil
+}
+
+// GetStatus returns the stored status of a process
+func (g *grpcControl) GetStatus(ctx context.Context, req *ProcessRequest) (*StatusResponse, error) {
+	result, err := g.handle(ctx, ActionStatus, req)
+	if err != nil {
+		return nil, err
+	}
+	statusJSON, ok := result.(json.RawMessage)
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "unexpected status result %T", result)
+	}
+	return &StatusResponse{StatusJson: string(statusJSON)}, nil
+}
+
+// handle runs a command through the shared command handler, mapping failures to gRPC
+// status codes
+func (g *grpcControl) handle(ctx context.Context, action string, req *ProcessRequest) (interface{}, error) {
+	if !g.monitor.isConfigured(req.GetProcess()) {
+		return nil, status.Errorf(codes.NotFound, "process %s is not configured", req.GetProcess())
+	}
+
+	result, err := g.monitor.HandleCommand(ctx, Command{Action: action, Process: req.GetProcess()})
+	if err != nil {
+		return nil, status.Error(codes.Unknown, err.Error())
+	}
+	return result, nil
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HardwareConfig lists the FRUs polled on every check
+type HardwareConfig struct {
+	Fans FanGroupConfig `json:"fans"`
+	PSUs PSUGroupConfig `json:"psus"`
+	NPUs FruGroupConfig `json:"npus"`
+
+	Thresholds ThresholdsConfig `json:"thresholds"`
+
+	Cooling CoolingConfig `json:"cooling"`
+
+	ReadRetry ReadRetryConfig `json:"readRetry"` // retries of failed metric reads before a FRU turns red
+}
+
+// FruGroupConfig describes the FRUs of one hardware type
+type FruGroupConfig struct {
+	Count        int    `json:"count"`                  // slots, numbered from 0
+	Absent       []int  `json:"absent,omitempty"`       // slots expected to be empty, not polled
+	PresencePath string `json:"presencePath,omitempty"` // file that exists while a FRU is present, {instance} is replaced by its number
+}
+
+// validate checks that the absent slots exist
+func (g FruGroupConfig) validate(name string) error {
+	for _, slot := range g.Absent {
+		if slot < 0 || slot >= g.Count {
+			return fmt.Errorf("%s absent slot %d is outside 0-%d", name, slot, g.Count-1)
+		}
+	}
+	return nil
+}
+
+// expected reports whether a FRU is expected in the given slot
+func (g FruGroupConfig) expected(slot int) bool {
+	for _, absent := range g.Absent {
+		if absent == slot {
+			return false
+		}
+	}
+	return true
+}
+
+// presencePath returns the presence file of the FRU in the given slot, empty if not configured
+func (g FruGroupConfig) presencePath(slot int) string {
+	return strings.ReplaceAll(g.PresencePath, "{instance}", strconv.Itoa(slot))
+}
+
+// validate checks the FRU population and thresholds
+func (c HardwareConfig) validate() error {
+	if err := c.Fans.validate(); err != nil {
+		return err
+	}
+	if err := c.PSUs.validate(); err != nil {
+		return err
+	}
+	if err := c.NPUs.validate("npus"); err != nil {
+		return err
+	}
+	if err := c.Cooling.validate(c.Fans.FruGroupConfig); err != nil {
+		return err
+	}
+	if err := c.ReadRetry.validate(); err != nil {
+		return err
+	}
+	return c.Thresholds.validate()
+}
+
+// fruPresent reports whether the presence file of a FRU, or another sysfs file it
+// may have, exists
+func fruPresent(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// ChassisStatus is the aggregate hardware status stored in Redis
+type ChassisStatus struct {
+	Status    FruStatus            `json:"status"` // worst status of any FRU
+	Frus      map[string]FruStatus `json:"frus"`
+	Timestamp string               `json:"timestamp"`
+}
+
+// HardwareManager polls every FRU and aggregates their status into a chassis status
+type HardwareManager struct {
+	frus        []HardwareInterface
+	escalations map[string]*FruEscalation // by FRU name
+	present     map[string]bool           // presence of each FRU at the last poll
+	status      FruStatus                 // chassis status from the last poll
+	power       *powerRedundancy          // nil unless a required PSU count is configured
+	redis       StatusStore
+	batch       *metricsBatch // the FRUs' store, queueing their metrics writes during a poll
+	sources     []func() map[string]FruStatus
+	cooling     []*coolingCheck
+	notifier    *Notifier // nil when no webhooks are configured
+	logger      *Logger
+
+	escalation []EscalationStep // steps for FRUs added by a reload
+	metrics    *MetricsExporter // nil when the exporter is disabled
+	removed    []string         // present FRUs dropped by a reload, reported red by the next poll
+	pollMu     sync.Mutex       // held by a poll, so a reload swaps the FRUs between polls
+
+	mu   sync.Mutex
+	last ChassisStatus // result of the last poll, for status dumps
+}
+
+// NewHardwareManager creates the FRUs listed in config
+func NewHardwareManager(config HardwareConfig, escalation []EscalationStep, redis StatusStore, metrics *MetricsExporter, logger *Logger) *HardwareManager {
+	hm := &HardwareManager{
+		status:     FruStatusGreen,
+		redis:      redis,
+		batch:      &metricsBatch{StatusStore: redis},
+		escalation: escalation,
+		metrics:    metrics,
+		logger:     logger,
+	}
+
+	frus, psus, fans := hm.buildFrus(config)
+	hm.frus = frus
+	hm.escalations = make(map[string]*FruEscalation, len(frus))
+	hm.present = make(map[string]bool, len(frus))
+	for _, fru := range frus {
+		hm.escalations[fru.getName()] = newFruEscalation(escalation)
+		hm.present[fru.getName()] = true
+	}
+	hm.power = newPowerRedundancy(config.PSUs, psus)
+	hm.cooling = newCoolingChecks(config.Cooling, fans)
+	logger.Info("Monitoring %d FRUs in %d fan, %d PSU and %d NPU slots",
+		len(frus), config.Fans.Count, config.PSUs.Count, config.NPUs.Count)
+	return hm
+}
+
+// buildFrus creates the FRUs listed in config, returning them along with the names of
+// the PSUs and the fans by slot. The FRUs write through the batch so a poll stores all
+// their metrics in one round trip.
+func (hm *HardwareManager) buildFrus(config HardwareConfig) (frus []HardwareInterface, psus []string, fans map[int]*Fan) {
+	thresholds := config.Thresholds.withDefaults()
+
+	psuSource := config.PSUs.Source
+	if psuSource == PSUSourceIPMI {
+		if _, err := exec.LookPath("ipmitool"); err != nil {
+			hm.logger.Error("PSU source is ipmi but ipmitool is not available, using simulated readings: %v", err)
+			psuSource = PSUSourceSimulated
+		}
+	}
+
+	fans = make(map[int]*Fan)
+	for i := 0; i < config.Fans.Count; i++ {
+		if !config.Fans.expected(i) {
+			continue
+		}
+		fan := NewFan("fan", i, thresholds.Fan, hm.logger, hm.batch, hm.metrics)
+		fan.setReadRetry(config.ReadRetry)
+		if path := config.Fans.presencePath(i); path != "" {
+			fan.setPresencePath(path)
+		}
+		if config.Fans.Source == FanSourceSimulated {
+			fan.setSource(fan.simulatedFan)
+		} else if hw, ok := config.Fans.hwmonFanFor(i); ok {
+			fan.setHwmon(hw)
+		} else {
+			fan.setSource(fan.simulatedFan)
+			hm.logger.Info("No hwmon input found for fan %d, using simulated readings", i)
+		}
+		frus = append(frus, fan)
+		fans[i] = fan
+	}
+	for i := 0; i < config.PSUs.Count; i++ {
+		if !config.PSUs.expected(i) {
+			continue
+		}
+		psu := NewPSU("psu", i, thresholds.PSU, hm.logger, hm.batch, hm.metrics)
+		psu.setReadRetry(config.ReadRetry)
+		if path := config.PSUs.presencePath(i); path != "" {
+			psu.setPresencePath(path)
+		}
+		if psuSource == PSUSourceIPMI {
+			psu.setSource(ipmiPSUSource(config.PSUs.IPMI.sensors(i)))
+		}
+		frus = append(frus, psu)
+		psus = append(psus, psu.getName())
+	}
+	for i := 0; i < config.NPUs.Count; i++ {
+		if !config.NPUs.expected(i) {
+			continue
+		}
+		npu := NewNPU("npu", i, thresholds.NPU, hm.logger, hm.batch, hm.metrics)
+		npu.setReadRetry(config.ReadRetry)
+		if path := config.NPUs.presencePath(i); path != "" {
+			npu.setPresencePath(path)
+		}
+		frus = append(frus, npu)
+	}
+	return frus, psus, fans
+}
+
+// HardwareReloadResult lists the FRUs a hardware reload added and removed
+type HardwareReloadResult struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// Reload re-enumerates the FRUs from config, for PSUs or fans hot-swapped at runtime.
+// Every FRU is recreated so changed settings apply, but FRUs that stay keep their
+// escalation and presence state. A removed FRU that was present is logged and reported
+// red by the next poll, then dropped. The swap waits for a running poll to finish, so a
+// poll always sees either the old or the new set.
+func (hm *HardwareManager) Reload(config HardwareConfig) HardwareReloadResult {
+	frus, psus, fans := hm.buildFrus(config)
+
+	hm.pollMu.Lock()
+	defer hm.pollMu.Unlock()
+
+	var result HardwareReloadResult
+	escalations := make(map[string]*FruEscalation, len(frus))
+	present := make(map[string]bool, len(frus))
+	for _, fru := range frus {
+		name := fru.getName()
+		if escalation, ok := hm.escalations[name]; ok {
+			escalations[name] = escalation
+			present[name] = hm.present[name]
+			continue
+		}
+		escalations[name] = newFruEscalation(hm.escalation)
+		present[name] = true
+		result.Added = append(result.Added, name)
+	}
+	for _, fru := range hm.frus {
+		name := fru.getName()
+		if _, ok := escalations[name]; ok {
+			continue
+		}
+		result.Removed = append(result.Removed, name)
+		if hm.present[name] {
+			hm.logger.Critical("FRU %s was present and is no longer configured", name)
+			hm.removed = append(hm.removed, name)
+		}
+	}
+
+	// Keep the last verdicts so unchanged conditions aren't logged again as changes
+	power := newPowerRedundancy(config.PSUs, psus)
+	if power != nil && hm.power != nil {
+		power.status = hm.power.status
+	}
+	cooling := newCoolingChecks(config.Cooling, fans)
+	for _, check := range cooling {
+		for _, old := range hm.cooling {
+			if old.sensor.Name == check.sensor.Name {
+				check.status = old.status
+			}
+		}
+	}
+	// The old fans give up any boost; a sensor still critical boosts the new ones next poll
+	for _, old := range hm.cooling {
+		old.release(hm.logger)
+	}
+
+	hm.frus = frus
+	hm.escalations = escalations
+	hm.present = present
+	hm.power = power
+	hm.cooling = cooling
+	hm.logger.Info("Reloaded hardware config: monitoring %d FRUs, added %v, removed %v",
+		len(frus), result.Added, result.Removed)
+	return result
+}
+
+// severity orders FRU statuses from best to worst
+func severity(status FruStatus) int {
+	switch status {
+	case FruStatusGreen:
+		return 0
+	case FruStatusYellow:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// SetNotifier sets where webhook events about FRUs are sent
+func (hm *HardwareManager) SetNotifier(notifier *Notifier) {
+	hm.notifier = notifier
+}
+
+// AddStatusSource includes the statuses returned by source, keyed by component name, in
+// the chassis status. Sources are read on every poll and are not escalated like FRUs.
+func (hm *HardwareManager) AddStatusSource(source func() map[string]FruStatus) {
+	hm.sources = append(hm.sources, source)
+}
+
+// Poll updates the metrics of every FRU and stores the chassis status in Redis.
+// The chassis is red if any FRU or other status source is red, yellow if any is yellow
+// and green otherwise.
+func (hm *HardwareManager) Poll(ctx context.Context) {
+	hm.pollMu.Lock()
+	defer hm.pollMu.Unlock()
+
+	if len(hm.frus) == 0 && len(hm.sources) == 0 && len(hm.removed) == 0 {
+		return
+	}
+
+	chassis := ChassisStatus{
+		Status: FruStatusGreen,
+		Frus:   make(map[string]FruStatus, len(hm.frus)),
+	}
+	now := time.Now()
+	hm.batch.begin()
+	for _, fru := range hm.frus {
+		name := fru.getName()
+		status, err := fru.getStatus(ctx)
+
+		// Every polled FRU is expected, so a missing one is critical
+		if present := fru.available(); present != hm.present[name] {
+			if present {
+				hm.logger.Info("FRU %s is present again", name)
+			} else {
+				hm.logger.Critical("FRU %s is expected but not present", name)
+			}
+			hm.present[name] = present
+		} else if err != nil && present {
+			hm.logger.Error("Error getting status of FRU %s: %v", name, err)
+		}
+
+		hm.escalations[name].observe(name, status, now, hm.logger)
+		chassis.Frus[name] = status
+		if severity(status) > severity(chassis.Status) {
+			chassis.Status = status
+		}
+	}
+	hm.batch.flush(ctx, hm.logger)
+	for _, name := range hm.removed {
+		chassis.Frus[name] = FruStatusRed
+		chassis.Status = FruStatusRed
+	}
+	hm.removed = nil
+
+	// Losing a spare PSU degrades the chassis even while every present PSU is green
+	if hm.power != nil {
+		power := hm.power.evaluate(chassis.Frus, now, hm.logger)
+		hm.power.store(ctx, hm.redis, power, hm.logger)
+		chassis.Frus[powerRedundancyName] = power.Status
+		if severity(power.Status) > severity(chassis.Status) {
+			chassis.Status = power.Status
+		}
+	}
+
+	// Fans and sensors that each look fine can together show blocked airflow
+	for _, check := range hm.cooling {
+		status, err := check.evaluate(hm.logger)
+		if err != nil {
+			hm.logger.Error("Error reading temperature sensor %s: %v", check.sensor.Name, err)
+		}
+		chassis.Frus[check.name()] = status
+		if severity(status) > severity(chassis.Status) {
+			chassis.Status = status
+		}
+	}
+	for _, source := range hm.sources {
+		for name, status := range source() {
+			chassis.Frus[name] = status
+			if severity(status) > severity(chassis.Status) {
+				chassis.Status = status
+			}
+		}
+	}
+	chassis.Timestamp = now.Format(time.RFC3339)
+
+	switch {
+	case chassis.Status == hm.status:
+		hm.logger.Debug("Chassis status: %s", chassis.Status)
+	case chassis.Status == FruStatusRed:
+		hm.logger.Critical("Chassis status changed: %s -> %s", hm.status, chassis.Status)
+	default:
+		hm.logger.Info("Chassis status changed: %s -> %s", hm.status, chassis.Status)
+	}
+	hm.status = chassis.Status
+	hm.mu.Lock()
+	previous := hm.last.Frus
+	hm.last = chassis
+	hm.mu.Unlock()
+	hm.notifyRed(previous, chassis.Frus)
+
+	statusJSON, err := json.Marshal(chassis)
+	if err != nil {
+		hm.logger.Error("Error marshaling chassis status: %v", err)
+		return
+	}
+	if err := hm.redis.UpdateChassisStatus(ctx, string(statusJSON)); err != nil {
+		hm.logger.Error("Error updating chassis status in Redis: %v", err)
+	}
+}
+
+// notifyRed sends a webhook and Kafka event for every component that turned red or
+// stopped being red since the previous poll
+func (hm *HardwareManager) notifyRed(previous, current map[string]FruStatus) {
+	for name, status := range current {
+		wasRed := previous[name] == FruStatusRed
+		switch {
+		case status == FruStatusRed && !wasRed:
+			hm.notifier.Notify(WebhookEvent{
+				Event:     WebhookEventFruRed,
+				FRU:       name,
+				OldStatus: string(previous[name]),
+				NewStatus: string(status),
+				Message:   fmt.Sprintf("FRU %s is red", name),
+			})
+			hm.notifier.PublishEvent(WebhookEventFruRed, fruTransitionEvent(name, previous[name], status))
+		case status != FruStatusRed && wasRed:
+			hm.notifier.Notify(WebhookEvent{
+				Event:     WebhookEventFruRecovered,
+				FRU:       name,
+				OldStatus: string(FruStatusRed),
+				NewStatus: string(status),
+				Message:   fmt.Sprintf("FRU %s is no longer red, now %s", name, status),
+			})
+			hm.notifier.PublishEvent(WebhookEventFruRecovered, fruTransitionEvent(name, FruStatusRed, status))
+		}
+	}
+}
@@ -14,6 +14,12 @@ const (
 	FruStatusRed FruStatus = "red"
 )
 
+// hardwareMetricsSchemaVersion is the current shape of the FRU metrics stored in Redis,
+// written with every sample. Bump it when a field of FanMetrics, PSUMetrics or NPUMetrics
+// is removed or changes type or meaning; adding a field does not need a bump. Samples
+// from before versioning have no schema_version and read as 0.
+const hardwareMetricsSchemaVersion = 1
+
 // HardwareInterface defines methods for hardware monitoring
 type HardwareInterface interface {
 	// getName returns the name of the hardware component
@@ -24,8 +30,8 @@ type HardwareInterface interface {
 	getStatus(ctx context.Context) (FruStatus, error)
 
 	// updateMetrics updates the hardware metrics
-	// Returns: true if metrics were successfully updated, false otherwise
-	updateMetrics(ctx context.Context) bool
+	// Returns: an error if the metrics could not be read or stored
+	updateMetrics(ctx context.Context) error
 
 	// available checks if the hardware is available for monitoring
 	// Returns: true if hardware is available, false otherwise
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+// fakeFrus returns a fan, PSU and NPU reading fixed values into store, held as
+// HardwareInterface the way HardwareManager holds them
+func fakeFrus(store StatusStore) []HardwareInterface {
+	thresholds := ThresholdsConfig{}.withDefaults()
+	logger := newTestLogger()
+
+	fan := NewFan("fan", 0, thresholds.Fan, logger, store, nil)
+	fan.setSource(func(ctx context.Context) (FanMetrics, error) {
+		return FanMetrics{RotorSpeeds: []int{3000, 3100}, Duty: 50}, nil
+	})
+	psu := NewPSU("psu", 0, thresholds.PSU, logger, store, nil)
+	psu.setSource(func(ctx context.Context) (PSUMetrics, error) {
+		return PSUMetrics{Voltage: 12, Current: 20, Power: 240}, nil
+	})
+	npu := NewNPU("npu", 0, thresholds.NPU, logger, store, nil)
+	npu.setSource(func(ctx context.Context) (NPUMetrics, error) {
+		return NPUMetrics{PacketRate: 1e6, Throughput: 10, BufferUsage: 20, ProcessorUsage: 30}, nil
+	})
+	return []HardwareInterface{fan, psu, npu}
+}
+
+func TestHardwareInterfaceImplementations(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	for i, fru := range fakeFrus(store) {
+		fru.setInstance(i + 1)
+		name := fru.getName()
+		if !fru.available() {
+			t.Errorf("%s not available", name)
+		}
+		if err := fru.updateMetrics(ctx); err != nil {
+			t.Errorf("%s updateMetrics: %v", name, err)
+		}
+		status, err := fru.getStatus(ctx)
+		if err != nil || status != FruStatusGreen {
+			t.Errorf("%s getStatus = %s, %v; want green", name, status, err)
+		}
+	}
+
+	// setInstance renumbers each FRU, which shows in its name and metrics key
+	for i, fruType := range []string{"fan", "psu", "npu"} {
+		key := fmt.Sprintf("hardware:%s:%d:metrics", fruType, i+1)
+		if _, err := store.Get(key); err != nil {
+			t.Errorf("no metrics stored at %s: %v", key, err)
+		}
+	}
+}
+
+func TestHardwareInterfaceReadFailure(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	thresholds := ThresholdsConfig{}.withDefaults()
+	logger := newTestLogger()
+
+	// A missing sensor fails the read without retrying
+	missing := fmt.Errorf("sensor gone: %w", fs.ErrNotExist)
+	fan := NewFan("fan", 0, thresholds.Fan, logger, store, nil)
+	fan.setSource(func(ctx context.Context) (FanMetrics, error) { return FanMetrics{}, missing })
+	psu := NewPSU("psu", 0, thresholds.PSU, logger, store, nil)
+	psu.setSource(func(ctx context.Context) (PSUMetrics, error) { return PSUMetrics{}, missing })
+	npu := NewNPU("npu", 0, thresholds.NPU, logger, store, nil)
+	npu.setSource(func(ctx context.Context) (NPUMetrics, error) { return NPUMetrics{}, missing })
+
+	for _, fru := range []HardwareInterface{fan, psu, npu} {
+		name := fru.getName()
+		if err := fru.updateMetrics(ctx); err == nil {
+			t.Errorf("%s updateMetrics succeeded with a failing source", name)
+		}
+		if status, _ := fru.getStatus(ctx); status != FruStatusRed {
+			t.Errorf("%s getStatus = %s, want red", name, status)
+		}
+	}
+}
+
+func TestFanWithoutTachInputsIsSimulated(t *testing.T) {
+	ctx := context.Background()
+	fan := NewFan("fan", 0, ThresholdsConfig{}.withDefaults().Fan, newTestLogger(), NewMemoryStore(), nil)
+
+	metrics, err := fan.defaultSource(ctx)
+	if err != nil {
+		t.Fatalf("defaultSource without tach inputs: %v", err)
+	}
+	if len(metrics.RotorSpeeds) != 1 || metrics.RotorSpeeds[0] != 2000 {
+		t.Errorf("rotor speeds %v, want the simulated 2000 RPM", metrics.RotorSpeeds)
+	}
+
+	// A configured tach input that can't be read is an error rather than simulated
+	fan.setTachInputs([]string{"/nonexistent/fan1_input"})
+	if _, err := fan.defaultSource(ctx); err == nil {
+		t.Error("defaultSource read a missing tach input")
+	}
+}
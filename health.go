@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"time"
+)
+
+const (
+	defaultHealthTimeout          = 5 * time.Second
+	defaultHealthFailureThreshold = 3
+)
+
+// HealthCheck probes whether a running process is actually working. Exactly one of
+// Command, HTTP and TCP must be set.
+type HealthCheck struct {
+	Command          []string `json:"command,omitempty"`          // exits 0 when healthy
+	HTTP             string   `json:"http,omitempty"`             // URL that answers GET with a 2xx or 3xx status
+	TCP              string   `json:"tcp,omitempty"`              // host:port that accepts connections
+	Timeout          Duration `json:"timeout,omitempty"`          // per probe, default 5s
+	Interval         Duration `json:"interval,omitempty"`         // minimum time between probes, default every check
+	FailureThreshold int      `json:"failureThreshold,omitempty"` // consecutive failures before unhealthy, default 3
+}
+
+// HealthResult is the outcome of the latest health probe of a process
+type HealthResult struct {
+	Passed              bool      `json:"passed"`
+	Error               string    `json:"error,omitempty"`
+	Timestamp           time.Time `json:"timestamp"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+}
+
+// validate checks that exactly one probe is configured
+func (hc *HealthCheck) validate() error {
+	probes := 0
+	for _, set := range []bool{len(hc.Command) > 0, hc.HTTP != "", hc.TCP != ""} {
+		if set {
+			probes++
+		}
+	}
+	if probes != 1 {
+		return errors.New("health check needs exactly one of command, http or tcp")
+	}
+	return nil
+}
+
+// unhealthy reports whether a result has failed often enough to mark the process unhealthy
+func (hc *HealthCheck) unhealthy(result *HealthResult) bool {
+	threshold := hc.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultHealthFailureThreshold
+	}
+	return result.ConsecutiveFailures >= threshold
+}
+
+// checkHealth runs the health check of a running process unless the previous result is
+// younger than the check interval. A new PID always gets a fresh probe and failure count.
+func (pm *ProcessMonitor) checkHealth(ctx context.Context, proc Process, previous *HealthResult, pidChanged bool) *HealthResult {
+	hc := proc.HealthCheck
+	if pidChanged {
+		previous = nil
+	}
+	if previous != nil && time.Since(previous.Timestamp) < hc.Interval.Duration {
+		return previous
+	}
+
+	timeout := hc.Timeout.Duration
+	if timeout <= 0 {
+		timeout = defaultHealthTimeout
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := &HealthResult{Passed: true, Timestamp: time.Now()}
+	if err := probeHealth(probeCtx, hc); err != nil {
+		result.Passed = false
+		result.Error = err.Error()
+		result.ConsecutiveFailures = 1
+		if previous != nil {
+			result.ConsecutiveFailures += previous.ConsecutiveFailures
+		}
+	}
+	return result
+}
+
+// probeHealth runs a single health probe
+func probeHealth(ctx context.Context, hc *HealthCheck) error {
+	switch {
+	case len(hc.Command) > 0:
+		output, err := exec.CommandContext(ctx, hc.Command[0], hc.Command[1:]...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("command failed: %v: %s", err, truncateOutput(output))
+		}
+		return nil
+
+	case hc.HTTP != "":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, hc.HTTP, nil)
+		if err != nil {
+			return fmt.Errorf("invalid health check URL: %v", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("HTTP request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("HTTP status %s", resp.Status)
+		}
+		return nil
+
+	default:
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", hc.TCP)
+		if err != nil {
+			return fmt.Errorf("TCP connect failed: %v", err)
+		}
+		conn.Close()
+		return nil
+	}
+}
+
+// truncateOutput shortens probe output for status and log messages
+func truncateOutput(output []byte) string {
+	const max = 256
+	if len(output) > max {
+		return string(output[:max]) + "..."
+	}
+	return string(output)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestLogger returns a logger that writes every level to stderr only, without
+// connecting to syslog
+func newTestLogger() *Logger {
+	return &Logger{level: LevelDebug, dedup: newLogDedup()}
+}
+
+// fakeProber is a ProcessProber whose processes are set by the test. Every running
+// instance reports the same memory, CPU time and thread and file descriptor counts.
+type fakeProber struct {
+	mu      sync.Mutex
+	pids    map[string][]int         // running instances by process name
+	errs    map[string]error         // returned by PIDs for a process
+	blocked map[string]chan struct{} // PIDs for a process waits until the channel is closed
+	probed  map[string]int           // PIDs calls by process name
+}
+
+// fakeStartTime is when every fake process started
+var fakeStartTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newFakeProber() *fakeProber {
+	return &fakeProber{
+		pids:    make(map[string][]int),
+		errs:    make(map[string]error),
+		blocked: make(map[string]chan struct{}),
+		probed:  make(map[string]int),
+	}
+}
+
+// set makes pids the running instances of a process, none meaning it is down
+func (p *fakeProber) set(name string, pids ...int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pids[name] = pids
+	delete(p.errs, name)
+}
+
+// fail makes finding a process return err
+func (p *fakeProber) fail(name string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.errs[name] = err
+}
+
+// block makes finding a process hang until the returned function is called
+func (p *fakeProber) block(name string) (release func()) {
+	ch := make(chan struct{})
+	p.mu.Lock()
+	p.blocked[name] = ch
+	p.mu.Unlock()
+	var once sync.Once
+	return func() { once.Do(func() { close(ch) }) }
+}
+
+// calls returns how often a process has been looked up
+func (p *fakeProber) calls(name string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.probed[name]
+}
+
+func (p *fakeProber) PIDs(proc Process) ([]int, error) {
+	p.mu.Lock()
+	p.probed[proc.Name]++
+	ch := p.blocked[proc.Name]
+	pids, err := append([]int(nil), p.pids[proc.Name]...), p.errs[proc.Name]
+	p.mu.Unlock()
+	if ch != nil {
+		<-ch
+	}
+	return pids, err
+}
+
+func (p *fakeProber) Memory(pids []int) (int64, error) {
+	return int64(len(pids)) << 20, nil
+}
+
+func (p *fakeProber) CPUTimes(pids []int) (map[int]time.Duration, error) {
+	times := make(map[int]time.Duration, len(pids))
+	for _, pid := range pids {
+		times[pid] = time.Second
+	}
+	return times, nil
+}
+
+func (p *fakeProber) Threads(pid int) (int, error) { return 4, nil }
+
+func (p *fakeProber) OpenFDs(pid int) (int, error) { return 10, nil }
+
+func (p *fakeProber) Env(pid int) (map[string]string, error) { return map[string]string{}, nil }
+
+func (p *fakeProber) StartTime(pid int) (time.Time, error) { return fakeStartTime, nil }
+
+// newTestMonitor returns a monitor of processes that stores statuses in a MemoryStore
+// and probes them through a fakeProber
+func newTestMonitor(processes []Process, config MonitoringConfig) (*ProcessMonitor, *MemoryStore, *fakeProber) {
+	store := NewMemoryStore()
+	prober := newFakeProber()
+	pm := NewProcessMonitor(processes, config, store, nil, newTestLogger())
+	pm.prober = prober
+	return pm, store, prober
+}
+
+// fakeRedis is a minimal RESP server standing in for Redis in tests. It answers PING
+// with PONG, confirms SUBSCRIBE and UNSUBSCRIBE, answers every other command with OK,
+// and records the commands it was sent.
+type fakeRedis struct {
+	listener net.Listener
+
+	mu         sync.Mutex
+	commands   [][]string
+	conns      map[net.Conn]bool // open connections, true once subscribed
+	rejectSubs int               // SUBSCRIBE commands still to answer with an error
+}
+
+// startFakeRedis serves connections accepted from listener until the test ends
+func startFakeRedis(t testing.TB, listener net.Listener) *fakeRedis {
+	t.Helper()
+	f := &fakeRedis{listener: listener, conns: make(map[net.Conn]bool)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns[conn] = false
+			f.mu.Unlock()
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+// subscribers returns how many open connections have subscribed to a channel
+func (f *fakeRedis) subscribers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, subscribed := range f.conns {
+		if subscribed {
+			n++
+		}
+	}
+	return n
+}
+
+// dropConnections closes every open connection, as a Redis restart would
+func (f *fakeRedis) dropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for conn := range f.conns {
+		conn.Close()
+	}
+}
+
+// rejectSubscribes makes the next n SUBSCRIBE commands fail
+func (f *fakeRedis) rejectSubscribes(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rejectSubs = n
+}
+
+// addr returns the host and port the fake listens on
+func (f *fakeRedis) addr() (string, int) {
+	tcp := f.listener.Addr().(*net.TCPAddr)
+	return tcp.IP.String(), tcp.Port
+}
+
+// received returns the commands sent so far, each as its name and arguments
+func (f *fakeRedis) received() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string(nil), f.commands...)
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer func() {
+		f.mu.Lock()
+		delete(f.conns, conn)
+		f.mu.Unlock()
+		conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readRESPCommand(reader)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args)
+		f.mu.Unlock()
+
+		reply := "+OK\r\n"
+		switch strings.ToLower(args[0]) {
+		case "ping":
+			reply = "+PONG\r\n"
+		case "subscribe":
+			reply = f.subscribe(conn, args[1:])
+		case "unsubscribe":
+			var b strings.Builder
+			for _, channel := range args[1:] {
+				fmt.Fprintf(&b, "*3\r\n$11\r\nunsubscribe\r\n$%d\r\n%s\r\n:0\r\n", len(channel), channel)
+			}
+			reply = b.String()
+		}
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// subscribe marks conn as subscribed and returns the confirmation of each channel, or
+// an error while SUBSCRIBE commands are being rejected
+func (f *fakeRedis) subscribe(conn net.Conn, channels []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rejectSubs > 0 {
+		f.rejectSubs--
+		return "-ERR subscriptions are disabled\r\n"
+	}
+	f.conns[conn] = true
+
+	var b strings.Builder
+	for i, channel := range channels {
+		fmt.Fprintf(&b, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:%d\r\n", len(channel), channel, i+1)
+	}
+	return b.String()
+}
+
+// readRESPCommand reads one command sent as a RESP array of bulk strings
+func readRESPCommand(reader *bufio.Reader) ([]string, error) {
+	n, err := readRESPLength(reader, '*')
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		size, err := readRESPLength(reader, '$')
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return args, nil
+}
+
+// readRESPLength reads a line such as *3 or $5 and returns its number
+func readRESPLength(reader *bufio.Reader, prefix byte) (int, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) < 2 || line[0] != prefix {
+		return 0, fmt.Errorf("unexpected RESP line %q", line)
+	}
+	return strconv.Atoi(line[1:])
+}
@@ -0,0 +1,243 @@
+// gRPC control interface of hostd, an alternative to the hostd:commands Redis channel.
+//
+// Regenerate hostd.pb.go and hostd_grpc.pb.go after changing this file with:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	    --go-grpc_out=. --go-grpc_opt=paths=source_relative hostd.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: hostd.proto
+
+package main
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	ProcessControl_Start_FullMethodName     = "/hostd.v1.ProcessControl/Start"
+	ProcessControl_Stop_FullMethodName      = "/hostd.v1.ProcessControl/Stop"
+	ProcessControl_Restart_FullMethodName   = "/hostd.v1.ProcessControl/Restart"
+	ProcessControl_GetStatus_FullMethodName = "/hostd.v1.ProcessControl/GetStatus"
+)
+
+// ProcessControlClient is the client API for ProcessControl service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ProcessControl starts, stops and inspects the processes listed in processes.json
+type ProcessControlClient interface {
+	// Start launches a process, after starting any of its dependencies that are down
+	Start(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*CommandResponse, error)
+	// Stop stops a process, after stopping the processes that depend on it
+	Stop(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*CommandResponse, error)
+	// Restart restarts a process together with the rest of its restart group
+	Restart(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*CommandResponse, error)
+	// GetStatus returns the status of a process as stored by the last check
+	GetStatus(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+}
+
+type processControlClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewProcessControlClient(cc grpc.ClientConnInterface) ProcessControlClient {
+	return &processControlClient{cc}
+}
+
+func (c *processControlClient) Start(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*CommandResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CommandResponse)
+	err := c.cc.Invoke(ctx, ProcessControl_Start_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *processControlClient) Stop(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*CommandResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CommandResponse)
+	err := c.cc.Invoke(ctx, ProcessControl_Stop_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *processControlClient) Restart(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*CommandResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CommandResponse)
+	err := c.cc.Invoke(ctx, ProcessControl_Restart_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *processControlClient) GetStatus(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, ProcessControl_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ProcessControlServer is the server API for ProcessControl service.
+// All implementations must embed UnimplementedProcessControlServer
+// for forward compatibility
+//
+// ProcessControl starts, stops and inspects the processes listed in processes.json
+type ProcessControlServer interface {
+	// Start launches a process, after starting any of its dependencies that are down
+	Start(context.Context, *ProcessRequest) (*CommandResponse, error)
+	// Stop stops a process, after stopping the processes that depend on it
+	Stop(context.Context, *ProcessRequest) (*CommandResponse, error)
+	// Restart restarts a process together with the rest of its restart group
+	Restart(context.Context, *ProcessRequest) (*CommandResponse, error)
+	// GetStatus returns the status of a process as stored by the last check
+	GetStatus(context.Context, *ProcessRequest) (*StatusResponse, error)
+	mustEmbedUnimplementedProcessControlServer()
+}
+
+// UnimplementedProcessControlServer must be embedded to have forward compatible implementations.
+type UnimplementedProcessControlServer struct {
+}
+
+func (UnimplementedProcessControlServer) Start(context.Context, *ProcessRequest) (*CommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Start not implemented")
+}
+func (UnimplementedProcessControlServer) Stop(context.Context, *ProcessRequest) (*CommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stop not implemented")
+}
+func (UnimplementedProcessControlServer) Restart(context.Context, *ProcessRequest) (*CommandResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Restart not implemented")
+}
+func (UnimplementedProcessControlServer) GetStatus(context.Context, *ProcessRequest) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedProcessControlServer) mustEmbedUnimplementedProcessControlServer() {}
+
+// UnsafeProcessControlServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ProcessControlServer will
+// result in compilation errors.
+type UnsafeProcessControlServer interface {
+	mustEmbedUnimplementedProcessControlServer()
+}
+
+func RegisterProcessControlServer(s grpc.ServiceRegistrar, srv ProcessControlServer) {
+	s.RegisterService(&ProcessControl_ServiceDesc, srv)
+}
+
+func _ProcessControl_Start_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProcessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessControlServer).Start(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProcessControl_Start_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessControlServer).Start(ctx, req.(*ProcessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProcessControl_Stop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProcessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessControlServer).Stop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProcessControl_Stop_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessControlServer).Stop(ctx, req.(*ProcessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProcessControl_Restart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProcessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessControlServer).Restart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProcessControl_Restart_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessControlServer).Restart(ctx, req.(*ProcessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ProcessControl_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProcessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProcessControlServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ProcessControl_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProcessControlServer).GetStatus(ctx, req.(*ProcessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ProcessControl_ServiceDesc is the grpc.ServiceDesc for ProcessControl service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ProcessControl_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "hostd.v1.ProcessControl",
+	HandlerType: (*ProcessControlServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Start",
+			Handler:    _ProcessControl_Start_Handler,
+		},
+		{
+			MethodName: "Stop",
+			Handler:    _ProcessControl_Stop_Handler,
+		},
+		{
+			MethodName: "Restart",
+			Handler:    _ProcessControl_Restart_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _ProcessControl_GetStatus_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "hostd.proto",
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	defaultHTTPListen = ":8080"
+	defaultHealthPath = "/healthz"
+	defaultReadyPath  = "/readyz"
+)
+
+// statusAPI serves process and hardware state stored in Redis over HTTP
+type statusAPI struct {
+	redis   *RedisClient
+	monitor *ProcessMonitor
+	runner  *PeriodicRunner
+	logger  *Logger
+}
+
+// StartHTTPServer starts the HTTP status API on addr. The server shuts down when ctx is
+// cancelled; the returned channel is closed once shutdown is complete.
+func StartHTTPServer(ctx context.Context, config HTTPConfig, redis *RedisClient, monitor *ProcessMonitor, runner *PeriodicRunner, logger *Logger) (<-chan struct{}, error) {
+	if config.Listen == "" {
+		config.Listen = defaultHTTPListen
+	}
+	if config.HealthPath == "" {
+		config.HealthPath = defaultHealthPath
+	}
+	if config.ReadyPath == "" {
+		config.ReadyPath = defaultReadyPath
+	}
+
+	api := &statusAPI{redis: redis, monitor: monitor, runner: runner, logger: logger}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/processes", api.handleProcesses)
+	mux.HandleFunc("/processes/", api.handleProcess)
+	mux.HandleFunc("/hardware", api.handleHardware)
+	mux.HandleFunc("/hardware/", api.handleHardwareHistory)
+	mux.HandleFunc("/export.csv", api.handleExportCSV)
+	mux.HandleFunc("/system", api.handleSystem)
+	mux.HandleFunc("/status", api.handleStatus)
+	mux.HandleFunc(config.HealthPath, api.handleHealth)
+	mux.HandleFunc(config.ReadyPath, api.handleReady)
+
+	return serveHTTP(ctx, "HTTP status API", config.Listen, mux, logger)
+}
+
+// serveHTTP serves handler on addr until ctx is cancelled. It binds before returning so
+// a bad address fails startup; the returned channel is closed once shutdown is complete.
+func serveHTTP(ctx context.Context, name, addr string, handler http.Handler, logger *Logger) (<-chan struct{}, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	done := make(chan struct{})
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("%s server error: %v", name, err)
+		}
+	}()
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Error shutting down %s server: %v", name, err)
+		}
+	}()
+
+	logger.Info("%s listening on %s", name, listener.Addr())
+	return done, nil
+}
+
+// handleProcesses serves GET /processes with the stored status of every configured
+// process, fetched in one round trip. Processes not checked yet are left out. Repeated
+// label=key=value query parameters limit it to processes carrying all those labels.
+func (a *statusAPI) handleProcesses(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	want, err := parseLabelSelectors(r.URL.Query()["label"])
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	processes := a.monitor.Processes()
+	names := make([]string, 0, len(processes))
+	for _, proc := range processes {
+		names = append(names, proc.Name)
+	}
+	sort.Strings(names)
+
+	statuses, err := a.redis.GetAllProcessStatuses(r.Context(), names)
+	if err != nil {
+		a.fail(w, "getting process statuses", err)
+		return
+	}
+
+	result := make([]*ProcessStatus, 0, len(statuses))
+	for _, name := range names {
+		status, ok := statuses[name]
+		if !ok || !matchLabels(status.Labels, want) {
+			continue
+		}
+		result = append(result, status)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleProcess serves GET /processes/{name} with the status of one process,
+// GET /processes/{name}/logs and POST /processes/{name}/reset-stats
+func (a *statusAPI) handleProcess(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/processes/")
+	if name, ok := strings.CutSuffix(name, "/reset-stats"); ok {
+		a.handleResetStats(w, r, name)
+		return
+	}
+	if name, ok := strings.CutSuffix(name, "/logs"); ok {
+		a.handleProcessLogs(w, r, name)
+		return
+	}
+
+	if !allowGet(w, r) {
+		return
+	}
+	if name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	status, err := a.redis.GetProcessStatus(r.Context(), name)
+	if err == redis.Nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "process not found"})
+		return
+	}
+	if err != nil {
+		a.fail(w, "getting process status", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, json.RawMessage(status))
+}
+
+// handleResetStats serves POST /processes/{name}/reset-stats, restarting the memory and
+// CPU min/max tracking of a process
+func (a *statusAPI) handleResetStats(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !a.monitor.isConfigured(name) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "process not found"})
+		return
+	}
+	if err := a.monitor.ResetStats(r.Context(), name); err != nil {
+		a.fail(w, "resetting process stats", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleProcessLogs serves GET /processes/{name}/logs with the captured output of a process
+// hostd started, oldest line first. The lines query parameter limits it to the last N lines.
+func (a *statusAPI) handleProcessLogs(w http.ResponseWriter, r *http.Request, name string) {
+	if !allowGet(w, r) {
+		return
+	}
+	if name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if !a.monitor.isConfigured(name) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "process not found"})
+		return
+	}
+
+	n := 0
+	if value := r.URL.Query().Get("lines"); value != "" {
+		var err error
+		if n, err = strconv.Atoi(value); err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "lines must be a positive number"})
+			return
+		}
+	}
+
+	lines := a.monitor.OutputTail(name, n)
+	if lines == nil {
+		lines = []string{}
+	}
+	writeJSON(w, http.StatusOK, lines)
+}
+
+// handleHardware serves GET /hardware with the latest metrics of every FRU
+func (a *statusAPI) handleHardware(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	metrics, err := a.redis.ListHardwareMetrics(r.Context())
+	if err != nil {
+		a.fail(w, "listing hardware metrics", err)
+		return
+	}
+
+	result := make(map[string]json.RawMessage, len(metrics))
+	for fru, value := range metrics {
+		result[fru] = json.RawMessage(value)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleHardwareHistory serves GET /hardware/{type}/{instance}/history with the stored
+// metrics samples of one FRU, newest first
+func (a *statusAPI) handleHardwareHistory(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/hardware/"), "/")
+	if len(parts) != 3 || parts[0] == "" || parts[2] != "history" {
+		http.NotFound(w, r)
+		return
+	}
+	instance, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	samples, err := a.redis.GetHardwareHistory(r.Context(), parts[0], instance)
+	if err != nil {
+		a.fail(w, "getting hardware history", err)
+		return
+	}
+
+	result := make([]json.RawMessage, 0, len(samples))
+	for _, sample := range samples {
+		result = append(result, json.RawMessage(sample))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleSystem serves GET /system with the latest system load, memory and disk readings
+func (a *statusAPI) handleSystem(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	readings, err := a.redis.ListSystemStatus(r.Context())
+	if err != nil {
+		a.fail(w, "listing system status", err)
+		return
+	}
+
+	result := make(map[string]json.RawMessage, len(readings))
+	for name, value := range readings {
+		result[name] = json.RawMessage(value)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleStatus serves GET /status with the state of the daemon's Redis connection
+func (a *statusAPI) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"redis_healthy": a.redis.Healthy()})
+}
+
+// handleHealth serves the liveness endpoint: 200 while every periodic task keeps completing
+func (a *statusAPI) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	if !a.runner.Alive() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "check loop stalled"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReady serves the readiness endpoint: 200 once Redis is reachable and every
+// periodic task has run once
+func (a *statusAPI) handleReady(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	switch {
+	case !a.redis.Healthy():
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "redis unreachable"})
+	case !a.runner.Swept():
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "first check not complete"})
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}
+}
+
+// fail logs a request error and returns a 500
+func (a *statusAPI) fail(w http.ResponseWriter, action string, err error) {
+	a.logger.Error("HTTP API error %s: %v", action, err)
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+}
+
+// allowGet rejects anything but GET with a 405
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return false
+	}
+	return true
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
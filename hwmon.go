@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hwmonRoot is where the kernel lists hardware monitoring devices
+const hwmonRoot = "/sys/class/hwmon"
+
+// Fan reading sources
+const (
+	FanSourceSysfs     = "sysfs"
+	FanSourceSimulated = "simulated"
+)
+
+// FanGroupConfig describes the fans, and optionally where each reads its sysfs values.
+// Fans without sysfs settings use the first hwmon device exposing their channel, and
+// fall back to simulated values if there is none.
+type FanGroupConfig struct {
+	FruGroupConfig
+
+	Source string                 `json:"source,omitempty"` // sysfs (default, simulated where no hwmon input is found) or simulated
+	Sysfs  map[int]FanSysfsConfig `json:"sysfs,omitempty"`  // by slot
+}
+
+// FanSysfsConfig selects the hwmon files a fan is read from
+type FanSysfsConfig struct {
+	Hwmon   string `json:"hwmon"`   // hwmon device directory, or its name under /sys/class/hwmon such as hwmon2
+	Channel int    `json:"channel"` // reads fan<channel>_input and pwm<channel>, default slot+1
+
+	TachInputs []string `json:"tachInputs,omitempty"` // one tach input per rotor, absolute or relative to hwmon, default fan<channel>_input
+}
+
+// validate checks the fan source and sysfs settings
+func (g FanGroupConfig) validate() error {
+	if err := g.FruGroupConfig.validate("fans"); err != nil {
+		return err
+	}
+	switch g.Source {
+	case "", FanSourceSysfs, FanSourceSimulated:
+	default:
+		return fmt.Errorf("fans source %q is not sysfs or simulated", g.Source)
+	}
+	for slot, cfg := range g.Sysfs {
+		for _, input := range cfg.TachInputs {
+			if cfg.Hwmon == "" && !filepath.IsAbs(input) {
+				return fmt.Errorf("fans sysfs slot %d tach input %q is relative but no hwmon device is set", slot, input)
+			}
+		}
+	}
+	return nil
+}
+
+// hwmonFan is the resolved sysfs files of a fan
+type hwmonFan struct {
+	inputs    []string // speed in RPM of each rotor
+	pwm       string   // PWM duty 0-255, empty if the device has none
+	pwmEnable string   // PWM control mode, 1 for manual, empty if the device has none
+}
+
+// hwmonFanFor returns the sysfs files the fan in slot should be read from. A fan pinned
+// to a device or to tach inputs is always read from them, so a missing file turns it
+// red; otherwise ok is false when no hwmon device exposes the fan's channel.
+func (g FanGroupConfig) hwmonFanFor(slot int) (fan hwmonFan, ok bool) {
+	cfg := g.Sysfs[slot]
+	channel := cfg.Channel
+	if channel <= 0 {
+		channel = slot + 1
+	}
+
+	dir := cfg.Hwmon
+	switch {
+	case dir == "" && len(cfg.TachInputs) > 0:
+		// Absolute tach inputs without a device leave the fan without a PWM file
+		return hwmonFan{inputs: cfg.TachInputs}, true
+	case dir == "":
+		matches, _ := filepath.Glob(filepath.Join(hwmonRoot, "hwmon*", fmt.Sprintf("fan%d_input", channel)))
+		if len(matches) == 0 {
+			return hwmonFan{}, false
+		}
+		sort.Strings(matches)
+		dir = filepath.Dir(matches[0])
+	case !filepath.IsAbs(dir):
+		dir = filepath.Join(hwmonRoot, dir)
+	}
+
+	inputs := cfg.TachInputs
+	if len(inputs) == 0 {
+		inputs = []string{fmt.Sprintf("fan%d_input", channel)}
+	}
+	for _, input := range inputs {
+		if !filepath.IsAbs(input) {
+			input = filepath.Join(dir, input)
+		}
+		fan.inputs = append(fan.inputs, input)
+	}
+	if pwm := filepath.Join(dir, fmt.Sprintf("pwm%d", channel)); fruPresent(pwm) {
+		fan.pwm = pwm
+		if enable := pwm + "_enable"; fruPresent(enable) {
+			fan.pwmEnable = enable
+		}
+	}
+	return fan, true
+}
+
+// setHwmon makes the fan read its rotor speeds and duty from hwmon sysfs files, setDuty
+// write its PWM file and boosts switch its pwm_enable file to manual control
+func (f *Fan) setHwmon(hw hwmonFan) {
+	f.setTachInputs(hw.inputs)
+	f.pwmPath = hw.pwm
+	f.pwmEnablePath = hw.pwmEnable
+	if hw.pwm != "" {
+		f.logger.Info("Set fan %d PWM file to %s (enable: %s)", f.instance, hw.pwm, hw.pwmEnable)
+	}
+}
+
+// readSysfsInt reads a sysfs attribute holding one integer
+func readSysfsInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultInjectDuration = time.Minute
+	maxInjectDuration     = time.Hour
+)
+
+// syntheticStatus is a status forced on a process by an inject-status command
+type syntheticStatus struct {
+	status   string
+	previous ProcessStatus // stored status before the injection, restored when it reverts
+	timer    *time.Timer
+}
+
+// InjectStatus forces the stored status of a process to cmd.Status for cmd.Duration, to
+// test alert routing end to end. The change goes through the normal alert path: it is
+// logged, published on the event stream and sent to webhooks, all tagged as synthetic.
+// Status checks of the process pause meanwhile, so nothing is restarted, and the
+// previous status is restored when the duration is up. The command must carry the
+// configured injectStatusToken.
+func (pm *ProcessMonitor) InjectStatus(ctx context.Context, cmd Command) error {
+	token := pm.config.InjectStatusToken
+	if token == "" {
+		return errors.New("inject-status is disabled, set monitoring.injectStatusToken to enable it")
+	}
+	if subtle.ConstantTimeCompare([]byte(cmd.Token), []byte(token)) != 1 {
+		pm.logger.Error("Rejected inject-status command for process %s with a missing or wrong token", cmd.Process)
+		return errors.New("inject-status token is missing or wrong")
+	}
+
+	proc, err := pm.findProcess(cmd.Process)
+	if err != nil {
+		return err
+	}
+	switch cmd.Status {
+	case "up", "down", "unhealthy":
+	default:
+		return fmt.Errorf("status %q is not up, down or unhealthy", cmd.Status)
+	}
+	duration := cmd.Duration.Duration
+	if duration == 0 {
+		duration = defaultInjectDuration
+	}
+	if duration < 0 || duration > maxInjectDuration {
+		return fmt.Errorf("duration %v is outside 0-%v", duration, maxInjectDuration)
+	}
+
+	unlock := pm.lockProcess(proc.Name)
+	defer unlock()
+
+	current, err := pm.getProcStatus(ctx, proc.Name)
+	if err != nil {
+		return fmt.Errorf("error getting status of process %s: %v", proc.Name, err)
+	}
+
+	// A second injection extends the first; reverting restores the real status
+	synthetic := &syntheticStatus{status: cmd.Status, previous: *current}
+	pm.mu.Lock()
+	if active, ok := pm.synthetic[proc.Name]; ok {
+		active.timer.Stop()
+		synthetic.previous = active.previous
+	}
+	pm.synthetic[proc.Name] = synthetic
+	synthetic.timer = time.AfterFunc(duration, func() {
+		pm.revertSynthetic(context.Background(), proc, synthetic)
+	})
+	pm.mu.Unlock()
+
+	injected := *current
+	injected.Status = cmd.Status
+	injected.Synthetic = true
+	injected.LastChange = time.Now()
+	if err := pm.storeStatus(ctx, &injected); err != nil {
+		return err
+	}
+
+	level := pm.logger.Info
+	switch cmd.Status {
+	case "down":
+		level = pm.logger.Critical
+	case "unhealthy":
+		level = pm.logger.Error
+	}
+	pm.alert(proc, level, "[synthetic] Process %s status forced from %s to %s for %v by inject-status, this is a test",
+		proc.Name, current.Status, cmd.Status, duration)
+	pm.publishTransition(ctx, proc, current.Status, cmd.Status, current.CurrentPID, true)
+	return nil
+}
+
+// revertSynthetic restores the status a process had before synthetic was injected,
+// unless another injection has replaced it since
+func (pm *ProcessMonitor) revertSynthetic(ctx context.Context, proc Process, synthetic *syntheticStatus) {
+	unlock := pm.lockProcess(proc.Name)
+	defer unlock()
+
+	pm.mu.Lock()
+	if pm.synthetic[proc.Name] != synthetic {
+		pm.mu.Unlock()
+		return
+	}
+	delete(pm.synthetic, proc.Name)
+	pm.mu.Unlock()
+
+	if !pm.isConfigured(proc.Name) {
+		return // removed by a reload while the status was forced
+	}
+	restored := synthetic.previous
+	if err := pm.storeStatus(ctx, &restored); err != nil {
+		pm.logger.Error("Error restoring status of process %s after synthetic %s: %v", proc.Name, synthetic.status, err)
+		return
+	}
+	pm.logger.Info("[synthetic] Process %s status reverted from %s to %s, the test is over",
+		proc.Name, synthetic.status, restored.Status)
+	pm.publishTransition(ctx, proc, synthetic.status, restored.Status, restored.CurrentPID, true)
+}
+
+// syntheticActive reports whether a process currently has an injected status
+func (pm *ProcessMonitor) syntheticActive(name string) bool {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	_, ok := pm.synthetic[name]
+	return ok
+}
+
+// storeStatus writes a process status to the store and the metrics exporter
+func (pm *ProcessMonitor) storeStatus(ctx context.Context, status *ProcessStatus) error {
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("error marshaling status: %v", err)
+	}
+	if err := pm.redis.UpdateProcessStatus(ctx, status.Name, string(statusJSON)); err != nil {
+		return fmt.Errorf("error updating Redis: %v", err)
+	}
+	pm.metrics.ObserveProcess(status)
+	return nil
+}
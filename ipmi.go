@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PSU metric sources
+const (
+	PSUSourceSimulated = "simulated"
+	PSUSourceIPMI      = "ipmi"
+)
+
+// ipmiTimeout bounds one ipmitool run, which can hang on a wedged BMC
+const ipmiTimeout = 10 * time.Second
+
+// PSUGroupConfig describes the PSUs and where their readings come from
+type PSUGroupConfig struct {
+	FruGroupConfig
+
+	Source string          `json:"source,omitempty"` // simulated (default) or ipmi
+	IPMI   IPMISensorNames `json:"ipmi"`
+
+	Required   int `json:"required,omitempty"`   // PSUs needed to carry the load, 0 disables redundancy evaluation
+	Redundancy int `json:"redundancy,omitempty"` // spare PSUs expected on top of required, e.g. 1 for N+1
+}
+
+// IPMISensorNames are the SDR sensor names a PSU is read from, with {instance}
+// replaced by its slot number
+type IPMISensorNames struct {
+	Voltage string `json:"voltage"` // default "PSU{instance} Voltage"
+	Current string `json:"current"` // default "PSU{instance} Current"
+	Power   string `json:"power"`   // default "PSU{instance} Power"
+}
+
+// validate checks the PSU source
+func (g PSUGroupConfig) validate() error {
+	switch g.Source {
+	case "", PSUSourceSimulated, PSUSourceIPMI:
+	default:
+		return fmt.Errorf("psus source %q is not simulated or ipmi", g.Source)
+	}
+	if g.Required < 0 || g.Redundancy < 0 {
+		return fmt.Errorf("psus required %d and redundancy %d must not be negative", g.Required, g.Redundancy)
+	}
+	if g.Redundancy > 0 && g.Required == 0 {
+		return fmt.Errorf("psus redundancy %d needs a required count", g.Redundancy)
+	}
+	if expected := g.Count - len(g.Absent); g.Required+g.Redundancy > expected {
+		return fmt.Errorf("psus required %d plus redundancy %d exceed the %d expected PSUs", g.Required, g.Redundancy, expected)
+	}
+	return g.FruGroupConfig.validate("psus")
+}
+
+// sensors returns the sensor names of the PSU in slot
+func (n IPMISensorNames) sensors(slot int) IPMISensorNames {
+	name := func(configured, def string) string {
+		if configured == "" {
+			configured = def
+		}
+		return strings.ReplaceAll(configured, "{instance}", strconv.Itoa(slot))
+	}
+	return IPMISensorNames{
+		Voltage: name(n.Voltage, "PSU{instance} Voltage"),
+		Current: name(n.Current, "PSU{instance} Current"),
+		Power:   name(n.Power, "PSU{instance} Power"),
+	}
+}
+
+// ipmiPSUSource reads a PSU's sensors from the BMC with ipmitool
+func ipmiPSUSource(sensors IPMISensorNames) PSUSource {
+	return func(ctx context.Context) (PSUMetrics, error) {
+		readings, err := ipmiSDR(ctx)
+		if err != nil {
+			return PSUMetrics{}, err
+		}
+
+		var metrics PSUMetrics
+		for _, sensor := range []struct {
+			name  string
+			value *float64
+		}{
+			{sensors.Voltage, &metrics.Voltage},
+			{sensors.Current, &metrics.Current},
+			{sensors.Power, &metrics.Power},
+		} {
+			value, ok := readings[sensor.name]
+			if !ok {
+				return PSUMetrics{}, fmt.Errorf("no reading for IPMI sensor %q", sensor.name)
+			}
+			*sensor.value = value
+		}
+		return metrics, nil
+	}
+}
+
+// ipmiSDR runs "ipmitool sdr" and returns the numeric reading of every sensor by name.
+// Sensors without a reading, such as disabled or absent ones, are left out.
+func ipmiSDR(ctx context.Context) (map[string]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, ipmiTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "ipmitool", "sdr").Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running ipmitool sdr: %v", err)
+	}
+	return parseIPMISDR(string(output)), nil
+}
+
+// parseIPMISDR parses "name | 12.10 Volts | ok" lines, skipping any it can't read
+func parseIPMISDR(output string) map[string]float64 {
+	readings := make(map[string]float64)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "|")
+		if len(fields) < 2 {
+			continue
+		}
+		value := strings.Fields(fields[1])
+		if len(value) == 0 {
+			continue
+		}
+		reading, err := strconv.ParseFloat(value[0], 64)
+		if err != nil {
+			continue // "no reading", "disabled" and discrete sensors such as "0x01"
+		}
+		readings[strings.TrimSpace(fields[0])] = reading
+	}
+	return readings
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultKafkaBuffer    = 1000 // events waiting for delivery before new ones are dropped
+	defaultKafkaBatchSize = 100  // events per produce request
+	defaultKafkaTimeout   = 5 * time.Second
+
+	kafkaContentType = "application/vnd.kafka.json.v2+json"
+)
+
+// KafkaEventLimitAlert is the Kafka event type of a resource limit alert being raised or
+// cleared, which has no webhook counterpart
+const KafkaEventLimitAlert = "limit_alert"
+
+// kafkaEvents lists the event types that can be published to Kafka
+var kafkaEvents = map[string]bool{
+	WebhookEventProcessTransition: true,
+	WebhookEventFruRed:            true,
+	WebhookEventFruRecovered:      true,
+	KafkaEventLimitAlert:          true,
+}
+
+// KafkaConfig publishes events to a Kafka topic through a Kafka REST Proxy, which takes
+// produce requests over HTTP, so hostd needs no broker connection of its own
+type KafkaConfig struct {
+	RestProxy string   `json:"restProxy"`        // base URL of the REST Proxy, such as http://kafka-rest:8082; empty disables
+	Topic     string   `json:"topic"`            // topic the events are produced to
+	Events    []string `json:"events,omitempty"` // event types to publish, all if empty
+	Buffer    int      `json:"buffer"`           // events waiting for delivery before new ones are dropped, default 1000
+	BatchSize int      `json:"batchSize"`        // most events sent in one produce request, default 100
+	Timeout   Duration `json:"timeout"`          // limit on each produce request, default 5s
+}
+
+// validate checks the proxy URL, topic and event filter of an enabled sink
+func (c KafkaConfig) validate() error {
+	if c.RestProxy == "" {
+		return nil
+	}
+	u, err := url.Parse(c.RestProxy)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("restProxy %q is not an http or https URL", c.RestProxy)
+	}
+	if c.Topic == "" || strings.ContainsAny(c.Topic, "/?#") {
+		return fmt.Errorf("topic %q is not a valid topic name", c.Topic)
+	}
+	for _, event := range c.Events {
+		if !kafkaEvents[event] {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	if c.Buffer < 0 {
+		return fmt.Errorf("buffer %d is negative", c.Buffer)
+	}
+	if c.BatchSize < 0 {
+		return fmt.Errorf("batchSize %d is negative", c.BatchSize)
+	}
+	if c.Timeout.Duration < 0 {
+		return fmt.Errorf("timeout %v is negative", c.Timeout.Duration)
+	}
+	return nil
+}
+
+// kafkaRecord is one message of a REST Proxy produce request, keyed by host so every
+// event of a host lands in the same partition and stays in order. The value is the entry
+// added to the event stream, so Kafka and stream consumers share one schema.
+type kafkaRecord struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// kafkaProduceResponse is the part of a REST Proxy produce response that reports the
+// outcome of each record, in request order
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+// kafkaProducer delivers events to Kafka asynchronously from a bounded queue. Failed
+// deliveries are logged and counted, never retried, so an unreachable proxy only costs
+// events rather than memory.
+type kafkaProducer struct {
+	config  KafkaConfig
+	url     string
+	events  map[string]bool // nil for all
+	queue   chan kafkaRecord
+	client  *http.Client
+	metrics *MetricsExporter // nil when the exporter is disabled
+	logger  *Logger
+}
+
+// newKafkaProducer creates a producer for config; nil if no REST Proxy is configured
+func newKafkaProducer(config KafkaConfig, metrics *MetricsExporter, logger *Logger) *kafkaProducer {
+	if config.RestProxy == "" {
+		return nil
+	}
+	if config.Buffer == 0 {
+		config.Buffer = defaultKafkaBuffer
+	}
+	if config.BatchSize == 0 {
+		config.BatchSize = defaultKafkaBatchSize
+	}
+	if config.Timeout.Duration == 0 {
+		config.Timeout.Duration = defaultKafkaTimeout
+	}
+
+	p := &kafkaProducer{
+		config:  config,
+		url:     strings.TrimSuffix(config.RestProxy, "/") + "/topics/" + url.PathEscape(config.Topic),
+		queue:   make(chan kafkaRecord, config.Buffer),
+		client:  &http.Client{Timeout: config.Timeout.Duration},
+		metrics: metrics,
+		logger:  logger,
+	}
+	if len(config.Events) > 0 {
+		p.events = make(map[string]bool, len(config.Events))
+		for _, event := range config.Events {
+			p.events[event] = true
+		}
+	}
+	logger.Info("Publishing events to Kafka topic %s through %s", config.Topic, config.RestProxy)
+	return p
+}
+
+// publish queues an event stream entry of the given event type, keyed by host, if the
+// producer is subscribed to the type, dropping and counting it if the queue is full
+func (p *kafkaProducer) publish(host, event string, values map[string]interface{}) {
+	if p == nil || (p.events != nil && !p.events[event]) {
+		return
+	}
+	select {
+	case p.queue <- kafkaRecord{Key: host, Value: values}:
+	default:
+		p.metrics.ObserveKafkaDropped(1)
+		p.logger.Error("Kafka queue is full, dropping %s event", event)
+	}
+}
+
+// deliverLoop sends queued events in batches of up to BatchSize until ctx is cancelled;
+// events still queued then are dropped
+func (p *kafkaProducer) deliverLoop(ctx context.Context) {
+	for {
+		var batch []kafkaRecord
+		select {
+		case <-ctx.Done():
+			return
+		case record := <-p.queue:
+			batch = append(batch, record)
+		}
+	drain:
+		for len(batch) < p.config.BatchSize {
+			select {
+			case record := <-p.queue:
+				batch = append(batch, record)
+			default:
+				break drain
+			}
+		}
+
+		failed, err := p.produce(ctx, batch)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			p.metrics.ObserveKafkaFailures(len(batch))
+			p.logger.Error("Failed to publish %d events to Kafka topic %s: %v", len(batch), p.config.Topic, err)
+			continue
+		}
+		if failed > 0 {
+			p.metrics.ObserveKafkaFailures(failed)
+		}
+	}
+}
+
+// produce sends one batch of records to the topic, treating any non-2xx response as a
+// failure of the whole batch. A 2xx response can still report errors for single records,
+// which are logged and returned as the number of records that failed.
+func (p *kafkaProducer) produce(ctx context.Context, records []kafkaRecord) (int, error) {
+	body, err := json.Marshal(map[string][]kafkaRecord{"records": records})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("unexpected response %s", resp.Status)
+	}
+
+	var result kafkaProduceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		p.logger.Debug("Could not parse Kafka produce response, assuming every record was stored: %v", err)
+		return 0, nil
+	}
+	failed := 0
+	for _, offset := range result.Offsets {
+		if offset.ErrorCode != nil {
+			failed++
+			p.logger.Error("Kafka rejected an event for topic %s: %s (error code %d)", p.config.Topic, offset.Error, *offset.ErrorCode)
+		}
+	}
+	return failed, nil
+}
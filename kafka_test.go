@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKafkaProduceSendsStreamEntries(t *testing.T) {
+	var body struct {
+		Records []kafkaRecord `json:"records"`
+	}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/topics/hostd-events" {
+			t.Errorf("produced to %s, want the topic path", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding produce request: %v", err)
+		}
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":7,"error_code":null,"error":null}]}`))
+	}))
+	defer proxy.Close()
+
+	p := newKafkaProducer(KafkaConfig{RestProxy: proxy.URL, Topic: "hostd-events"}, nil, newTestLogger())
+	event := transitionEvent("api", "up", "down", 42, false)
+	p.publish("node1", WebhookEventProcessTransition, event)
+
+	failed, err := p.produce(context.Background(), []kafkaRecord{<-p.queue})
+	if err != nil || failed != 0 {
+		t.Fatalf("produce = %d failed, %v, want every record stored", failed, err)
+	}
+	if len(body.Records) != 1 {
+		t.Fatalf("produced %d records, want 1", len(body.Records))
+	}
+	record := body.Records[0]
+	if record.Key != "node1" {
+		t.Errorf("key %q, want the host", record.Key)
+	}
+	for field, want := range event {
+		if record.Value[field] != want {
+			t.Errorf("value %s = %v, want the stream entry's %v", field, record.Value[field], want)
+		}
+	}
+	if len(record.Value) != len(event) {
+		t.Errorf("value %v has fields beyond the stream entry %v", record.Value, event)
+	}
+}
+
+func TestKafkaProduceCountsRejectedRecords(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"offsets":[
+			{"partition":0,"offset":8,"error_code":null,"error":null},
+			{"partition":null,"offset":null,"error_code":40403,"error":"Topic not authorized"},
+			{"partition":null,"offset":null,"error_code":50003,"error":"Leader not available"}]}`))
+	}))
+	defer proxy.Close()
+
+	p := newKafkaProducer(KafkaConfig{RestProxy: proxy.URL, Topic: "hostd-events"}, nil, newTestLogger())
+	records := make([]kafkaRecord, 3)
+	for i := range records {
+		records[i] = kafkaRecord{Key: "node1", Value: limitAlertEvent("api", "memory", true, "600MB", "512MB")}
+	}
+
+	failed, err := p.produce(context.Background(), records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 2 {
+		t.Errorf("%d records counted as failed, want the 2 with an error code", failed)
+	}
+}
+
+func TestKafkaPublishFiltersEvents(t *testing.T) {
+	p := newKafkaProducer(KafkaConfig{RestProxy: "http://kafka-rest:8082", Topic: "hostd-events", Events: []string{KafkaEventLimitAlert}}, nil, newTestLogger())
+	p.publish("node1", WebhookEventProcessTransition, transitionEvent("api", "up", "down", 42, false))
+	p.publish("node1", KafkaEventLimitAlert, limitAlertEvent("api", "fds", false, "10", "1024"))
+
+	if len(p.queue) != 1 {
+		t.Fatalf("%d events queued, want only the limit alert", len(p.queue))
+	}
+	if record := <-p.queue; record.Value["limit_alert"] != "fds" {
+		t.Errorf("queued %v, want the limit alert", record.Value)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// labelNamePattern is what Prometheus accepts as a label name
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// validLabelName reports whether a process label can be exported as a Prometheus label.
+// Names starting with __ are reserved, and process is the label hostd sets itself.
+func validLabelName(name string) bool {
+	return labelNamePattern.MatchString(name) && !strings.HasPrefix(name, "__") && name != "process"
+}
+
+// parseLabelSelectors parses label query parameters of the form key=value
+func parseLabelSelectors(selectors []string) (map[string]string, error) {
+	want := make(map[string]string, len(selectors))
+	for _, selector := range selectors {
+		key, value, ok := strings.Cut(selector, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("label %q is not of the form key=value", selector)
+		}
+		want[key] = value
+	}
+	return want, nil
+}
+
+// matchLabels reports whether labels has every key of want with the same value
+func matchLabels(labels, want map[string]string) bool {
+	for key, value := range want {
+		if got, ok := labels[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// defaultLeakSamples is how many memory samples the leak detector fits a trend to
+const defaultLeakSamples = 30
+
+// MemorySample is one memory reading kept for leak detection
+type MemorySample struct {
+	Time  time.Time `json:"t"`
+	Bytes int64     `json:"b"`
+}
+
+// memorySlope fits a least-squares line to samples and returns its slope in bytes per hour
+func memorySlope(samples []MemorySample) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+
+	// Hours since the first sample keep the sums small
+	n := float64(len(samples))
+	var sumX, sumY, sumXY, sumXX float64
+	for _, sample := range samples {
+		x := sample.Time.Sub(samples[0].Time).Hours()
+		y := float64(sample.Bytes)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denom
+}
+
+// checkMemoryTrend adds the current memory reading of a running process to its samples and
+// flags a suspected leak while the trend over a full window grows faster than the
+// configured rate. Samples live in the runtime state, so the window survives daemon
+// restarts; a new PID starts a new window. The caller holds the process lock.
+func (pm *ProcessMonitor) checkMemoryTrend(ctx context.Context, proc Process, status *ProcessStatus, pidChanged bool) {
+	threshold := pm.config.LeakSlopeBytesPerHour
+	if threshold <= 0 {
+		return
+	}
+
+	state := pm.runtimeState(ctx, proc.Name)
+	if pidChanged {
+		state.MemorySamples = nil
+		state.SuspectedLeak = false
+	}
+	if status.CurrentMemory <= 0 {
+		// No reading this time, keep the verdict of the last one
+		status.SuspectedLeak = state.SuspectedLeak
+		return
+	}
+	state.MemorySamples = append(state.MemorySamples, MemorySample{Time: time.Now(), Bytes: status.CurrentMemory})
+	if excess := len(state.MemorySamples) - pm.config.LeakSamples; excess > 0 {
+		state.MemorySamples = append([]MemorySample(nil), state.MemorySamples[excess:]...)
+	}
+
+	leak := false
+	slope := memorySlope(state.MemorySamples)
+	if len(state.MemorySamples) == pm.config.LeakSamples {
+		leak = slope > threshold
+	}
+
+	if leak && !state.SuspectedLeak {
+		pm.alert(proc, pm.logger.Error, "Process %s memory grew %.2f MB/hour over the last %d samples, suspected leak (threshold %.2f MB/hour)",
+			proc.Name, slope/(1024*1024), len(state.MemorySamples), threshold/(1024*1024))
+	} else if !leak && state.SuspectedLeak {
+		pm.logger.Info("Process %s memory growth %.2f MB/hour is back below the leak threshold", proc.Name, slope/(1024*1024))
+	}
+	state.SuspectedLeak = leak
+	status.SuspectedLeak = leak
+}
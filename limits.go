@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// limitClearRatio is the fraction of a resource limit usage must fall below before an
+// alert clears, so usage hovering around the limit doesn't flap the alert
+const limitClearRatio = 0.9
+
+// overLimit reports whether usage is over a limit, applying hysteresis when already alerting
+func overLimit(usage, limit float64, alerting bool) bool {
+	if alerting {
+		return usage >= limit*limitClearRatio
+	}
+	return usage > limit
+}
+
+// checkLimits compares the memory, CPU and file descriptor usage of a running process with its configured
+// limits, setting the alert flags of status and logging and publishing alert changes.
+// CPU is only compared when it was sampled; otherwise the previous alert is kept.
+func (pm *ProcessMonitor) checkLimits(ctx context.Context, proc Process, previous, status *ProcessStatus, cpuSampled bool) {
+	if proc.MemoryLimitBytes > 0 {
+		status.MemoryAlert = overLimit(float64(status.CurrentMemory), float64(proc.MemoryLimitBytes), previous.MemoryAlert)
+		if status.MemoryAlert && !previous.MemoryAlert {
+			pm.alert(proc, pm.logger.Error, "Process %s memory %.2f MB exceeds limit of %.2f MB",
+				proc.Name, float64(status.CurrentMemory)/(1024*1024), float64(proc.MemoryLimitBytes)/(1024*1024))
+		} else if !status.MemoryAlert && previous.MemoryAlert {
+			pm.logger.Info("Process %s memory %.2f MB back below limit of %.2f MB",
+				proc.Name, float64(status.CurrentMemory)/(1024*1024), float64(proc.MemoryLimitBytes)/(1024*1024))
+		}
+		if status.MemoryAlert != previous.MemoryAlert {
+			pm.publishLimitAlert(ctx, proc, "memory", status.MemoryAlert,
+				fmt.Sprint(status.CurrentMemory), fmt.Sprint(proc.MemoryLimitBytes))
+		}
+	}
+
+	if proc.CPULimitPercent > 0 {
+		status.CPUAlert = previous.CPUAlert
+		if cpuSampled {
+			status.CPUAlert = overLimit(status.CurrentCPU, proc.CPULimitPercent, previous.CPUAlert)
+		}
+		if status.CPUAlert && !previous.CPUAlert {
+			pm.alert(proc, pm.logger.Error, "Process %s CPU %.1f%% exceeds limit of %.1f%%",
+				proc.Name, status.CurrentCPU, proc.CPULimitPercent)
+		} else if !status.CPUAlert && previous.CPUAlert {
+			pm.logger.Info("Process %s CPU %.1f%% back below limit of %.1f%%",
+				proc.Name, status.CurrentCPU, proc.CPULimitPercent)
+		}
+		if status.CPUAlert != previous.CPUAlert {
+			pm.publishLimitAlert(ctx, proc, "cpu", status.CPUAlert,
+				fmt.Sprintf("%.1f", status.CurrentCPU), fmt.Sprint(proc.CPULimitPercent))
+		}
+	}
+
+	if proc.MaxOpenFDs > 0 {
+		status.FDAlert = overLimit(float64(status.OpenFDs), float64(proc.MaxOpenFDs), previous.FDAlert)
+		if status.FDAlert && !previous.FDAlert {
+			pm.alert(proc, pm.logger.Error, "Process %s has %d open file descriptors, over the limit of %d",
+				proc.Name, status.OpenFDs, proc.MaxOpenFDs)
+		} else if !status.FDAlert && previous.FDAlert {
+			pm.logger.Info("Process %s open file descriptors %d back below limit of %d",
+				proc.Name, status.OpenFDs, proc.MaxOpenFDs)
+		}
+		if status.FDAlert != previous.FDAlert {
+			pm.publishLimitAlert(ctx, proc, "fds", status.FDAlert, fmt.Sprint(status.OpenFDs), fmt.Sprint(proc.MaxOpenFDs))
+		}
+	}
+
+	status.Alerting = status.MemoryAlert || status.CPUAlert || status.FDAlert
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestOverLimitHysteresis(t *testing.T) {
+	tests := []struct {
+		usage    float64
+		alerting bool
+		want     bool
+	}{
+		{100, false, false}, // at the limit doesn't raise
+		{100.1, false, true},
+		{100, true, true},
+		{90, true, true}, // at the clear ratio the alert holds
+		{89.9, true, false},
+		{95, false, false},
+	}
+	for _, tt := range tests {
+		if got := overLimit(tt.usage, 100, tt.alerting); got != tt.want {
+			t.Errorf("overLimit(%.1f, 100, alerting %v) = %v, want %v", tt.usage, tt.alerting, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// logDedup suppresses repeats of a message within a window and reports how many were
+// dropped once the window has passed. It is shared by a logger and those derived from it.
+type logDedup struct {
+	mu     sync.Mutex
+	window time.Duration // 0 disables coalescing
+	seen   map[string]*dedupEntry
+	stop   chan struct{} // stops the flush loop, nil while coalescing is off
+}
+
+// dedupEntry tracks a message logged within the current window
+type dedupEntry struct {
+	logger     *Logger // the logger that wrote it, for its fields
+	level      int
+	msg        string
+	since      time.Time
+	suppressed int
+}
+
+func newLogDedup() *logDedup {
+	return &logDedup{seen: make(map[string]*dedupEntry)}
+}
+
+// setWindow changes the coalescing window, 0 disabling it. While it is on, a loop
+// writes the summaries of suppressed repeats once their window has passed, so they
+// appear even if nothing else is logged.
+func (d *logDedup) setWindow(window time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.window = window
+	if d.stop != nil {
+		close(d.stop)
+		d.stop = nil
+	}
+	if window > 0 {
+		d.stop = make(chan struct{})
+		go d.flushLoop(window, d.stop)
+	}
+}
+
+// flushLoop writes the summaries of expired messages every window until stop is closed
+func (d *logDedup) flushLoop(window time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			d.mu.Lock()
+			expired := d.expire(now, false)
+			d.mu.Unlock()
+			writeRepeats(expired)
+		}
+	}
+}
+
+// close stops the flush loop and returns every message with suppressed repeats, whether
+// or not its window has passed, so the summaries can be written before exiting
+func (d *logDedup) close() []dedupEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stop != nil {
+		close(d.stop)
+		d.stop = nil
+	}
+	d.window = 0
+	return d.expire(time.Now(), true)
+}
+
+// admit reports whether a message should be written now. It also returns the messages
+// whose window has passed with repeats suppressed, so their summaries can be written.
+func (d *logDedup) admit(l *Logger, level int, msg string, now time.Time) (bool, []dedupEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.window <= 0 {
+		return true, nil
+	}
+	expired := d.expire(now, false)
+
+	// fmt prints maps sorted by key, so equal fields give equal keys
+	key := fmt.Sprintf("%d\x00%s\x00%v", level, msg, l.fields)
+	if entry, ok := d.seen[key]; ok {
+		entry.suppressed++
+		return false, expired
+	}
+	d.seen[key] = &dedupEntry{logger: l, level: level, msg: msg, since: now}
+	return true, expired
+}
+
+// expire forgets the messages whose window has passed, or all of them, returning those
+// with suppressed repeats. d.mu must be held.
+func (d *logDedup) expire(now time.Time, all bool) []dedupEntry {
+	var expired []dedupEntry
+	for key, entry := range d.seen {
+		if !all && now.Sub(entry.since) < d.window {
+			continue
+		}
+		if entry.suppressed > 0 {
+			expired = append(expired, *entry)
+		}
+		delete(d.seen, key)
+	}
+	return expired
+}
+
+// writeRepeats writes a summary line for each message whose repeats were suppressed
+func writeRepeats(entries []dedupEntry) {
+	for _, entry := range entries {
+		entry.logger.emit(entry.level, fmt.Sprintf("%s [repeated %d times in the last %v]",
+			entry.msg, entry.suppressed, time.Since(entry.since).Round(time.Second)))
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+const (
+	defaultLogFileMaxSizeMB  = 10
+	defaultLogFileMaxBackups = 3
+)
+
+// rotatingFile is a log file that is rotated once it grows past a size limit,
+// keeping a fixed number of numbered backups. It is safe for concurrent use.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// newRotatingFile opens or creates the log file at path, appending to existing content
+func newRotatingFile(config LogFileConfig) (*rotatingFile, error) {
+	if config.MaxSizeMB <= 0 {
+		config.MaxSizeMB = defaultLogFileMaxSizeMB
+	}
+	if config.MaxBackups <= 0 {
+		config.MaxBackups = defaultLogFileMaxBackups
+	}
+
+	f := &rotatingFile{
+		path:       config.Path,
+		maxSize:    int64(config.MaxSizeMB) * 1024 * 1024,
+		maxBackups: config.MaxBackups,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the current log file and records its size
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening log file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error reading log file size: %v", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// Write appends p to the log file, rotating first if it would grow past the size limit
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			// The logger can't log its own failures; keep appending to whatever file is open
+			fmt.Fprintf(os.Stderr, "hostd: %v\n", err)
+		}
+	}
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts path.N to path.N+1, dropping the oldest backup, moves the current
+// file to path.1 and opens a new one. The file is reopened even if the rename fails;
+// f.file is nil only if that also fails.
+func (f *rotatingFile) rotate() error {
+	f.file.Close()
+	f.file = nil
+
+	os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	renameErr := os.Rename(f.path, f.path+".1")
+
+	if err := f.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return fmt.Errorf("error rotating log file: %v", renameErr)
+	}
+	return nil
+}
+
+// Close closes the log file
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
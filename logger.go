@@ -1,50 +1,292 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"log/syslog"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
+// defaultMaxLineLength is the longest message logged before truncation
+const defaultMaxLineLength = 4096
+
+// Log output formats
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Log levels, in increasing order of severity
+const (
+	LevelDebug = iota
+	LevelInfo
+	LevelError
+	LevelCritical
+)
+
+// levelNames maps config level names to levels
+var levelNames = map[string]int{
+	"debug":    LevelDebug,
+	"info":     LevelInfo,
+	"error":    LevelError,
+	"critical": LevelCritical,
+}
+
+// levelLabels are the names written with each line, indexed by level
+var levelLabels = [...]string{"debug", "info", "error", "critical"}
+
+// syslogNew connects to the local syslog daemon, replaced by tests to simulate it being unavailable
+var syslogNew = syslog.New
+
 // Logger wraps syslog functionality
 type Logger struct {
-	syslog *syslog.Writer
+	syslog        *syslog.Writer         // nil if syslog is unavailable
+	file          *rotatingFile          // optional log file, nil if not configured
+	level         int                    // messages below this level are dropped
+	maxLineLength int                    // 0 disables truncation
+	json          bool                   // write each line as a JSON object
+	fields        map[string]interface{} // attached to every line, set with WithFields
+	dedup         *logDedup              // coalesces repeated messages, shared with derived loggers
 }
 
-// NewLogger creates a new logger with syslog integration
-func NewLogger() (*Logger, error) {
-	syslogWriter, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "hostd")
+// NewLogger creates a new logger with syslog integration. If syslog is unavailable
+// the logger still writes to stderr and, once configured, the log file.
+func NewLogger() *Logger {
+	l := &Logger{
+		level:         LevelInfo,
+		maxLineLength: defaultMaxLineLength,
+		dedup:         newLogDedup(),
+	}
+
+	syslogWriter, err := syslogNew(syslog.LOG_INFO|syslog.LOG_DAEMON, "hostd")
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to syslog: %v", err)
+		l.Error("Failed to connect to syslog, logging to stderr only: %v", err)
+		return l
+	}
+	l.syslog = syslogWriter
+	return l
+}
+
+// newConsoleLogger returns a logger for short-lived commands such as hostd ctl and
+// hostd -check, which write errors to stderr only
+func newConsoleLogger() *Logger {
+	return &Logger{level: LevelError, maxLineLength: defaultMaxLineLength, dedup: newLogDedup()}
+}
+
+// SetFile additionally writes every line to a size-rotated log file
+func (l *Logger) SetFile(config LogFileConfig) error {
+	file, err := newRotatingFile(config)
+	if err != nil {
+		return err
+	}
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file = file
+	return nil
+}
+
+// SetMaxLineLength sets the longest message logged before truncation.
+// Zero keeps the default and a negative value disables truncation.
+func (l *Logger) SetMaxLineLength(length int) {
+	switch {
+	case length == 0:
+		l.maxLineLength = defaultMaxLineLength
+	case length < 0:
+		l.maxLineLength = 0
+	default:
+		l.maxLineLength = length
+	}
+}
+
+// SetDedupWindow sets how long repeats of a message are suppressed after it is logged.
+// Zero, the default, logs every repeat.
+func (l *Logger) SetDedupWindow(window time.Duration) {
+	l.dedup.setWindow(window)
+}
+
+// SetLevel sets the lowest level logged by name; an empty name keeps info
+func (l *Logger) SetLevel(name string) error {
+	if name == "" {
+		l.level = LevelInfo
+		return nil
+	}
+
+	level, ok := levelNames[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("unknown log level %q", name)
+	}
+	l.level = level
+	return nil
+}
+
+// SetFormat selects text or JSON output; an empty format keeps text
+func (l *Logger) SetFormat(format string) error {
+	switch format {
+	case "", LogFormatText:
+		l.json = false
+	case LogFormatJSON:
+		l.json = true
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	return nil
+}
+
+// WithFields returns a logger that adds the given fields to every line, on top of
+// any fields already attached. The returned logger shares the syslog connection.
+func (l *Logger) WithFields(fields map[string]interface{}) *Logger {
+	merged := make(map[string]interface{}, len(l.fields)+len(fields))
+	for key, value := range l.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+
+	derived := *l
+	derived.fields = merged
+	return &derived
+}
+
+// format builds a log message, truncating it to the configured maximum length
+func (l *Logger) format(format string, v ...interface{}) string {
+	msg := fmt.Sprintf(format, v...)
+	if l.maxLineLength <= 0 || len(msg) <= l.maxLineLength {
+		return msg
+	}
+
+	cut := l.maxLineLength
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s... [truncated %d bytes]", msg[:cut], len(msg)-cut)
+}
+
+// render builds the line for a message at the given level, as a JSON object in JSON
+// mode and with fields appended as key=value pairs in text mode
+func (l *Logger) render(level, msg string) string {
+	if l.json {
+		entry := make(map[string]interface{}, len(l.fields)+3)
+		for key, value := range l.fields {
+			entry[key] = value
+		}
+		entry["level"] = level
+		entry["msg"] = msg
+		entry["time"] = time.Now().Format(time.RFC3339Nano)
+
+		line, err := json.Marshal(entry)
+		if err != nil {
+			// Fall back to the string form of fields that can't be encoded
+			for key, value := range l.fields {
+				entry[key] = fmt.Sprint(value)
+			}
+			line, _ = json.Marshal(entry)
+		}
+		return string(line)
+	}
+
+	if len(l.fields) == 0 {
+		return msg
+	}
+	keys := make([]string, 0, len(l.fields))
+	for key := range l.fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(msg)
+	for _, key := range keys {
+		fmt.Fprintf(&b, " %s=%v", key, l.fields[key])
+	}
+	return b.String()
+}
+
+// write sends a line to syslog, stderr and the log file, unless the level is below
+// the configured threshold or the message repeats one logged within the dedup window
+func (l *Logger) write(level int, format string, v ...interface{}) {
+	if level < l.level {
+		return
 	}
 
-	return &Logger{
-		syslog: syslogWriter,
-	}, nil
+	msg := l.format(format, v...)
+	admitted, repeated := l.dedup.admit(l, level, msg, time.Now())
+	writeRepeats(repeated)
+	if admitted {
+		l.emit(level, msg)
+	}
+}
+
+// emit writes a message to syslog, stderr and the log file
+func (l *Logger) emit(level int, msg string) {
+	line := l.render(levelLabels[level], msg)
+	l.writeSyslog(level, line)
+
+	// JSON lines carry their own timestamp, so skip the text prefix
+	if !l.json {
+		line = fmt.Sprintf("%s [%s] %s", time.Now().Format("2006/01/02 15:04:05"),
+			strings.ToUpper(levelLabels[level]), line)
+	}
+	var out io.Writer = log.Writer()
+	if l.file != nil {
+		out = io.MultiWriter(out, l.file)
+	}
+	fmt.Fprintln(out, line)
+}
+
+// writeSyslog sends a line to syslog at the matching priority
+func (l *Logger) writeSyslog(level int, line string) {
+	if l.syslog == nil {
+		return
+	}
+
+	switch level {
+	case LevelCritical:
+		l.syslog.Crit(line)
+	case LevelError:
+		l.syslog.Err(line)
+	case LevelInfo:
+		l.syslog.Info(line)
+	default:
+		l.syslog.Debug(line)
+	}
 }
 
-// Close closes the syslog connection
+// Close writes the summaries of any suppressed repeats, then closes the syslog
+// connection and the log file
 func (l *Logger) Close() error {
+	writeRepeats(l.dedup.close())
+	if l.file != nil {
+		l.file.Close()
+	}
+	if l.syslog == nil {
+		return nil
+	}
 	return l.syslog.Close()
 }
 
 // Critical logs a critical error message
 func (l *Logger) Critical(format string, v ...interface{}) {
-	msg := fmt.Sprintf(format, v...)
-	l.syslog.Crit(msg)
-	log.Printf("[CRITICAL] %s", msg)
+	l.write(LevelCritical, format, v...)
 }
 
 // Error logs an error message
 func (l *Logger) Error(format string, v ...interface{}) {
-	msg := fmt.Sprintf(format, v...)
-	l.syslog.Err(msg)
-	log.Printf("[ERROR] %s", msg)
+	l.write(LevelError, format, v...)
 }
 
 // Info logs an informational message
 func (l *Logger) Info(format string, v ...interface{}) {
-	msg := fmt.Sprintf(format, v...)
-	l.syslog.Info(msg)
-	log.Printf("[INFO] %s", msg)
+	l.write(LevelInfo, format, v...)
+}
+
+// Debug logs a debug message
+func (l *Logger) Debug(format string, v ...interface{}) {
+	l.write(LevelDebug, format, v...)
 }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"log/syslog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// logBuffer collects the lines loggers write to stderr, safe for the dedup flush loop
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureLog redirects stderr logging to a buffer for the rest of the test
+func captureLog(t *testing.T) *logBuffer {
+	t.Helper()
+	buf := &logBuffer{}
+	previous := log.Writer()
+	log.SetOutput(buf)
+	t.Cleanup(func() { log.SetOutput(previous) })
+	return buf
+}
+
+func TestLoggerDedupOffByDefault(t *testing.T) {
+	out := captureLog(t)
+	logger := newTestLogger()
+	for i := 0; i < 3; i++ {
+		logger.Error("Redis is unreachable")
+	}
+	logger.Close()
+
+	if n := strings.Count(out.String(), "Redis is unreachable"); n != 3 {
+		t.Errorf("logged %d times, want every repeat:\n%s", n, out)
+	}
+}
+
+func TestLoggerDedupFlushesOnTicker(t *testing.T) {
+	out := captureLog(t)
+	logger := newTestLogger()
+	logger.SetDedupWindow(50 * time.Millisecond)
+	defer logger.Close()
+
+	for i := 0; i < 4; i++ {
+		logger.Error("Redis is unreachable")
+	}
+	if n := strings.Count(out.String(), "Redis is unreachable"); n != 1 {
+		t.Fatalf("logged %d times within the window, want 1:\n%s", n, out)
+	}
+
+	// Nothing else is logged, so only the flush loop can write the summary
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), "[repeated 3 times") {
+		if time.Now().After(deadline) {
+			t.Fatalf("no repeat summary written after the window passed:\n%s", out)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLoggerDedupFlushesOnClose(t *testing.T) {
+	out := captureLog(t)
+	logger := newTestLogger()
+	logger.SetDedupWindow(time.Hour)
+
+	derived := logger.WithFields(map[string]interface{}{"process": "api"})
+	for i := 0; i < 3; i++ {
+		derived.Error("Process api is down")
+	}
+	logger.Close()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want the message and its summary:\n%s", len(lines), out)
+	}
+	if !strings.Contains(lines[1], "Process api is down [repeated 2 times") || !strings.Contains(lines[1], "process=api") {
+		t.Errorf("summary %q, want the message, repeat count and fields of the derived logger", lines[1])
+	}
+
+	// Closing stops coalescing, so a late message isn't held back
+	logger.Error("Late message")
+	logger.Error("Late message")
+	if n := strings.Count(out.String(), "Late message"); n != 2 {
+		t.Errorf("logged %d times after close, want 2", n)
+	}
+}
+
+func TestNewLoggerWithoutSyslog(t *testing.T) {
+	out := captureLog(t)
+	previous := syslogNew
+	syslogNew = func(syslog.Priority, string) (*syslog.Writer, error) {
+		return nil, errors.New("dial unix /dev/log: connect: no such file or directory")
+	}
+	t.Cleanup(func() { syslogNew = previous })
+
+	logger := NewLogger()
+	if logger == nil {
+		t.Fatal("NewLogger returned nil without syslog")
+	}
+	if !strings.Contains(out.String(), "Failed to connect to syslog, logging to stderr only") {
+		t.Errorf("no warning about the missing syslog:\n%s", out)
+	}
+
+	logger.Info("Monitoring started")
+	logger.WithFields(map[string]interface{}{"process": "api"}).Critical("Process api is down")
+	if err := logger.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	for _, want := range []string{"[INFO] Monitoring started", "[CRITICAL] Process api is down process=api"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("stderr is missing %q:\n%s", want, out)
+		}
+	}
+}
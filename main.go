@@ -3,25 +3,196 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"log"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
-
-	"github.com/go-redis/redis/v8"
 )
 
 type Config struct {
-	Redis RedisConfig `json:"redis"`
+	Redis      RedisConfig      `json:"redis"`
+	Monitoring MonitoringConfig `json:"monitoring"`
+	Logging    LoggingConfig    `json:"logging"`
+
+	CheckInterval    Duration `json:"checkInterval"`    // how often processes are checked, default 1m
+	HardwareInterval Duration `json:"hardwareInterval"` // how often FRUs are polled, default checkInterval
+
+	Jitter JitterConfig `json:"jitter"`
+
+	Hardware      HardwareConfig   `json:"hardware"`
+	FruEscalation []EscalationStep `json:"fruEscalation,omitempty"` // escalation ladder for FRUs that stay red
+
+	Disk   DiskConfig   `json:"disk"`
+	System SystemConfig `json:"system"`
+
+	Webhooks []WebhookConfig `json:"webhooks,omitempty"` // endpoints notified of process restarts, transitions and red FRUs
+	Kafka    KafkaConfig     `json:"kafka"`              // topic the event stream entries are published to
+
+	Watchdog WatchdogConfig `json:"watchdog"`
+	HTTP     HTTPConfig     `json:"http"`
+	Metrics  MetricsConfig  `json:"metrics"`
+	GRPC     GRPCConfig     `json:"grpc"`
+	Debug    DebugConfig    `json:"debug"`
+}
+
+// MetricsConfig holds settings for the Prometheus metrics exporter
+type MetricsConfig struct {
+	Enabled bool   `json:"enabled"`
+	Listen  string `json:"listen"` // address to serve /metrics on, default :9111
+}
+
+// GRPCConfig holds settings for the gRPC control interface
+type GRPCConfig struct {
+	Enabled bool   `json:"enabled"`
+	Listen  string `json:"listen"` // address to listen on, default 127.0.0.1:9090
+}
+
+// HTTPConfig holds settings for the HTTP status API
+type HTTPConfig struct {
+	Listen     string `json:"listen"`     // address to listen on, default :8080
+	HealthPath string `json:"healthPath"` // liveness endpoint, default /healthz
+	ReadyPath  string `json:"readyPath"`  // readiness endpoint, default /readyz
+}
+
+// WatchdogConfig holds settings for the watchdog that detects stuck periodic checks
+type WatchdogConfig struct {
+	Disabled   bool   `json:"disabled"`
+	Multiplier int    `json:"multiplier"` // check intervals without a completed cycle before firing, default 3
+	Action     string `json:"action"`     // log (default) or exit
+}
+
+// JitterConfig spreads the periodic work of many hosts over time so their Redis
+// traffic doesn't line up
+type JitterConfig struct {
+	Fraction float64 `json:"fraction"`       // random offset of up to this fraction of the interval, 0 disables
+	EveryRun bool    `json:"everyRun"`       // offset every run, not only the first
+	Seed     *int64  `json:"seed,omitempty"` // combined with the hostname for reproducible offsets, random if unset
+}
+
+// LoggingConfig holds logger settings
+type LoggingConfig struct {
+	MaxLineLength int    `json:"maxLineLength"` // 0 uses the default, negative disables truncation
+	Format        string `json:"format"`        // text (default) or json
+	Level         string `json:"level"`         // debug, info (default), error or critical
+
+	DedupWindow Duration `json:"dedupWindow"` // suppress repeats of a message for this long, 0 (the default) disables
+
+	File *LogFileConfig `json:"file,omitempty"` // also log to a rotating file
+}
+
+// LogFileConfig holds settings for the rotating log file
+type LogFileConfig struct {
+	Path       string `json:"path"`
+	MaxSizeMB  int    `json:"maxSizeMB"`  // rotate once the file reaches this size, default 10
+	MaxBackups int    `json:"maxBackups"` // rotated files kept, default 3
 }
 
 type RedisConfig struct {
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	Password string `json:"password"`
-	DB       int    `json:"db"`
+	Host        string              `json:"host"`
+	Port        int                 `json:"port"`
+	Password    string              `json:"password"`
+	DB          int                 `json:"db"`
+	Username    string              `json:"username"` // ACL username, empty for the default user
+	ReadReplica *RedisReplicaConfig `json:"readReplica,omitempty"`
+
+	Sentinel *RedisSentinelConfig `json:"sentinel,omitempty"` // find the primary through Sentinel instead of host and port
+
+	TLS           bool   `json:"tls"`
+	TLSSkipVerify bool   `json:"tlsSkipVerify"` // accept any server certificate, for testing only
+	CACertFile    string `json:"caCertFile"`    // PEM CA bundle to verify the server with, default system roots
+
+	CompressThreshold int `json:"compressThreshold"` // gzip values larger than this many bytes, 0 disables
+
+	OpTimeout Duration `json:"opTimeout"` // limit on each Redis operation attempt, default 5s
+
+	EventStream       string `json:"eventStream"`       // stream process state transitions are added to, default hostd:events
+	EventStreamMaxLen int64  `json:"eventStreamMaxLen"` // approximate number of events kept, default 10000
+
+	HardwareHistoryLength int `json:"hardwareHistoryLength"` // metrics samples kept per FRU in hardware:{type}:{instance}:history, 0 disables
+
+	DisableCommands bool `json:"disableCommands"` // don't subscribe to hostd:commands, e.g. when control goes through gRPC
+
+	KeyPrefix string `json:"keyPrefix"` // prepended with a colon to every key, stream and channel, e.g. the hostname
+
+	KeyTTL          Duration `json:"keyTTL"`          // expiry of status and metrics keys, refreshed on every write, 0 for none
+	CleanupInterval Duration `json:"cleanupInterval"` // how often keys of processes no longer configured are deleted, 0 disables
+}
+
+// RedisReplicaConfig is the address of a read replica used for status queries.
+// It shares the credentials, DB and TLS settings of the primary.
+type RedisReplicaConfig struct {
+	Host string `json:"host"`
+	Port int    `json:"port"`
+}
+
+// RedisSentinelConfig locates the primary through Redis Sentinel and follows it across
+// failovers. It replaces host and port; the credentials, DB and TLS settings still apply.
+type RedisSentinelConfig struct {
+	MasterName       string   `json:"masterName"`
+	SentinelAddrs    []string `json:"sentinelAddrs"`    // host:port of each sentinel
+	SentinelPassword string   `json:"sentinelPassword"` // password of the sentinels themselves, empty for none
+}
+
+// MonitoringConfig holds settings for process monitoring
+type MonitoringConfig struct {
+	UptimeWindow Duration `json:"uptimeWindow"` // rolling window for the uptime ratio, default 24h
+	UptimeTarget float64  `json:"uptimeTarget"` // alert when uptime percent drops below this, 0 disables
+
+	StrictSelfCheck bool `json:"strictSelfCheck"` // refuse to start if required external commands are broken
+
+	CheckConcurrency int `json:"checkConcurrency"` // processes checked at once, default 8
+
+	DockerSocket string `json:"dockerSocket,omitempty"` // Docker API socket for container processes, default /var/run/docker.sock
+
+	StartupDelay Duration `json:"startupDelay"` // record status but suppress alerts for this long after hostd starts
+
+	RestartStablePeriod Duration `json:"restartStablePeriod"` // uptime after which the restart count resets, default 5m
+
+	FlapThreshold int      `json:"flapThreshold"` // restarts within flapWindow that mark a process as flapping, default 5
+	FlapWindow    Duration `json:"flapWindow"`    // rolling window for flap detection, default 5m
+	FlapCooldown  Duration `json:"flapCooldown"`  // pause before restarting a flapping process again, default 15m
+
+	MaxRestartsPerMinute int `json:"maxRestartsPerMinute"` // automatic restarts allowed across all processes per minute, 0 disables
+
+	ClearRemovedKeys bool `json:"clearRemovedKeys"` // delete Redis keys of processes dropped on reload
+
+	ShutdownTimeout Duration `json:"shutdownTimeout"` // limit on stopping stopOnShutdown processes when hostd exits, default 30s
+
+	LeakSlopeBytesPerHour float64 `json:"leakSlopeBytesPerHour"` // flag a suspected leak when memory grows faster than this, 0 disables
+	LeakSamples           int     `json:"leakSamples"`           // samples the memory trend is fitted to, one per check, default 30
+
+	ProbeBackend string   `json:"probeBackend"` // how processes are inspected: auto (default), ps or proc
+	ProbeTimeout Duration `json:"probeTimeout"` // limit on each pgrep or ps run, after which the status is unknown for that check, default 10s
+
+	InjectStatusToken string `json:"injectStatusToken,omitempty"` // token inject-status commands must carry, empty disables them
+}
+
+// Duration is a time.Duration that is read from a string such as "30s" in config files
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %v", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %v", s, err)
+	}
+
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
 }
 
 type ProcessConfig struct {
@@ -29,16 +200,80 @@ type ProcessConfig struct {
 }
 
 type Process struct {
-	Name       string `json:"name"`
-	Restart    bool   `json:"restart"`
-	MaxRetries int    `json:"maxRetries"`
+	Name       string            `json:"name"`
+	Restart    bool              `json:"restart"`
+	MaxRetries int               `json:"maxRetries"`
+	Managed    bool              `json:"managed,omitempty"`    // start the process whenever it is down, not only after a crash
+	Command    string            `json:"command,omitempty"`    // executable used to start the process
+	Args       []string          `json:"args,omitempty"`       // arguments passed to Command
+	WorkingDir string            `json:"workingDir,omitempty"` // working directory, hostd's own if empty
+	Env        map[string]string `json:"env,omitempty"`        // added to hostd's environment when starting
+
+	ExpectedEnv map[string]string `json:"expectedEnv,omitempty"` // environment the running process should have
+	EnvKeys     []string          `json:"envKeys,omitempty"`     // subset of ExpectedEnv keys to compare, all if empty
+
+	StopTimeout    Duration `json:"stopTimeout,omitempty"`    // time to exit after SIGTERM before SIGKILL, default 10s
+	StopOnShutdown bool     `json:"stopOnShutdown,omitempty"` // stop the process when hostd shuts down
+
+	StartVerifyAttempts int      `json:"startVerifyAttempts,omitempty"` // PID checks after a start before giving up
+	StartVerifyInterval Duration `json:"startVerifyInterval,omitempty"` // delay between PID checks after a start
+
+	RestartGroup string `json:"restartGroup,omitempty"` // processes sharing a group are restarted together
+	RestartOn    string `json:"restartOn,omitempty"`    // always, on-failure or never for children hostd started
+
+	MatchMode string `json:"matchMode,omitempty"` // substring (default), exact or cmdline
+	Container string `json:"container,omitempty"` // Docker container name or ID to monitor instead of matching by name
+	Cgroup    string `json:"cgroup,omitempty"`    // cgroup whose processes are the instances, e.g. system.slice/nginx.service
+
+	MinThreads int `json:"minThreads,omitempty"` // warn when the thread count drops below this, 0 disables
+	MaxThreads int `json:"maxThreads,omitempty"` // warn when the thread count exceeds this, 0 disables
+
+	MemoryLimitBytes     int64   `json:"memoryLimitBytes,omitempty"`     // alert when memory exceeds this, 0 disables
+	CPULimitPercent      float64 `json:"cpuLimitPercent,omitempty"`      // alert when CPU exceeds this percent of one core, 0 disables
+	RestartOnMemoryLimit bool    `json:"restartOnMemoryLimit,omitempty"` // restart the process when its memory alert is raised
+	MaxOpenFDs           int     `json:"maxOpenFDs,omitempty"`           // alert when open file descriptors exceed this, 0 disables
+
+	StartupDelay *Duration `json:"startupDelay,omitempty"` // overrides the global startup delay for this process
+	StartupGrace Duration  `json:"startupGrace,omitempty"` // after hostd or a start command launches it, a missing PID is not reported down for this long
+
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"` // probe that the running process works, beyond its PID existing
+
+	DependsOn []string `json:"dependsOn,omitempty"` // processes that must be running before this one is started
+
+	Labels map[string]string `json:"labels,omitempty"` // e.g. team or tier, for filtering the status API and labelling metrics
+
+	OutputLines int    `json:"outputLines,omitempty"` // stdout/stderr lines kept for processes hostd starts, default 200, negative disables
+	OutputFile  string `json:"outputFile,omitempty"`  // also append that output to this file
 }
 
 type Command struct {
-	Action  string `json:"action"`  // start, stop, restart
-	Process string `json:"process"` // process name
+	Action  string `json:"action"`            // start, stop, restart, status
+	Process string `json:"process"`           // process name
+	ReplyTo string `json:"replyTo,omitempty"` // channel to publish the CommandReply on, no reply if empty
+	ID      string `json:"id,omitempty"`      // echoed in the reply and result record, a UUID is assigned if empty
+
+	// inject-status only
+	Status   string   `json:"status,omitempty"`   // status to force: up, down or unhealthy
+	Duration Duration `json:"duration,omitempty"` // how long before it reverts, default 1m
+	Token    string   `json:"token,omitempty"`    // must match monitoring.injectStatusToken
+}
+
+// CommandReply is published on a command's ReplyTo channel once it has been handled
+type CommandReply struct {
+	ID      string      `json:"id,omitempty"`
+	Action  string      `json:"action"`
+	Process string      `json:"process"`
+	Status  string      `json:"status"` // ok or error
+	Error   string      `json:"error,omitempty"`
+	Result  interface{} `json:"result,omitempty"`
 }
 
+// Command reply statuses
+const (
+	ReplyStatusOK    = "ok"
+	ReplyStatusError = "error"
+)
+
 func loadConfig(filename string) (*Config, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -46,10 +281,14 @@ func loadConfig(filename string) (*Config, error) {
 	}
 
 	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
+	if err := unmarshalConfigFile(filename, data, &config); err != nil {
 		return nil, fmt.Errorf("error parsing config file: %v", err)
 	}
 
+	if err := config.applyEnvOverrides(); err != nil {
+		return nil, fmt.Errorf("invalid environment override: %v", err)
+	}
+
 	return &config, nil
 }
 
@@ -60,127 +299,292 @@ func loadProcessConfig(filename string) (*ProcessConfig, error) {
 	}
 
 	var config ProcessConfig
-	if err := json.Unmarshal(data, &config); err != nil {
+	if err := unmarshalConfigFile(filename, data, &config); err != nil {
 		return nil, fmt.Errorf("error parsing process config file: %v", err)
 	}
 
 	return &config, nil
 }
 
-type RedisClient struct {
-	client *redis.Client
-}
-
-func NewRedisClient(config *RedisConfig) (*RedisClient, error) {
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%d", config.Host, config.Port),
-		Password: config.Password,
-		DB:       config.DB,
-	})
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("error connecting to Redis: %v", err)
+// reloadProcessConfig re-reads the process config file and swaps the new process list
+// into the monitor. The current list is kept if the file cannot be loaded.
+func reloadProcessConfig(ctx context.Context, filename string, monitor *ProcessMonitor, clearKeys bool, logger *Logger) {
+	processConfig, err := loadProcessConfig(filename)
+	if err != nil {
+		logger.Error("Failed to reload process config, keeping the current one: %v", err)
+		return
+	}
+	if err := processConfig.Validate(); err != nil {
+		logger.Error("Invalid process config, keeping the current one:\n%v", err)
+		return
 	}
 
-	return &RedisClient{client: client}, nil
+	added, removed := monitor.ReloadProcesses(ctx, processConfig.Processes, clearKeys)
+	logger.Info("Reloaded process config: %d processes, added %v, removed %v",
+		len(processConfig.Processes), added, removed)
 }
 
-func (r *RedisClient) Close() {
-	r.client.Close()
-}
+// reloadHardwareConfig re-reads the hardware section of the config file and
+// re-enumerates the FRUs. The current FRUs are kept if the section cannot be loaded.
+func reloadHardwareConfig(filename string, hardware *HardwareManager, logger *Logger) (*HardwareReloadResult, error) {
+	config, err := loadConfig(filename)
+	if err == nil {
+		err = config.Hardware.validate()
+	}
+	if err != nil {
+		logger.Error("Failed to reload hardware config, keeping the current FRUs: %v", err)
+		return nil, err
+	}
 
-func (r *RedisClient) UpdateProcessStatus(ctx context.Context, processName string, status string) error {
-	return r.client.Set(ctx, fmt.Sprintf("process:%s:status", processName), status, 0).Err()
+	result := hardware.Reload(config.Hardware)
+	return &result, nil
 }
 
-func (r *RedisClient) SubscribeToCommands(ctx context.Context, handler func(ctx context.Context, cmd Command) error) {
-	pubsub := r.client.Subscribe(ctx, "hostd:commands")
-	defer pubsub.Close()
-
-	// Wait for confirmation that subscription is created before publishing anything
-	_, err := pubsub.Receive(ctx)
+// runCheck loads and validates both config files and pings Redis without starting
+// any monitoring. It prints the first failure and returns the exit code.
+func runCheck(configFile, processFile string) int {
+	config, err := loadConfig(configFile)
+	if err == nil {
+		err = config.Validate()
+	}
 	if err != nil {
-		log.Printf("Error receiving subscription confirmation: %v", err)
-		return
+		fmt.Fprintf(os.Stderr, "%s: %v\n", configFile, err)
+		return 1
 	}
 
-	ch := pubsub.Channel()
-
-	for {
-		select {
-		case msg := <-ch:
-			var cmd Command
-			if err := json.Unmarshal([]byte(msg.Payload), &cmd); err != nil {
-				log.Printf("Error parsing command: %v", err)
-				continue
-			}
+	processConfig, err := loadProcessConfig(processFile)
+	if err == nil {
+		err = processConfig.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", processFile, err)
+		return 1
+	}
 
-			if err := handler(ctx, cmd); err != nil {
-				log.Printf("Error handling command: %v", err)
-			}
-		case <-ctx.Done():
-			return
-		}
+	redisClient, err := NewRedisClient(&config.Redis, newConsoleLogger())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Redis at %s: %v\n", config.Redis, err)
+		return 1
 	}
+	redisClient.Close()
+
+	fmt.Printf("%s and %s are valid (%d processes), Redis at %s is reachable\n",
+		configFile, processFile, len(processConfig.Processes), config.Redis)
+	return 0
 }
 
 func main() {
-	// Initialize logger
-	logger, err := NewLogger()
-	if err != nil {
-		fmt.Printf("Failed to initialize logger: %v\n", err)
-		os.Exit(1)
+	// "hostd ctl ..." talks to a running daemon instead of starting one
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		os.Exit(runCtl(os.Args[2:]))
+	}
+
+	configFlag := flag.String("config", "", "daemon config file, YAML if it ends in .yaml or .yml (default $HOSTD_CONFIG or config.json)")
+	processFlag := flag.String("processes", "", "process config file, YAML if it ends in .yaml or .yml (default $HOSTD_PROCESSES or processes.json)")
+	checkOnly := flag.Bool("check", false, "validate the config files and Redis connection, then exit")
+	showVersion := flag.Bool("version", false, "print the version and exit")
+	flag.Parse()
+	if *showVersion {
+		fmt.Println(versionString())
+		os.Exit(0)
+	}
+	configFile := configPath(*configFlag, envConfigFile, "config.json")
+	processFile := configPath(*processFlag, envProcessFile, "processes.json")
+
+	if *checkOnly {
+		os.Exit(runCheck(configFile, processFile))
 	}
+
+	// Initialize logger
+	logger := NewLogger()
 	defer logger.Close()
 
 	// Load configurations
-	config, err := loadConfig("config.json")
+	config, err := loadConfig(configFile)
 	if err != nil {
 		logger.Critical("Failed to load config: %v", err)
 		os.Exit(1)
 	}
+	if err := config.Validate(); err != nil {
+		logger.Critical("Invalid config %s:\n%v", configFile, err)
+		os.Exit(1)
+	}
+
+	logger.SetMaxLineLength(config.Logging.MaxLineLength)
+	logger.SetDedupWindow(config.Logging.DedupWindow.Duration)
+	if err := logger.SetLevel(config.Logging.Level); err != nil {
+		logger.Critical("Invalid logging config: %v", err)
+		os.Exit(1)
+	}
+	if err := logger.SetFormat(config.Logging.Format); err != nil {
+		logger.Critical("Invalid logging config: %v", err)
+		os.Exit(1)
+	}
+	if config.Logging.File != nil {
+		if err := logger.SetFile(*config.Logging.File); err != nil {
+			logger.Critical("Failed to open log file: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	logger.Info("Starting %s", versionString())
+
+	// Pick how processes are probed, then verify the external commands it needs work
+	config.Monitoring.ProbeBackend = resolveProbeBackend(config.Monitoring.ProbeBackend, logger)
+	if err := runSelfCheck(config.Monitoring.ProbeBackend, logger); err != nil && config.Monitoring.StrictSelfCheck {
+		logger.Critical("Refusing to start in strict mode: %v", err)
+		os.Exit(1)
+	}
 
-	processConfig, err := loadProcessConfig("processes.json")
+	processConfig, err := loadProcessConfig(processFile)
 	if err != nil {
 		logger.Critical("Failed to load process config: %v", err)
 		os.Exit(1)
 	}
+	if err := processConfig.Validate(); err != nil {
+		logger.Critical("Invalid process config %s:\n%v", processFile, err)
+		os.Exit(1)
+	}
 
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Connect to Redis
-	redisClient, err := NewRedisClient(&config.Redis)
+	logger.Info("Using Redis at %s", config.Redis)
+	redisClient, err := NewRedisClient(&config.Redis, logger)
 	if err != nil {
 		logger.Critical("Failed to connect to Redis: %v", err)
 		os.Exit(1)
 	}
 	defer redisClient.Close()
 
+	// Serve Prometheus metrics if enabled
+	var metrics *MetricsExporter
+	var metricsDone <-chan struct{}
+	if config.Metrics.Enabled {
+		metrics = NewMetricsExporter()
+		metricsDone, err = StartMetricsServer(ctx, config.Metrics.Listen, metrics, logger)
+		if err != nil {
+			logger.Critical("Failed to start metrics server: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	// Serve pprof profiles for troubleshooting if enabled
+	var pprofDone <-chan struct{}
+	if config.Debug.PProf {
+		pprofDone, err = StartPprofServer(ctx, config.Debug.Listen, logger)
+		if err != nil {
+			logger.Critical("Failed to start pprof server: %v", err)
+			os.Exit(1)
+		}
+	}
+
 	// Create process monitor
-	processMonitor := NewProcessMonitor(processConfig.Processes, redisClient, logger)
+	processMonitor := NewProcessMonitor(processConfig.Processes, config.Monitoring, redisClient, metrics, logger)
+	processMonitor.LoadRuntimeState(ctx)
+
+	// Create hardware manager
+	hardwareManager := NewHardwareManager(config.Hardware, config.FruEscalation, redisClient, metrics, logger)
 
-	// Create and start periodic runner
-	periodicRunner := NewPeriodicRunner(processMonitor, logger)
+	// Notify webhooks and Kafka of process and FRU events
+	notifier := NewNotifier(config.Webhooks, config.Kafka, metrics, logger)
+	notifier.Start(ctx)
+	processMonitor.SetNotifier(notifier)
+	hardwareManager.SetNotifier(notifier)
+
+	// Create system load and memory monitor
+	systemMonitor := NewSystemMonitor(config.System, redisClient, metrics, logger)
+
+	// Create disk monitor, reporting into the chassis status
+	diskMonitor := NewDiskMonitor(config.Disk, redisClient, logger)
+	if len(config.Disk.Mounts) > 0 {
+		hardwareManager.AddStatusSource(diskMonitor.Statuses)
+	}
+
+	// Create and start periodic runner, polling hardware on its own cadence
+	hardwareInterval := config.HardwareInterval.Duration
+	if hardwareInterval == 0 {
+		hardwareInterval = config.CheckInterval.Duration
+	}
+	periodicRunner := NewPeriodicRunner(config.Watchdog, config.Jitter, logger)
+	periodicRunner.AddTask("processes", config.CheckInterval.Duration, processMonitor.UpdateAll)
+	periodicRunner.AddTask("hardware", hardwareInterval, hardwareManager.Poll)
+	if !config.System.Disabled {
+		periodicRunner.AddTask("system", config.CheckInterval.Duration, systemMonitor.Check)
+	}
+	if len(config.Disk.Mounts) > 0 {
+		diskInterval := config.Disk.Interval.Duration
+		if diskInterval == 0 {
+			diskInterval = config.CheckInterval.Duration
+		}
+		periodicRunner.AddTask("disk", diskInterval, diskMonitor.Check)
+	}
+	if config.Redis.CleanupInterval.Duration > 0 {
+		periodicRunner.AddTask("cleanup", config.Redis.CleanupInterval.Duration, func(ctx context.Context) {
+			cleanupStaleKeys(ctx, redisClient, processMonitor, logger)
+		})
+	}
 	periodicRunner.Start(ctx)
 
+	// Serve the HTTP status API
+	httpDone, err := StartHTTPServer(ctx, config.HTTP, redisClient, processMonitor, periodicRunner, logger)
+	if err != nil {
+		logger.Critical("Failed to start HTTP server: %v", err)
+		os.Exit(1)
+	}
+
+	// Act on start/stop/restart commands, over Redis and optionally gRPC
+	if !config.Redis.DisableCommands {
+		go redisClient.SubscribeToCommands(ctx, func(ctx context.Context, cmd Command) (interface{}, error) {
+			if cmd.Action == ActionReloadHardware {
+				return reloadHardwareConfig(configFile, hardwareManager, logger)
+			}
+			return processMonitor.HandleCommand(ctx, cmd)
+		})
+	}
+	var grpcDone <-chan struct{}
+	if config.GRPC.Enabled {
+		grpcDone, err = StartGRPCServer(ctx, config.GRPC.Listen, processMonitor, logger)
+		if err != nil {
+			logger.Critical("Failed to start gRPC control server: %v", err)
+			os.Exit(1)
+		}
+	}
+
 	logger.Info("Host daemon started")
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal, reloading the process and hardware config on SIGHUP and
+	// logging a status dump on SIGUSR1
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1)
+	for sig := <-sigChan; sig == syscall.SIGHUP || sig == syscall.SIGUSR1; sig = <-sigChan {
+		if sig == syscall.SIGUSR1 {
+			go dumpStatus(ctx, processMonitor, hardwareManager, logger)
+			continue
+		}
+		reloadProcessConfig(ctx, processFile, processMonitor, config.Monitoring.ClearRemovedKeys, logger)
+		reloadHardwareConfig(configFile, hardwareManager, logger)
+	}
 
-	// Cancel context to stop all goroutines
+	// Stop the processes that should not outlive hostd, then cancel context to stop all goroutines
 	logger.Info("Shutting down...")
+	processMonitor.StopOnShutdown(ctx)
 	cancel()
-	
-	// Wait for periodic tasks to complete
-	periodicRunner.Wait()
-	
+
+	// Wait for periodic tasks and the HTTP servers to complete
+	periodicRunner.Stop()
+	<-httpDone
+	if metricsDone != nil {
+		<-metricsDone
+	}
+	if pprofDone != nil {
+		<-pprofDone
+	}
+	if grpcDone != nil {
+		<-grpcDone
+	}
+
 	logger.Info("Shutdown complete")
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadProcessConfigWithoutCommand(t *testing.T) {
+	// processes.json from before processes had a command to start them with
+	path := filepath.Join(t.TempDir(), "processes.json")
+	old := `{"processes": [{"name": "nginx", "restart": false, "maxRetries": 3}]}`
+	if err := os.WriteFile(path, []byte(old), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadProcessConfig(path)
+	if err != nil {
+		t.Fatalf("loadProcessConfig: %v", err)
+	}
+	want := []Process{{Name: "nginx", MaxRetries: 3}}
+	if !reflect.DeepEqual(config.Processes, want) {
+		t.Errorf("processes = %+v, want %+v", config.Processes, want)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestLoadProcessConfigWithCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "processes.json")
+	data := `{"processes": [{"name": "api", "restart": true, "maxRetries": 3,
+		"command": "/usr/bin/api", "args": ["-port", "8080"], "workingDir": "/srv/api", "env": {"MODE": "prod"}}]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadProcessConfig(path)
+	if err != nil {
+		t.Fatalf("loadProcessConfig: %v", err)
+	}
+	want := []Process{{
+		Name:       "api",
+		Restart:    true,
+		MaxRetries: 3,
+		Command:    "/usr/bin/api",
+		Args:       []string{"-port", "8080"},
+		WorkingDir: "/srv/api",
+		Env:        map[string]string{"MODE": "prod"},
+	}}
+	if !reflect.DeepEqual(config.Processes, want) {
+		t.Errorf("processes = %+v, want %+v", config.Processes, want)
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestValidateRejectsRestartWithoutCommand(t *testing.T) {
+	config := ProcessConfig{Processes: []Process{{Name: "api", Restart: true}}}
+	if err := config.Validate(); err == nil {
+		t.Error("Validate accepted a restartable process without a command")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Match modes deciding which running processes belong to a configured process
+const (
+	MatchModeSubstring = "substring" // pgrep -f pattern anywhere in the command line
+	MatchModeExact     = "exact"     // executable basename equals the name
+	MatchModeCmdline   = "cmdline"   // full command line equals the configured command and args
+	MatchModeCgroup    = "cgroup"    // every process in the configured cgroup
+)
+
+// validMatchMode reports whether mode is a known match mode, empty meaning the default
+func validMatchMode(mode string) bool {
+	switch mode {
+	case "", MatchModeSubstring, MatchModeExact, MatchModeCmdline, MatchModeCgroup:
+		return true
+	}
+	return false
+}
+
+// getProcessPID gets the lowest PID of a running process, returns 0 if not running.
+// For services that fork workers this is usually the parent.
+func (pm *ProcessMonitor) getProcessPID(proc Process) (int, error) {
+	pids, err := pm.prober.PIDs(proc)
+	if err != nil || len(pids) == 0 {
+		return 0, err
+	}
+	return pids[0], nil
+}
+
+// pgrepPIDs returns the PIDs whose command line matches pattern anywhere
+func (p osProber) pgrepPIDs(pattern string) ([]int, error) {
+	output, err := p.output(nil, "pgrep", "-f", pattern)
+	if errors.Is(err, errProbeTimeout) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, nil // Process not running
+	}
+
+	var pids []int
+	for _, field := range strings.Fields(string(output)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PID format: %v", err)
+		}
+		pids = append(pids, pid)
+	}
+
+	sort.Ints(pids)
+	return pids, nil
+}
+
+// scanProcesses walks /proc and returns the PIDs, other than hostd's own, whose
+// command line satisfies match. Processes that exit during the scan are skipped.
+func scanProcesses(match func(pid int, argv []string) bool) ([]int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("error listing processes: %v", err)
+	}
+
+	self := os.Getpid()
+	var pids []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == self {
+			continue
+		}
+
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+		if err != nil || len(data) == 0 {
+			continue // exited, or a kernel thread without a command line
+		}
+
+		argv := strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+		if match(pid, argv) {
+			pids = append(pids, pid)
+		}
+	}
+
+	sort.Ints(pids)
+	return pids, nil
+}
+
+// executableName returns the basename of a process's executable, falling back to
+// argv[0] when the executable link can't be read
+func executableName(pid int, argv []string) string {
+	if exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid)); err == nil {
+		return filepath.Base(strings.TrimSuffix(exe, " (deleted)"))
+	}
+	return filepath.Base(argv[0])
+}
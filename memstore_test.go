@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var _ StatusStore = (*MemoryStore)(nil)
+
+// MemoryStore is a StatusStore kept in memory for tests, using the same keys as
+// RedisClient. Nothing is compressed and the replica-preferring reads see the latest
+// writes.
+type MemoryStore struct {
+	mu     sync.Mutex
+	values map[string]string
+	events []map[string]interface{}
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{values: make(map[string]string)}
+}
+
+func (m *MemoryStore) set(key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = value
+	return nil
+}
+
+func (m *MemoryStore) get(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.values[key]
+	if !ok {
+		return "", redis.Nil
+	}
+	return value, nil
+}
+
+// Get returns the value stored under a key, redis.Nil if there is none
+func (m *MemoryStore) Get(key string) (string, error) {
+	return m.get(key)
+}
+
+// Events returns a copy of the events added so far, oldest first
+func (m *MemoryStore) Events() []map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]map[string]interface{}(nil), m.events...)
+}
+
+// UpdateProcessStatus stores the status of a process
+func (m *MemoryStore) UpdateProcessStatus(ctx context.Context, processName string, status string) error {
+	return m.set(fmt.Sprintf("process:%s:status", processName), status)
+}
+
+// GetProcessStatus gets the status of a process
+func (m *MemoryStore) GetProcessStatus(ctx context.Context, processName string) (string, error) {
+	return m.get(fmt.Sprintf("process:%s:status", processName))
+}
+
+// GetProcessStatusFresh gets the status of a process
+func (m *MemoryStore) GetProcessStatusFresh(ctx context.Context, processName string) (string, error) {
+	return m.get(fmt.Sprintf("process:%s:status", processName))
+}
+
+// GetAllProcessStatuses gets the status of several processes, leaving out those without one
+func (m *MemoryStore) GetAllProcessStatuses(ctx context.Context, processNames []string) (map[string]*ProcessStatus, error) {
+	statuses := make(map[string]*ProcessStatus, len(processNames))
+	for _, name := range processNames {
+		data, err := m.get(fmt.Sprintf("process:%s:status", name))
+		if err == redis.Nil {
+			continue
+		}
+		var status ProcessStatus
+		if err := json.Unmarshal([]byte(data), &status); err != nil {
+			return nil, fmt.Errorf("error decoding status of process %s: %v", name, err)
+		}
+		statuses[name] = &status
+	}
+	return statuses, nil
+}
+
+// GetUptimeStats gets the legacy uptime accumulators of a process
+func (m *MemoryStore) GetUptimeStats(ctx context.Context, processName string) (string, error) {
+	return m.get(fmt.Sprintf("process:%s:uptime", processName))
+}
+
+// UpdateUptimeRatio stores the uptime ratio of a process
+func (m *MemoryStore) UpdateUptimeRatio(ctx context.Context, processName string, ratio float64) error {
+	return m.set(fmt.Sprintf("process:%s:uptime_ratio", processName), fmt.Sprintf("%.6f", ratio))
+}
+
+// GetRuntimeState gets the runtime state of a process
+func (m *MemoryStore) GetRuntimeState(ctx context.Context, processName string) (string, error) {
+	return m.get(fmt.Sprintf("process:%s:runtime", processName))
+}
+
+// UpdateRuntimeState stores the runtime state of a process
+func (m *MemoryStore) UpdateRuntimeState(ctx context.Context, processName string, state string) error {
+	return m.set(fmt.Sprintf("process:%s:runtime", processName), state)
+}
+
+// DeleteProcessKeys removes everything stored for a process
+func (m *MemoryStore) DeleteProcessKeys(ctx context.Context, processName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, suffix := range []string{"status", "uptime", "uptime_ratio", "runtime"} {
+		delete(m.values, fmt.Sprintf("process:%s:%s", processName, suffix))
+	}
+	return nil
+}
+
+// AddEvent records an event
+func (m *MemoryStore) AddEvent(ctx context.Context, values map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, values)
+	return nil
+}
+
+// UpdateHardwareMetrics stores the latest metrics of a FRU
+func (m *MemoryStore) UpdateHardwareMetrics(ctx context.Context, fruType string, instance int, metrics string) error {
+	return m.set(fmt.Sprintf("hardware:%s:%d:metrics", fruType, instance), metrics)
+}
+
+// UpdateHardwareReadFailures stores the failed read counts of a FRU
+func (m *MemoryStore) UpdateHardwareReadFailures(ctx context.Context, fruType string, instance int, failures string) error {
+	return m.set(fmt.Sprintf("hardware:%s:%d:read_failures", fruType, instance), failures)
+}
+
+// UpdateChassisStatus stores the aggregate status of all FRUs
+func (m *MemoryStore) UpdateChassisStatus(ctx context.Context, status string) error {
+	return m.set("hardware:overall:status", status)
+}
+
+// UpdatePowerRedundancy stores the power redundancy of the chassis
+func (m *MemoryStore) UpdatePowerRedundancy(ctx context.Context, status string) error {
+	return m.set("hardware:power:redundancy", status)
+}
+
+// UpdateDiskStatus stores the usage of a mount point
+func (m *MemoryStore) UpdateDiskStatus(ctx context.Context, mount string, status string) error {
+	return m.set("system:disk:"+mount, status)
+}
+
+// UpdateSystemStatus stores a system-wide reading
+func (m *MemoryStore) UpdateSystemStatus(ctx context.Context, name string, status string) error {
+	return m.set("system:"+name, status)
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultMetricsListen = ":9111"
+
+// MetricsExporter keeps the latest value of every gauge and serves them in the
+// Prometheus text exposition format. A nil exporter ignores all observations.
+type MetricsExporter struct {
+	mu       sync.Mutex
+	help     map[string]string
+	series   map[string]map[string]float64 // metric name -> rendered label set -> value
+	counters map[string]bool               // metrics exposed as counters rather than gauges
+}
+
+// NewMetricsExporter creates an empty metrics exporter
+func NewMetricsExporter() *MetricsExporter {
+	return &MetricsExporter{
+		help:     make(map[string]string),
+		series:   make(map[string]map[string]float64),
+		counters: make(map[string]bool),
+	}
+}
+
+// StartMetricsServer serves the exporter on addr at /metrics until ctx is cancelled.
+// The returned channel is closed once the server has shut down.
+func StartMetricsServer(ctx context.Context, addr string, exporter *MetricsExporter, logger *Logger) (<-chan struct{}, error) {
+	if addr == "" {
+		addr = defaultMetricsListen
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+	return serveHTTP(ctx, "Prometheus metrics", addr, mux, logger)
+}
+
+// renderLabels renders label name, value pairs as they appear between the braces
+func renderLabels(labels []string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%s", labels[i], strconv.Quote(labels[i+1]))
+	}
+	return b.String()
+}
+
+// setGauge records the value of a gauge; labels are given as name, value pairs
+func (m *MetricsExporter) setGauge(name, help string, value float64, labels ...string) {
+	rendered := renderLabels(labels)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.help[name] = help
+	if m.series[name] == nil {
+		m.series[name] = make(map[string]float64)
+	}
+	m.series[name][rendered] = value
+}
+
+// addCounter increases a counter by delta; labels are given as name, value pairs
+func (m *MetricsExporter) addCounter(name, help string, delta float64, labels ...string) {
+	rendered := renderLabels(labels)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.help[name] = help
+	m.counters[name] = true
+	if m.series[name] == nil {
+		m.series[name] = make(map[string]float64)
+	}
+	m.series[name][rendered] += delta
+}
+
+// processLabels returns the metric labels of a process: its name followed by its
+// configured labels in name order
+func processLabels(status *ProcessStatus) []string {
+	names := make([]string, 0, len(status.Labels))
+	for name := range status.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	labels := []string{"process", status.Name}
+	for _, name := range names {
+		labels = append(labels, name, status.Labels[name])
+	}
+	return labels
+}
+
+// dropProcessSeries deletes the series of a process, except those with the label set keep
+func (m *MetricsExporter) dropProcessSeries(name, keep string) {
+	prefix := "process=" + strconv.Quote(name)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, series := range m.series {
+		for labels := range series {
+			if labels != keep && (labels == prefix || strings.HasPrefix(labels, prefix+",")) {
+				delete(series, labels)
+			}
+		}
+	}
+}
+
+// ObserveProcess records the latest status of a process
+func (m *MetricsExporter) ObserveProcess(status *ProcessStatus) {
+	if m == nil {
+		return
+	}
+
+	up := 0.0
+	if status.Status == "up" {
+		up = 1
+	}
+
+	// Labels changed by a reload would otherwise leave the old series behind
+	labels := processLabels(status)
+	m.dropProcessSeries(status.Name, renderLabels(labels))
+
+	m.setGauge("hostd_process_up", "Whether the process is running (1) or not (0).", up, labels...)
+	m.setGauge("hostd_process_instances", "Number of running instances of the process.", float64(status.InstanceCount), labels...)
+	m.setGauge("hostd_process_memory_bytes", "Resident memory of all instances of the process in bytes.", float64(status.CurrentMemory), labels...)
+	m.setGauge("hostd_process_cpu_percent", "CPU usage of the process as a percentage of one core.", status.CurrentCPU, labels...)
+	m.setGauge("hostd_process_threads", "Number of threads of the process.", float64(status.Threads), labels...)
+	m.setGauge("hostd_process_open_fds", "Number of open file descriptors of the process.", float64(status.OpenFDs), labels...)
+	m.setGauge("hostd_process_uptime_seconds", "Seconds since the current process instance started.", float64(status.Uptime), labels...)
+	m.setGauge("hostd_process_uptime_ratio", "Fraction of the uptime window the process was up.", status.UptimeRatio, labels...)
+	m.setGauge("hostd_process_restarts", "Automatic restarts since the process was last stable.", float64(status.RestartCount), labels...)
+}
+
+// ForgetProcess drops all gauges of a process that is no longer monitored
+func (m *MetricsExporter) ForgetProcess(name string) {
+	if m == nil {
+		return
+	}
+
+	m.dropProcessSeries(name, "")
+}
+
+// ObserveNPU records the latest metrics of an NPU
+func (m *MetricsExporter) ObserveNPU(instance int, metrics NPUMetrics) {
+	if m == nil {
+		return
+	}
+
+	id := strconv.Itoa(instance)
+	m.setGauge("hostd_npu_packet_rate_pps", "NPU packet rate in packets per second.", metrics.PacketRate, "instance", id)
+	m.setGauge("hostd_npu_throughput_gbps", "NPU throughput in Gbps.", metrics.Throughput, "instance", id)
+	m.setGauge("hostd_npu_buffer_usage_percent", "NPU buffer usage percentage.", metrics.BufferUsage, "instance", id)
+	m.setGauge("hostd_npu_processor_usage_percent", "NPU processor usage percentage.", metrics.ProcessorUsage, "instance", id)
+}
+
+// ObservePSU records the latest metrics of a PSU
+func (m *MetricsExporter) ObservePSU(instance int, metrics PSUMetrics) {
+	if m == nil {
+		return
+	}
+
+	id := strconv.Itoa(instance)
+	m.setGauge("hostd_psu_voltage_volts", "PSU output voltage.", metrics.Voltage, "instance", id)
+	m.setGauge("hostd_psu_current_amps", "PSU output current.", metrics.Current, "instance", id)
+	m.setGauge("hostd_psu_power_watts", "PSU output power.", metrics.Power, "instance", id)
+}
+
+// ObserveFan records the latest metrics of a fan
+func (m *MetricsExporter) ObserveFan(instance int, metrics FanMetrics) {
+	if m == nil {
+		return
+	}
+
+	id := strconv.Itoa(instance)
+	m.setGauge("hostd_fan_speed_rpm", "Fan speed of the slowest rotor in RPM.", float64(metrics.Speed), "instance", id)
+	m.setGauge("hostd_fan_duty_percent", "Fan duty cycle percentage.", float64(metrics.Duty), "instance", id)
+}
+
+// ObserveSystemLoad records the latest system load average
+func (m *MetricsExporter) ObserveSystemLoad(load SystemLoad) {
+	if m == nil {
+		return
+	}
+
+	m.setGauge("hostd_system_load1", "System load average over 1 minute.", load.Load1)
+	m.setGauge("hostd_system_load5", "System load average over 5 minutes.", load.Load5)
+	m.setGauge("hostd_system_load15", "System load average over 15 minutes.", load.Load15)
+}
+
+// ObserveSystemMemory records the latest system memory usage
+func (m *MetricsExporter) ObserveSystemMemory(memory SystemMemory) {
+	if m == nil {
+		return
+	}
+
+	m.setGauge("hostd_system_memory_total_bytes", "Total system memory in bytes.", float64(memory.TotalBytes))
+	m.setGauge("hostd_system_memory_available_bytes", "System memory available for new work in bytes.", float64(memory.AvailableBytes))
+}
+
+// ObserveKafkaDropped counts events dropped because the Kafka queue was full
+func (m *MetricsExporter) ObserveKafkaDropped(events int) {
+	if m == nil {
+		return
+	}
+
+	m.addCounter("hostd_kafka_dropped_events_total", "Events dropped because the Kafka queue was full.", float64(events))
+}
+
+// ObserveKafkaFailures counts events whose delivery to Kafka failed
+func (m *MetricsExporter) ObserveKafkaFailures(events int) {
+	if m == nil {
+		return
+	}
+
+	m.addCounter("hostd_kafka_delivery_failures_total", "Events whose delivery to Kafka failed.", float64(events))
+}
+
+// ServeHTTP writes all gauges and counters in the Prometheus text exposition format
+func (m *MetricsExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(m.series))
+	for name := range m.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, name := range names {
+		kind := "gauge"
+		if m.counters[name] {
+			kind = "counter"
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, m.help[name], name, kind)
+
+		labelSets := make([]string, 0, len(m.series[name]))
+		for labels := range m.series[name] {
+			labelSets = append(labelSets, labels)
+		}
+		sort.Strings(labelSets)
+
+		for _, labels := range labelSets {
+			value := strconv.FormatFloat(m.series[name][labels], 'g', -1, 64)
+			if labels == "" {
+				fmt.Fprintf(w, "%s %s\n", name, value)
+			} else {
+				fmt.Fprintf(w, "%s{%s} %s\n", name, labels, value)
+			}
+		}
+	}
+}
@@ -14,29 +14,49 @@ type NPUMetrics struct {
 	BufferUsage    float64 `json:"buffer_usage"`    // Percentage of buffer usage
 	ProcessorUsage float64 `json:"processor_usage"` // NPU processor utilization
 	Timestamp      string  `json:"timestamp"`
+
+	LastUpdated string `json:"last_updated"` // when the readings last changed
+	Stale       bool   `json:"stale"`        // readings unchanged for longer than the staleness thresholds allow
+
+	SchemaVersion int `json:"schema_version"` // hardwareMetricsSchemaVersion when stored
 }
 
 // NPU represents a Network Processing Unit
 type NPU struct {
 	name           string
 	logger         *Logger
-	redis          *RedisClient
-	packetRate     float64 // Packets per second
-	throughput     float64 // Gbps
-	bufferUsage    float64 // Percentage
-	processorUsage float64 // Percentage
+	redis          StatusStore
+	metrics        *MetricsExporter // nil when the exporter is disabled
+	packetRate     float64          // Packets per second
+	throughput     float64          // Gbps
+	bufferUsage    float64          // Percentage
+	processorUsage float64          // Percentage
 	isPresent      bool
+	presencePath   string // file that exists while the FRU is present, empty to assume present
 	instance       int
+	thresholds     NPUThresholds
+	source         NPUSource // where traffic and usage are read from
+	freshness      freshness
+	retry          readRetry
 }
 
-// NewNPU creates a new Network Processing Unit instance
-func NewNPU(name string, instance int, logger *Logger, redis *RedisClient) *NPU {
+// NPU must keep satisfying HardwareInterface
+var _ HardwareInterface = (*NPU)(nil)
+
+// NPUSource reads an NPU's traffic and resource usage
+type NPUSource func(ctx context.Context) (NPUMetrics, error)
+
+// NewNPU creates a new Network Processing Unit instance reading simulated values
+func NewNPU(name string, instance int, thresholds NPUThresholds, logger *Logger, redis StatusStore, metrics *MetricsExporter) *NPU {
 	return &NPU{
-		name:      name,
-		logger:    logger,
-		redis:     redis,
-		isPresent: true, // Initially assume NPU is present
-		instance:  instance,
+		name:       name,
+		logger:     logger.WithFields(map[string]interface{}{"fru": fmt.Sprintf("%s-%d", name, instance)}),
+		redis:      redis,
+		metrics:    metrics,
+		isPresent:  true, // Initially assume NPU is present
+		instance:   instance,
+		thresholds: thresholds,
+		source:     simulatedNPU,
 	}
 }
 
@@ -45,39 +65,56 @@ func (n *NPU) getName() string {
 }
 
 func (n *NPU) getStatus(ctx context.Context) (FruStatus, error) {
+	if n.presencePath != "" {
+		n.isPresent = fruPresent(n.presencePath)
+	}
 	if !n.isPresent {
 		return FruStatusRed, fmt.Errorf("NPU %d not present", n.instance)
 	}
 
-	if err := n.updateMetrics(ctx); err != nil {
+	if err := n.retry.read(ctx, n.redis, n.logger, "npu", n.instance, n.updateMetrics); err != nil {
 		return FruStatusRed, fmt.Errorf("failed to update NPU %d metrics", n.instance)
 	}
 
-	// Example thresholds for network processing metrics
-	if n.bufferUsage > 95 || n.processorUsage > 95 { // Critical resource exhaustion
+	// Readings that stopped changing may come from a stuck sensor
+	stale := n.freshness.status(n.thresholds.StalenessThresholds, time.Now())
+	if stale == FruStatusRed {
+		return FruStatusRed, fmt.Errorf("NPU %d readings unchanged since %s", n.instance, n.freshness.lastUpdated.Format(time.RFC3339))
+	}
+
+	if n.bufferUsage > n.thresholds.BufferCritical || n.processorUsage > n.thresholds.ProcessorCritical { // Critical resource exhaustion
 		return FruStatusRed, nil
 	}
-	if n.bufferUsage > 80 || n.processorUsage > 85 { // High resource utilization
+	if n.bufferUsage > n.thresholds.BufferWarn || n.processorUsage > n.thresholds.ProcessorWarn { // High resource utilization
 		return FruStatusYellow, nil
 	}
-	return FruStatusGreen, nil
+	return stale, nil
 }
 
 func (n *NPU) updateMetrics(ctx context.Context) error {
-	// In a real implementation, this would read from hardware
-	// For now, using example values
-	n.packetRate = 1000000.0  // 1M packets per second
-	n.throughput = 40.0       // 40 Gbps
-	n.bufferUsage = 60.0      // 60% buffer usage
-	n.processorUsage = 70.0   // 70% NPU processor utilization
+	reading, err := n.source(ctx)
+	if err != nil {
+		n.logger.Error("Failed to read NPU %d: %v", n.instance, err)
+		return err
+	}
+	n.packetRate = reading.PacketRate
+	n.throughput = reading.Throughput
+	n.bufferUsage = reading.BufferUsage
+	n.processorUsage = reading.ProcessorUsage
+
+	now := time.Now()
+	n.freshness.observe(reading, now)
 
 	// Create metrics structure
 	metrics := NPUMetrics{
+		SchemaVersion:  hardwareMetricsSchemaVersion,
 		PacketRate:     n.packetRate,
 		Throughput:     n.throughput,
 		BufferUsage:    n.bufferUsage,
 		ProcessorUsage: n.processorUsage,
-		Timestamp:      time.Now().Format(time.RFC3339),
+		Timestamp:      now.Format(time.RFC3339),
+		LastUpdated:    n.freshness.lastUpdated.Format(time.RFC3339),
+		Stale:          n.freshness.status(n.thresholds.StalenessThresholds, now) != FruStatusGreen,
 	}
 
 	// Convert metrics to JSON
@@ -88,22 +125,50 @@ func (n *NPU) updateMetrics(ctx context.Context) error {
 	}
 
 	// Store metrics in Redis
-	key := fmt.Sprintf("hardware:npu:%d:metrics", n.instance)
-	if err := n.redis.client.Set(ctx, key, string(metricsJSON), 0).Err(); err != nil {
+	if err := n.redis.UpdateHardwareMetrics(ctx, "npu", n.instance, string(metricsJSON)); err != nil {
 		n.logger.Error("Failed to store NPU %d metrics in Redis: %v", n.instance, err)
 		return err
 	}
 
-	n.logger.Info("Updated NPU %d metrics: PacketRate=%.1f pps, Throughput=%.1f Gbps, BufferUsage=%.1f%%, ProcessorUsage=%.1f%%",
+	n.metrics.ObserveNPU(n.instance, metrics)
+
+	n.logger.Debug("Updated NPU %d metrics: PacketRate=%.1f pps, Throughput=%.1f Gbps, BufferUsage=%.1f%%, ProcessorUsage=%.1f%%",
 		n.instance, n.packetRate, n.throughput, n.bufferUsage, n.processorUsage)
 	return nil
 }
 
+// simulatedNPU reports fixed example values, for hosts without an NPU backend
+func simulatedNPU(ctx context.Context) (NPUMetrics, error) {
+	return NPUMetrics{
+		PacketRate:     1000000.0, // 1M packets per second
+		Throughput:     40.0,      // 40 Gbps
+		BufferUsage:    60.0,      // 60% buffer usage
+		ProcessorUsage: 70.0,      // 70% NPU processor utilization
+	}, nil
+}
+
 func (n *NPU) available() bool {
 	return n.isPresent
 }
 
 func (n *NPU) setInstance(instance int) {
 	n.instance = instance
+	n.logger = n.logger.WithFields(map[string]interface{}{"fru": n.getName()})
 	n.logger.Info("Set NPU instance to %d", instance)
 }
+
+// setReadRetry sets how failed metric reads are retried
+func (n *NPU) setReadRetry(config ReadRetryConfig) {
+	n.retry.config = config
+}
+
+// setPresencePath sets the file whose existence shows the NPU is present
+func (n *NPU) setPresencePath(path string) {
+	n.presencePath = path
+	n.logger.Info("Set NPU %d presence path to %s", n.instance, path)
+}
+
+// setSource replaces where the NPU's readings come from
+func (n *NPU) setSource(source NPUSource) {
+	n.source = source
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultOutputLines = 200  // lines of output kept per process hostd started
+	maxOutputLine      = 4096 // longer lines are split, bounding the buffer's memory
+	crashOutputLines   = 20   // lines of output included in the log when a process stops
+)
+
+// outputRing keeps the most recent lines a process wrote to stdout and stderr
+type outputRing struct {
+	mu      sync.Mutex
+	lines   []string // circular once full, the oldest line at next
+	next    int
+	size    int
+	partial []byte // output after the last newline
+}
+
+func newOutputRing(size int) *outputRing {
+	return &outputRing{size: size}
+}
+
+// Write splits output into lines and keeps the last ones
+func (o *outputRing) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	data := append(o.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		o.add(string(data[:i]))
+		data = data[i+1:]
+	}
+	for len(data) > maxOutputLine {
+		o.add(string(data[:maxOutputLine]))
+		data = data[maxOutputLine:]
+	}
+	o.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+// mark adds a line of hostd's own between runs of the process
+func (o *outputRing) mark(format string, v ...interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.partial) > 0 {
+		o.add(string(o.partial))
+		o.partial = nil
+	}
+	o.add(fmt.Sprintf("--- "+format+" ---", v...))
+}
+
+// add appends a line, dropping the oldest once the buffer is full. The caller holds mu.
+func (o *outputRing) add(line string) {
+	if len(o.lines) < o.size {
+		o.lines = append(o.lines, line)
+		return
+	}
+	o.lines[o.next] = line
+	o.next = (o.next + 1) % o.size
+}
+
+// tail returns up to the last n lines, oldest first, including any unterminated line.
+// n <= 0 returns everything kept.
+func (o *outputRing) tail(n int) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	lines := make([]string, 0, len(o.lines)+1)
+	lines = append(lines, o.lines[o.next:]...)
+	lines = append(lines, o.lines[:o.next]...)
+	if len(o.partial) > 0 {
+		lines = append(lines, string(o.partial))
+	}
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// captureOutput returns where a process about to be started should write its stdout and
+// stderr: its output buffer and, if configured, its output file. The returned function
+// closes the file once the process has exited. A nil writer discards the output.
+func (pm *ProcessMonitor) captureOutput(proc Process) (io.Writer, func(), error) {
+	var writers []io.Writer
+	closeOutput := func() {}
+
+	if ring := pm.outputBuffer(proc); ring != nil {
+		ring.mark("starting %s at %s", proc.Name, time.Now().Format(time.RFC3339))
+		writers = append(writers, ring)
+	}
+	if proc.OutputFile != "" {
+		file, err := os.OpenFile(proc.OutputFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error opening output file of process %s: %v", proc.Name, err)
+		}
+		writers = append(writers, file)
+		closeOutput = func() { file.Close() }
+	}
+
+	switch len(writers) {
+	case 0:
+		return nil, closeOutput, nil
+	case 1:
+		return writers[0], closeOutput, nil
+	default:
+		return io.MultiWriter(writers...), closeOutput, nil
+	}
+}
+
+// outputBuffer returns the output buffer of a process, creating it on first use or when
+// its configured size changed. It returns nil if capture is disabled for the process.
+func (pm *ProcessMonitor) outputBuffer(proc Process) *outputRing {
+	size := proc.OutputLines
+	if size == 0 {
+		size = defaultOutputLines
+	}
+	if size < 0 {
+		return nil
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	ring, ok := pm.outputs[proc.Name]
+	if !ok || ring.size != size {
+		ring = newOutputRing(size)
+		pm.outputs[proc.Name] = ring
+	}
+	return ring
+}
+
+// OutputTail returns up to the last n captured output lines of a process, oldest first,
+// nil if hostd hasn't started it. n <= 0 returns everything kept.
+func (pm *ProcessMonitor) OutputTail(name string, n int) []string {
+	pm.mu.Lock()
+	ring, ok := pm.outputs[name]
+	pm.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return ring.tail(n)
+}
@@ -2,31 +2,121 @@ package main
 
 import (
 	"context"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"runtime"
 	"sync"
 	"time"
 )
 
-// PeriodicRunner handles periodic tasks
+const (
+	defaultCheckInterval = time.Minute
+	minCheckInterval     = time.Second
+)
+
+const (
+	defaultWatchdogMultiplier = 3
+	WatchdogActionLog         = "log"
+	WatchdogActionExit        = "exit"
+)
+
+// PeriodicRunner runs named tasks, each in its own goroutine on its own interval
 type PeriodicRunner struct {
-	monitor    *ProcessMonitor
-	logger     *Logger
-	wg         sync.WaitGroup
-	lastCheck  time.Time
-	checkMutex sync.Mutex
+	tasks     []*periodicTask
+	logger    *Logger
+	watchdog  WatchdogConfig
+	jitter    JitterConfig
+	rngMu     sync.Mutex
+	rng       *rand.Rand // draws the jitter offsets
+	wg        sync.WaitGroup
+	beatMutex sync.Mutex // guards the run bookkeeping of every task
+	cancelMu  sync.Mutex
+	cancel    context.CancelFunc // stops the goroutines started by Start, nil before Start
+	ready     chan struct{}      // closed once every task has completed a run
+}
+
+// periodicTask is a job the runner repeats every interval
+type periodicTask struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context)
+	lastBeat time.Time // when the last run completed
+	swept    bool      // whether any run has completed
 }
 
-// NewPeriodicRunner creates a new periodic runner
-func NewPeriodicRunner(monitor *ProcessMonitor, logger *Logger) *PeriodicRunner {
+// NewPeriodicRunner creates a periodic runner without tasks
+func NewPeriodicRunner(watchdog WatchdogConfig, jitter JitterConfig, logger *Logger) *PeriodicRunner {
+	if watchdog.Multiplier <= 0 {
+		watchdog.Multiplier = defaultWatchdogMultiplier
+	}
+	if watchdog.Action == "" {
+		watchdog.Action = WatchdogActionLog
+	}
+
 	return &PeriodicRunner{
-		monitor: monitor,
-		logger:  logger,
+		logger:   logger,
+		watchdog: watchdog,
+		jitter:   jitter,
+		rng:      rand.New(rand.NewSource(jitterSeed(jitter.Seed))),
+		ready:    make(chan struct{}),
+	}
+}
+
+// jitterSeed returns the seed of the jitter offsets: the configured seed mixed with the
+// hostname, so hosts sharing a config still get different but reproducible offsets
+func jitterSeed(seed *int64) int64 {
+	if seed == nil {
+		return time.Now().UnixNano()
+	}
+	hostname, _ := os.Hostname()
+	h := fnv.New64a()
+	h.Write([]byte(hostname))
+	return *seed ^ int64(h.Sum64())
+}
+
+// offset returns a random duration within ±fraction of interval
+func (pr *PeriodicRunner) offset(interval time.Duration) time.Duration {
+	pr.rngMu.Lock()
+	defer pr.rngMu.Unlock()
+	return time.Duration((pr.rng.Float64()*2 - 1) * pr.jitter.Fraction * float64(interval))
+}
+
+// AddTask registers a task to run every interval. A zero interval uses the default, and
+// one below the minimum is logged and replaced by the default. Tasks must be added
+// before Start.
+func (pr *PeriodicRunner) AddTask(name string, interval time.Duration, run func(ctx context.Context)) {
+	if interval == 0 {
+		interval = defaultCheckInterval
+	} else if interval < minCheckInterval {
+		pr.logger.Error("Interval %v of task %s is below the minimum of %v, using the default of %v",
+			interval, name, minCheckInterval, defaultCheckInterval)
+		interval = defaultCheckInterval
 	}
+
+	pr.tasks = append(pr.tasks, &periodicTask{name: name, interval: interval, run: run})
 }
 
-// Start begins the periodic execution
+// Start begins the periodic execution. It runs until ctx is cancelled or Stop is called.
 func (pr *PeriodicRunner) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	pr.cancelMu.Lock()
+	pr.cancel = cancel
+	pr.cancelMu.Unlock()
+
+	for _, task := range pr.tasks {
+		pr.heartbeat(task, false)
+		pr.wg.Add(1)
+		go pr.runTask(ctx, task)
+	}
+
+	if !pr.watchdog.Disabled && len(pr.tasks) > 0 {
+		pr.wg.Add(1)
+		go pr.runWatchdog(ctx)
+	}
+
 	pr.wg.Add(1)
-	go pr.run(ctx)
+	go pr.notifySystemd(ctx)
 }
 
 // Wait waits for all periodic tasks to complete
@@ -34,11 +124,46 @@ func (pr *PeriodicRunner) Wait() {
 	pr.wg.Wait()
 }
 
-// run executes the periodic tasks
-func (pr *PeriodicRunner) run(ctx context.Context) {
+// Stop stops the periodic tasks and waits for them to finish, including runs in
+// progress. It is safe to call more than once, and before Start.
+func (pr *PeriodicRunner) Stop() {
+	pr.cancelMu.Lock()
+	cancel := pr.cancel
+	pr.cancelMu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	pr.wg.Wait()
+}
+
+// runTask repeats a task until ctx is cancelled. The first run happens straight away and
+// the ticker then fires once per interval, so long intervals don't cost idle wakeups.
+// With jitter the first run is delayed by a random part of the jitter range, and with
+// everyRun each later wait is the interval plus a random offset. Runs of one task never overlap.
+func (pr *PeriodicRunner) runTask(ctx context.Context, task *periodicTask) {
 	defer pr.wg.Done()
 
-	ticker := time.NewTicker(1 * time.Second)
+	if pr.jitter.Fraction > 0 {
+		delay := pr.offset(task.interval)
+		if delay < 0 {
+			delay = -delay
+		}
+		pr.logger.Debug("Delaying first run of periodic task %s by %v", task.name, delay.Round(time.Millisecond))
+		if !sleepContext(ctx, delay) {
+			return
+		}
+	}
+	pr.runOnce(ctx, task, time.Now())
+
+	if pr.jitter.Fraction > 0 && pr.jitter.EveryRun {
+		for sleepContext(ctx, task.interval+pr.offset(task.interval)) {
+			pr.runOnce(ctx, task, time.Now())
+		}
+		return
+	}
+
+	ticker := time.NewTicker(task.interval)
 	defer ticker.Stop()
 
 	for {
@@ -46,18 +171,130 @@ func (pr *PeriodicRunner) run(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case currentTime := <-ticker.C:
-			pr.checkMutex.Lock()
-			if currentTime.Sub(pr.lastCheck) >= time.Minute {
-				pr.logger.Info("Running periodic process check at %v", currentTime.Format(time.RFC3339))
-				
-				// Run process monitoring
-				for _, proc := range pr.monitor.processes {
-					pr.monitor.updateProcStatus(ctx, proc)
+			pr.runOnce(ctx, task, currentTime)
+		}
+	}
+}
+
+// sleepContext waits for d, reporting false if ctx was cancelled first
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// runOnce runs a task and records that it completed
+func (pr *PeriodicRunner) runOnce(ctx context.Context, task *periodicTask, currentTime time.Time) {
+	pr.logger.Debug("Running periodic task %s at %v", task.name, currentTime.Format(time.RFC3339))
+	task.run(ctx)
+	pr.heartbeat(task, true)
+}
+
+// heartbeat records that a run of a task completed, or resets its timer at start.
+// The first time every task has completed a run, the ready channel is closed.
+func (pr *PeriodicRunner) heartbeat(task *periodicTask, ran bool) {
+	pr.beatMutex.Lock()
+	defer pr.beatMutex.Unlock()
+
+	task.lastBeat = time.Now()
+	if !ran || task.swept {
+		return
+	}
+	task.swept = true
+	for _, t := range pr.tasks {
+		if !t.swept {
+			return
+		}
+	}
+	close(pr.ready)
+}
+
+// watchdogTimeout is how long a task may go without completing a run before it is
+// considered stuck
+func (pr *PeriodicRunner) watchdogTimeout(task *periodicTask) time.Duration {
+	return time.Duration(pr.watchdog.Multiplier) * task.interval
+}
+
+// Alive reports whether every task has completed a run within its watchdog timeout
+func (pr *PeriodicRunner) Alive() bool {
+	pr.beatMutex.Lock()
+	defer pr.beatMutex.Unlock()
+	for _, task := range pr.tasks {
+		if time.Since(task.lastBeat) >= pr.watchdogTimeout(task) {
+			return false
+		}
+	}
+	return true
+}
+
+// Swept reports whether every task has completed at least one run
+func (pr *PeriodicRunner) Swept() bool {
+	pr.beatMutex.Lock()
+	defer pr.beatMutex.Unlock()
+	for _, task := range pr.tasks {
+		if !task.swept {
+			return false
+		}
+	}
+	return true
+}
+
+// runWatchdog fires when a task doesn't complete a run within its watchdog timeout,
+// which means its loop is stuck
+func (pr *PeriodicRunner) runWatchdog(ctx context.Context) {
+	defer pr.wg.Done()
+
+	shortest := pr.watchdogTimeout(pr.tasks[0])
+	for _, task := range pr.tasks[1:] {
+		if timeout := pr.watchdogTimeout(task); timeout < shortest {
+			shortest = timeout
+		}
+	}
+	ticker := time.NewTicker(shortest / 10)
+	defer ticker.Stop()
+
+	fired := make(map[string]bool, len(pr.tasks))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, task := range pr.tasks {
+				timeout := pr.watchdogTimeout(task)
+				pr.beatMutex.Lock()
+				stalled := time.Since(task.lastBeat)
+				pr.beatMutex.Unlock()
+
+				if stalled < timeout {
+					fired[task.name] = false
+					continue
+				}
+				if fired[task.name] {
+					continue
+				}
+				fired[task.name] = true
+
+				pr.logger.Critical("Watchdog: periodic task %s has not completed in %v (timeout %v), dumping goroutine stacks",
+					task.name, stalled.Round(time.Second), timeout)
+				dumpGoroutines()
+
+				if pr.watchdog.Action == WatchdogActionExit {
+					pr.logger.Critical("Watchdog: exiting so the supervisor can restart hostd")
+					os.Exit(2)
 				}
-				
-				pr.lastCheck = currentTime
 			}
-			pr.checkMutex.Unlock()
 		}
 	}
 }
+
+// dumpGoroutines writes the stacks of all goroutines to stderr
+func dumpGoroutines() {
+	buf := make([]byte, 1<<20)
+	n := runtime.Stack(buf, true)
+	os.Stderr.Write(buf[:n])
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/pprof"
+)
+
+const defaultPprofListen = "127.0.0.1:6060"
+
+// DebugConfig holds troubleshooting settings that are off by default
+type DebugConfig struct {
+	PProf  bool   `json:"pprof"`  // serve Go's pprof profiles under /debug/pprof/
+	Listen string `json:"listen"` // address to serve them on, default 127.0.0.1:6060
+}
+
+// StartPprofServer serves the pprof handlers on addr under /debug/pprof/ until ctx is
+// cancelled. The profiles expose internals and cost CPU while running, so they are
+// only meant for troubleshooting. The returned channel is closed once the server has
+// shut down.
+func StartPprofServer(ctx context.Context, addr string, logger *Logger) (<-chan struct{}, error) {
+	if addr == "" {
+		addr = defaultPprofListen
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			logger.Error("pprof is served on %s, which is not a loopback address", addr)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return serveHTTP(ctx, "pprof", addr, mux, logger)
+}
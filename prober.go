@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProcessProber inspects running processes. ProcessMonitor uses it for everything it
+// learns about a process from the OS, so the probing can be replaced in tests.
+type ProcessProber interface {
+	// PIDs returns the PIDs of every running process matching proc, in ascending order
+	PIDs(proc Process) ([]int, error)
+
+	// Memory returns the combined resident memory of the given PIDs in bytes
+	Memory(pids []int) (int64, error)
+
+	// CPUTimes returns the user plus system CPU time each of the given PIDs has used
+	// since it started, leaving out PIDs that have exited
+	CPUTimes(pids []int) (map[int]time.Duration, error)
+
+	// Threads returns the thread count of a process
+	Threads(pid int) (int, error)
+
+	// OpenFDs returns the number of open file descriptors of a process
+	OpenFDs(pid int) (int, error)
+
+	// Env returns the environment of a process
+	Env(pid int) (map[string]string, error)
+
+	// StartTime returns when a process was started
+	StartTime(pid int) (time.Time, error)
+}
+
+// defaultProbeTimeout bounds each pgrep or ps run
+const defaultProbeTimeout = 10 * time.Second
+
+// errProbeTimeout is returned when a probe command is killed for taking too long, as on
+// a wedged host. It means the state of the process is unknown, not that it is down.
+var errProbeTimeout = errors.New("probe timed out")
+
+// osProber probes processes through pgrep, ps and /proc
+type osProber struct {
+	timeout time.Duration // limit on each command, defaultProbeTimeout if 0
+}
+
+// output runs a probe command and returns its standard output, killing it and returning
+// errProbeTimeout if it runs past the probe timeout
+func (p osProber) output(env []string, name string, args ...string) ([]byte, error) {
+	timeout := p.timeout
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = env
+	output, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%w: %s did not finish within %v", errProbeTimeout, name, timeout)
+	}
+	return output, err
+}
+
+// PIDs gets the PIDs of every running process matching proc, in ascending
+// order. An empty result means the process is not running.
+func (p osProber) PIDs(proc Process) ([]int, error) {
+	if proc.Cgroup != "" {
+		return cgroupPIDs(proc.Cgroup)
+	}
+
+	switch proc.MatchMode {
+	case MatchModeExact:
+		return scanProcesses(func(pid int, argv []string) bool {
+			return executableName(pid, argv) == proc.Name
+		})
+	case MatchModeCmdline:
+		want := proc.Name
+		if proc.Command != "" {
+			want = strings.Join(append([]string{proc.Command}, proc.Args...), " ")
+		}
+		return scanProcesses(func(pid int, argv []string) bool {
+			return strings.Join(argv, " ") == want
+		})
+	default:
+		return p.pgrepPIDs(proc.Name)
+	}
+}
+
+// Memory gets the combined memory usage in bytes of a process's instances
+func (p osProber) Memory(pids []int) (int64, error) {
+	output, err := p.psColumn("rss=", pids)
+	if err != nil {
+		return 0, fmt.Errorf("error getting memory usage: %v", err)
+	}
+
+	var total int64
+	for _, field := range output {
+		// Convert KB to bytes (ps outputs in KB)
+		memKB, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing memory value: %v", err)
+		}
+		total += memKB * 1024
+	}
+
+	return total, nil
+}
+
+// psColumn runs ps for the given PIDs and returns one value of the output column per
+// PID still running
+func (p osProber) psColumn(column string, pids []int) ([]string, error) {
+	ids := make([]string, len(pids))
+	for i, pid := range pids {
+		ids[i] = strconv.Itoa(pid)
+	}
+
+	output, err := p.output(nil, "ps", "-o", column, "-p", strings.Join(ids, ","))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// procMounted reports whether /proc is available; without it, as on non-Linux
+// systems, the /proc based probes report zero instead of failing
+func procMounted() bool {
+	_, err := os.Stat("/proc/self")
+	return err == nil
+}
+
+// Threads gets the current thread count of a process from /proc
+func (osProber) Threads(pid int) (int, error) {
+	if !procMounted() {
+		return 0, nil
+	}
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0, fmt.Errorf("error reading process status: %v", err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "Threads:"); ok {
+			threads, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return 0, fmt.Errorf("error parsing thread count: %v", err)
+			}
+			return threads, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no thread count in process status")
+}
+
+// OpenFDs counts the open file descriptors of a process in /proc
+func (osProber) OpenFDs(pid int) (int, error) {
+	if !procMounted() {
+		return 0, nil
+	}
+	entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", pid))
+	if err != nil {
+		return 0, fmt.Errorf("error reading file descriptors: %v", err)
+	}
+	return len(entries), nil
+}
+
+// Env reads the environment of a running process from /proc
+func (osProber) Env(pid int) (map[string]string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		return nil, fmt.Errorf("error reading environment: %v", err)
+	}
+
+	env := make(map[string]string)
+	for _, entry := range strings.Split(string(data), "\x00") {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			env[key] = value
+		}
+	}
+
+	return env, nil
+}
+
+// psStartLayout is the format of the ps lstart column, after collapsing repeated spaces
+const psStartLayout = "Mon Jan 2 15:04:05 2006"
+
+// StartTime gets the start time of a process from ps, to the second
+func (p osProber) StartTime(pid int) (time.Time, error) {
+	output, err := p.output(append(os.Environ(), "LC_ALL=C"), "ps", "-o", "lstart=", "-p", strconv.Itoa(pid))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error getting start time: %v", err)
+	}
+
+	started, err := time.ParseInLocation(psStartLayout, strings.Join(strings.Fields(string(output)), " "), time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing start time: %v", err)
+	}
+	return started, nil
+}
@@ -3,90 +3,475 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
-	"strconv"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
+// Restart policies applied when a child started by hostd exits
+const (
+	RestartOnAlways        = "always"
+	RestartOnFailure       = "on-failure"
+	RestartOnNever         = "never"
+	defaultRestartOnPolicy = RestartOnFailure
+)
+
+const (
+	defaultStartVerifyAttempts = 5
+	defaultStartVerifyInterval = time.Second
+)
+
+// defaultCheckConcurrency is how many processes a check cycle probes at once
+const defaultCheckConcurrency = 8
+
+// processStatusSchemaVersion is the current shape of the ProcessStatus stored in Redis,
+// written with every record so consumers can tell old records from new ones. Bump it
+// when a field is removed or changes type or meaning; adding a field does not need a
+// bump. Records from before versioning have no schema_version and read as 0.
+const processStatusSchemaVersion = 1
+
 // ProcessStatus represents the current status of a process
 type ProcessStatus struct {
-	Name          string     `json:"name"`
-	CurrentPID    int        `json:"current_pid"`
-	PreviousPID   *int       `json:"previous_pid,omitempty"`
-	Status        string     `json:"status"`
-	LastChange    time.Time  `json:"last_change"`
-	MemoryStats   MemoryStats `json:"memory_stats"`
-	CurrentMemory int64      `json:"current_memory"` // in bytes
+	SchemaVersion  int         `json:"schema_version"`
+	Name           string      `json:"name"`
+	CurrentPID     int         `json:"current_pid"` // lowest PID of all instances
+	PIDs           []int       `json:"pids,omitempty"`
+	InstanceCount  int         `json:"instance_count"`
+	PreviousPID    *int        `json:"previous_pid,omitempty"`
+	Status         string      `json:"status"`
+	LastChange     time.Time   `json:"last_change"`
+	StartTime      time.Time   `json:"start_time"`     // when the current PID started, zero while down
+	Uptime         int64       `json:"uptime_seconds"` // seconds since StartTime
+	MemoryStats    MemoryStats `json:"memory_stats"`
+	CurrentMemory  int64       `json:"current_memory"` // in bytes
+	CPUStats       CPUStats    `json:"cpu_stats"`
+	CurrentCPU     float64     `json:"current_cpu"`  // percent of one core, 0 while down
+	UptimeRatio    float64     `json:"uptime_ratio"` // fraction of the uptime window spent up
+	EnvDrift       bool        `json:"env_drift"`
+	DriftedEnv     []string    `json:"drifted_env,omitempty"`     // values of secret-looking keys are redacted
+	ContainerState string      `json:"container_state,omitempty"` // Docker state for container processes, "missing" if absent
+	Threads        int         `json:"threads"`
+	ThreadsAlert   bool        `json:"threads_alert"` // thread count outside the configured range
+	RestartCount   int         `json:"restart_count"` // automatic restarts since the process was last stable
+	Flapping       bool        `json:"flapping"`      // restarted too often, restarts paused for a cooldown
+	MemoryAlert    bool        `json:"memory_alert"`  // memory over the configured limit
+	CPUAlert       bool        `json:"cpu_alert"`     // CPU over the configured limit
+	OpenFDs        int         `json:"open_fds"`      // 0 where /proc is unavailable
+	FDAlert        bool        `json:"fd_alert"`      // open file descriptors over the configured limit
+	Alerting       bool        `json:"alerting"`      // any resource limit alert is active
+
+	SuspectedLeak bool `json:"suspected_leak"` // memory trending up faster than the configured leak threshold
+
+	Labels map[string]string `json:"labels,omitempty"` // copied from the process config
+
+	Synthetic bool `json:"synthetic,omitempty"` // status forced by an inject-status command, not observed
+
+	Health *HealthResult `json:"health,omitempty"` // latest health check, nil if none is configured
 }
 
 // MemoryStats tracks memory usage statistics
 type MemoryStats struct {
-	MinMemory    int64     `json:"min_memory"`    // in bytes
-	MaxMemory    int64     `json:"max_memory"`    // in bytes
+	MinMemory    int64     `json:"min_memory"` // in bytes
+	MaxMemory    int64     `json:"max_memory"` // in bytes
+	MinTimestamp time.Time `json:"min_timestamp"`
+	MaxTimestamp time.Time `json:"max_timestamp"`
+}
+
+// CPUStats tracks CPU usage statistics
+type CPUStats struct {
+	MinCPU       float64   `json:"min_cpu"` // percent of one core
+	MaxCPU       float64   `json:"max_cpu"` // percent of one core
 	MinTimestamp time.Time `json:"min_timestamp"`
 	MaxTimestamp time.Time `json:"max_timestamp"`
 }
 
 // ProcessMonitor handles process monitoring
 type ProcessMonitor struct {
-	processes []Process
-	redis     *RedisClient
-	logger    *Logger
+	procMu        sync.RWMutex // guards processes and restartGroups, which are swapped on reload
+	processes     []Process
+	restartGroups map[string][]Process // group name -> members in config order
+	config        MonitoringConfig
+	mu            sync.Mutex // guards runtime, procLocks, launchedAt, cpuSamples and synthetic
+	runtime       map[string]*ProcessRuntimeState
+	procLocks     map[string]*sync.Mutex // serializes status updates per process
+	childExits    map[string]int         // exit codes of children hostd started, by process name
+	outputs       map[string]*outputRing // recent output of children hostd started, by process name
+	launchedAt    map[string]time.Time   // when hostd last started each process itself
+	cpuSamples    map[string]cpuSample   // CPU times at the last check, by process name
+	prober        ProcessProber
+	docker        *DockerClient
+	startedAt     time.Time
+	metrics       *MetricsExporter // nil when the exporter is disabled
+	notifier      *Notifier        // nil when no webhooks are configured
+	restartLimit  *restartLimiter  // nil when automatic restarts aren't rate limited
+	redis         StatusStore
+	logger        *Logger
+
+	synthetic map[string]*syntheticStatus // statuses forced by inject-status, by process name
 }
 
 // NewProcessMonitor creates a new process monitor
-func NewProcessMonitor(processes []Process, redis *RedisClient, logger *Logger) *ProcessMonitor {
+func NewProcessMonitor(processes []Process, config MonitoringConfig, redis StatusStore, metrics *MetricsExporter, logger *Logger) *ProcessMonitor {
+	if config.UptimeWindow.Duration <= 0 {
+		config.UptimeWindow.Duration = defaultUptimeWindow
+	}
+	if config.RestartStablePeriod.Duration <= 0 {
+		config.RestartStablePeriod.Duration = defaultRestartStablePeriod
+	}
+	if config.FlapThreshold <= 0 {
+		config.FlapThreshold = defaultFlapThreshold
+	}
+	if config.FlapWindow.Duration <= 0 {
+		config.FlapWindow.Duration = defaultFlapWindow
+	}
+	if config.FlapCooldown.Duration <= 0 {
+		config.FlapCooldown.Duration = defaultFlapCooldown
+	}
+	if config.CheckConcurrency <= 0 {
+		config.CheckConcurrency = defaultCheckConcurrency
+	}
+	if config.LeakSamples <= 0 {
+		config.LeakSamples = defaultLeakSamples
+	}
+
 	return &ProcessMonitor{
-		processes: processes,
-		redis:     redis,
-		logger:    logger,
+		processes:     processes,
+		restartGroups: buildRestartGroups(processes),
+		config:        config,
+		runtime:       make(map[string]*ProcessRuntimeState),
+		procLocks:     make(map[string]*sync.Mutex),
+		childExits:    make(map[string]int),
+		outputs:       make(map[string]*outputRing),
+		launchedAt:    make(map[string]time.Time),
+		cpuSamples:    make(map[string]cpuSample),
+		synthetic:     make(map[string]*syntheticStatus),
+		prober:        newProber(resolveProbeBackend(config.ProbeBackend, logger), config.ProbeTimeout.Duration),
+		docker:        NewDockerClient(config.DockerSocket),
+		startedAt:     time.Now(),
+		metrics:       metrics,
+		restartLimit:  newRestartLimiter(config.MaxRestartsPerMinute),
+		redis:         redis,
+		logger:        logger,
 	}
 }
 
-// getProcessPID gets the PID of a running process, returns 0 if not running
-func (pm *ProcessMonitor) getProcessPID(processName string) (int, error) {
-	cmd := exec.Command("pgrep", "-f", processName)
-	output, err := cmd.Output()
-	if err != nil {
-		return 0, nil // Process not running
+// lockProcess serializes status updates for a process, returning the unlock function
+func (pm *ProcessMonitor) lockProcess(name string) func() {
+	pm.mu.Lock()
+	lock, ok := pm.procLocks[name]
+	if !ok {
+		lock = &sync.Mutex{}
+		pm.procLocks[name] = lock
+	}
+	pm.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// buildRestartGroups indexes processes by restart group, keeping config order
+func buildRestartGroups(processes []Process) map[string][]Process {
+	restartGroups := make(map[string][]Process)
+	for _, proc := range processes {
+		if proc.RestartGroup != "" {
+			restartGroups[proc.RestartGroup] = append(restartGroups[proc.RestartGroup], proc)
+		}
+	}
+	return restartGroups
+}
+
+// Processes returns a snapshot of the monitored processes
+func (pm *ProcessMonitor) Processes() []Process {
+	pm.procMu.RLock()
+	defer pm.procMu.RUnlock()
+	return pm.processes
+}
+
+// ReloadProcesses swaps in a new process list. Added processes are picked up by the next
+// check; removed ones stop being monitored and, if clearKeys is set, have their Redis keys
+// and runtime state deleted. It returns the names of the added and removed processes.
+func (pm *ProcessMonitor) ReloadProcesses(ctx context.Context, processes []Process, clearKeys bool) (added, removed []string) {
+	pm.procMu.Lock()
+	old := pm.processes
+	pm.processes = processes
+	pm.restartGroups = buildRestartGroups(processes)
+	pm.procMu.Unlock()
+
+	oldNames := make(map[string]bool, len(old))
+	for _, proc := range old {
+		oldNames[proc.Name] = true
+	}
+	for _, proc := range processes {
+		if !oldNames[proc.Name] {
+			added = append(added, proc.Name)
+		}
+		delete(oldNames, proc.Name)
+	}
+	for _, proc := range old {
+		if oldNames[proc.Name] {
+			removed = append(removed, proc.Name)
+		}
+	}
+
+	for _, name := range removed {
+		pm.metrics.ForgetProcess(name)
+		if !clearKeys {
+			continue
+		}
+
+		unlock := pm.lockProcess(name)
+		pm.mu.Lock()
+		delete(pm.runtime, name)
+		delete(pm.childExits, name)
+		delete(pm.outputs, name)
+		delete(pm.cpuSamples, name)
+		pm.mu.Unlock()
+		if err := pm.redis.DeleteProcessKeys(ctx, name); err != nil {
+			pm.logger.Error("Error clearing Redis keys for removed process %s: %v", name, err)
+		}
+		unlock()
+	}
+
+	return added, removed
+}
+
+// restartGroupMembers returns the processes that must restart together with proc,
+// in the order they are configured. A process without a group restarts alone.
+func (pm *ProcessMonitor) restartGroupMembers(proc Process) []Process {
+	if proc.RestartGroup == "" {
+		return []Process{proc}
+	}
+
+	pm.procMu.RLock()
+	defer pm.procMu.RUnlock()
+	return pm.restartGroups[proc.RestartGroup]
+}
+
+// alertsArmed reports whether the startup delay for a process has elapsed
+func (pm *ProcessMonitor) alertsArmed(proc Process) bool {
+	delay := pm.config.StartupDelay.Duration
+	if proc.StartupDelay != nil {
+		delay = proc.StartupDelay.Duration
 	}
+	return time.Since(pm.startedAt) >= delay
+}
 
-	// Get first PID if multiple instances are running
-	pids := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(pids) == 0 {
-		return 0, nil
+// inStartupGrace reports whether a process is within its startup grace, during which a
+// missing PID is not treated as down. The grace runs from hostd's own start and again
+// from every time hostd starts the process, so a restarted process gets it too.
+func (pm *ProcessMonitor) inStartupGrace(proc Process) bool {
+	since := pm.startedAt
+	pm.mu.Lock()
+	if launched := pm.launchedAt[proc.Name]; launched.After(since) {
+		since = launched
 	}
+	pm.mu.Unlock()
+	return time.Since(since) < proc.StartupGrace.Duration
+}
 
-	pid, err := strconv.Atoi(pids[0])
+// recordLaunch notes that hostd has just started a process, opening its startup grace
+func (pm *ProcessMonitor) recordLaunch(name string) {
+	pm.mu.Lock()
+	pm.launchedAt[name] = time.Now()
+	pm.mu.Unlock()
+}
+
+// cpuSample is the CPU time of each instance of a process at one check
+type cpuSample struct {
+	at    time.Time
+	times map[int]time.Duration
+}
+
+// cpuUsage returns the CPU usage of a process's instances since the previous check, as
+// a percentage of one core: the CPU time they used over the wall time that passed. Only
+// instances seen at both checks count, so a new or restarted PID adds nothing until the
+// check after it appears. ok is false when there is no earlier sample to compare with,
+// such as on the first check of a process.
+func (pm *ProcessMonitor) cpuUsage(name string, pids []int) (cpu float64, ok bool, err error) {
+	times, err := pm.prober.CPUTimes(pids)
 	if err != nil {
-		return 0, fmt.Errorf("invalid PID format: %v", err)
+		return 0, false, err
 	}
+	sample := cpuSample{at: time.Now(), times: times}
+
+	pm.mu.Lock()
+	previous, found := pm.cpuSamples[name]
+	pm.cpuSamples[name] = sample
+	pm.mu.Unlock()
 
-	return pid, nil
+	wall := sample.at.Sub(previous.at)
+	if !found || wall <= 0 {
+		return 0, false, nil
+	}
+	var used time.Duration
+	for pid, t := range times {
+		before, seen := previous.times[pid]
+		if !seen || t < before {
+			continue // new instance, or a reused PID
+		}
+		used += t - before
+		ok = true
+	}
+	if !ok {
+		return 0, false, nil
+	}
+	return float64(used) / float64(wall) * 100, true, nil
+}
+
+// forgetCPU drops the CPU sample of a process that is down, so its usage is measured
+// afresh once it runs again
+func (pm *ProcessMonitor) forgetCPU(name string) {
+	pm.mu.Lock()
+	delete(pm.cpuSamples, name)
+	pm.mu.Unlock()
+}
+
+// alert logs an alert for a process at the given level, downgrading it to Info
+// while the process is still within its startup delay
+func (pm *ProcessMonitor) alert(proc Process, level func(format string, v ...interface{}), format string, v ...interface{}) {
+	if !pm.alertsArmed(proc) {
+		pm.logger.Info("[suppressed during startup delay] "+format, v...)
+		return
+	}
+	level(format, v...)
+}
+
+// verifyProcessStarted polls for the PID of a just-started process, returning it as
+// soon as it appears. It gives up once the configured attempts are exhausted and the
+// process's startup grace has passed, so a start is only reported failed once status
+// checks would report the process down too.
+func (pm *ProcessMonitor) verifyProcessStarted(ctx context.Context, proc Process) (int, error) {
+	attempts := proc.StartVerifyAttempts
+	if attempts <= 0 {
+		attempts = defaultStartVerifyAttempts
+	}
+	interval := proc.StartVerifyInterval.Duration
+	if interval <= 0 {
+		interval = defaultStartVerifyInterval
+	}
+
+	attempt := 1
+	for ; ; attempt++ {
+		pid, err := pm.getProcessPID(proc)
+		if err != nil {
+			return 0, err
+		}
+		if pid > 0 {
+			return pid, nil
+		}
+		if attempt >= attempts && !pm.inStartupGrace(proc) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+
+	return 0, fmt.Errorf("process %s not running after %d checks %v apart", proc.Name, attempt, interval)
+}
+
+// shouldRestartOnExit applies the process's RestartOn policy to the exit code of a
+// child hostd started, matching systemd's Restart= semantics
+func shouldRestartOnExit(proc Process, exitCode int) bool {
+	policy := proc.RestartOn
+	if policy == "" {
+		policy = defaultRestartOnPolicy
+	}
+
+	switch policy {
+	case RestartOnAlways:
+		return true
+	case RestartOnNever:
+		return false
+	default:
+		return exitCode != 0
+	}
+}
+
+// isSecretEnvKey reports whether an environment variable likely holds a secret
+func isSecretEnvKey(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, marker := range []string{"PASSWORD", "PASSWD", "SECRET", "TOKEN", "KEY", "CREDENTIAL"} {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
 }
 
-// getProcessMemory gets the current memory usage of a process in bytes
-func (pm *ProcessMonitor) getProcessMemory(pid int) (int64, error) {
-	cmd := exec.Command("ps", "-o", "rss=", "-p", strconv.Itoa(pid))
-	output, err := cmd.Output()
+// checkEnvDrift compares a process's environment against the configured expected
+// environment and returns a description of every mismatched key
+func (pm *ProcessMonitor) checkEnvDrift(pid int, proc Process) ([]string, error) {
+	env, err := pm.prober.Env(pid)
 	if err != nil {
-		return 0, fmt.Errorf("error getting memory usage: %v", err)
+		return nil, err
+	}
+
+	keys := proc.EnvKeys
+	if len(keys) == 0 {
+		for key := range proc.ExpectedEnv {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+
+	var drift []string
+	for _, key := range keys {
+		expected := proc.ExpectedEnv[key]
+		actual, ok := env[key]
+		if ok && actual == expected {
+			continue
+		}
+
+		if !ok {
+			actual = "<unset>"
+		} else if isSecretEnvKey(key) {
+			actual = "<redacted>"
+		}
+		if isSecretEnvKey(key) {
+			expected = "<redacted>"
+		}
+		drift = append(drift, fmt.Sprintf("%s: expected=%s actual=%s", key, expected, actual))
 	}
 
-	// Convert KB to bytes (ps outputs in KB)
-	memKB, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	return drift, nil
+}
+
+// markUnknown stores the status of a process as unknown for this check after one of its
+// probes timed out, keeping everything else from the previous check. Nothing is
+// restarted, since a hung probe says nothing about whether the process is running, and
+// the next check probes again.
+func (pm *ProcessMonitor) markUnknown(ctx context.Context, proc Process, probeErr error) {
+	pm.logger.Info("Probe of process %s timed out, status unknown for this check: %v", proc.Name, probeErr)
+
+	status, err := pm.getProcStatus(ctx, proc.Name)
 	if err != nil {
-		return 0, fmt.Errorf("error parsing memory value: %v", err)
+		pm.logger.Error("Error getting current status for process %s: %v", proc.Name, err)
+		return
 	}
+	previous := status.Status
+	status.SchemaVersion = processStatusSchemaVersion
+	status.Status = "unknown"
+	status.Labels = proc.Labels
 
-	return memKB * 1024, nil // Convert KB to bytes
+	if err := pm.storeStatus(ctx, status); err != nil {
+		pm.logger.Error("Error storing status of process %s: %v", proc.Name, err)
+		return
+	}
+	if previous != status.Status {
+		pm.publishTransition(ctx, proc, previous, status.Status, status.CurrentPID, false)
+	}
 }
 
 // getProcStatus gets the current status from Redis
 func (pm *ProcessMonitor) getProcStatus(ctx context.Context, processName string) (*ProcessStatus, error) {
-	data, err := pm.redis.GetProcessStatus(ctx, processName)
+	data, err := pm.redis.GetProcessStatusFresh(ctx, processName)
 	if err != nil {
 		return &ProcessStatus{
 			Name:       processName,
@@ -101,97 +486,346 @@ func (pm *ProcessMonitor) getProcStatus(ctx context.Context, processName string)
 		}, nil
 	}
 
+	return pm.decodeProcStatus(processName, data)
+}
+
+// decodeProcStatus parses a stored status of any schema version. Redis keys outlive
+// daemon upgrades, so a record may come from an older or newer hostd. Fields whose type
+// changed are left at their zero value instead of failing the check, and the record is
+// rewritten in the current schema on the next update.
+func (pm *ProcessMonitor) decodeProcStatus(processName, data string) (*ProcessStatus, error) {
 	var status ProcessStatus
 	if err := json.Unmarshal([]byte(data), &status); err != nil {
-		return nil, fmt.Errorf("error parsing status: %v", err)
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("error parsing status: %v", err)
+		}
+		// Unmarshal still decodes the other fields after a type mismatch
+		pm.logger.Info("Status of process %s (schema version %d) has field %s of an unexpected type, resetting it",
+			processName, status.SchemaVersion, typeErr.Field)
 	}
 
+	if status.SchemaVersion > processStatusSchemaVersion {
+		pm.logger.Debug("Status of process %s has newer schema version %d, keeping known fields",
+			processName, status.SchemaVersion)
+	}
+	status.SchemaVersion = processStatusSchemaVersion
 	return &status, nil
 }
 
-// updateProcStatus checks process status and updates Redis
+// updateProcStatus checks process status, updates Redis and restarts the process
+// if it crashed and its restart policy allows it
 func (pm *ProcessMonitor) updateProcStatus(ctx context.Context, proc Process) {
-	currentPID, err := pm.getProcessPID(proc.Name)
+	if pm.refreshProcStatus(ctx, proc) {
+		pm.autoRestart(ctx, proc)
+	}
+}
+
+// UpdateAll checks every monitored process, running up to checkConcurrency checks at
+// once so a slow probe doesn't hold up the others. Members of a restart group are
+// checked in order by the same worker, so a crash restarts the group only once.
+func (pm *ProcessMonitor) UpdateAll(ctx context.Context) {
+	var batches [][]Process
+	groups := make(map[string]int) // restart group -> index in batches
+	for _, proc := range pm.Processes() {
+		if proc.RestartGroup == "" {
+			batches = append(batches, []Process{proc})
+			continue
+		}
+		if i, ok := groups[proc.RestartGroup]; ok {
+			batches[i] = append(batches[i], proc)
+			continue
+		}
+		groups[proc.RestartGroup] = len(batches)
+		batches = append(batches, []Process{proc})
+	}
+
+	sem := make(chan struct{}, pm.config.CheckConcurrency)
+	var wg sync.WaitGroup
+	for _, batch := range batches {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(batch []Process) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			for _, proc := range batch {
+				pm.updateProcStatus(ctx, proc)
+			}
+		}(batch)
+	}
+	wg.Wait()
+}
+
+// refreshProcStatus checks process status and updates Redis, returning whether an
+// automatic restart is due
+func (pm *ProcessMonitor) refreshProcStatus(ctx context.Context, proc Process) bool {
+	unlock := pm.lockProcess(proc.Name)
+	defer unlock()
+	logger := pm.logger.WithFields(map[string]interface{}{"process": proc.Name})
+
+	if pm.syntheticActive(proc.Name) {
+		logger.Debug("Process %s has a synthetic status, skipping its check", proc.Name)
+		return false
+	}
+
+	var pids []int
+	var containerState string
+	var err error
+	if proc.Container != "" {
+		var pid int
+		pid, containerState, err = pm.docker.containerState(ctx, proc.Container)
+		if pid > 0 {
+			pids = []int{pid}
+		}
+	} else {
+		pids, err = pm.prober.PIDs(proc)
+	}
+	if errors.Is(err, errProbeTimeout) {
+		pm.markUnknown(ctx, proc, err)
+		return false
+	}
 	if err != nil {
-		pm.logger.Error("Error getting PID for process %s: %v", proc.Name, err)
-		return
+		logger.Error("Error getting PID for process %s: %v", proc.Name, err)
+		return false
+	}
+
+	// The process is up while any instance remains
+	var currentPID int
+	if len(pids) > 0 {
+		currentPID = pids[0]
+		logger = logger.WithFields(map[string]interface{}{"pid": currentPID})
+	}
+	if currentPID == 0 && pm.inStartupGrace(proc) {
+		logger.Debug("Process %s not running yet, within its startup grace of %v", proc.Name, proc.StartupGrace.Duration)
+		return false
 	}
 
 	// Get current status from Redis
 	currentStatus, err := pm.getProcStatus(ctx, proc.Name)
 	if err != nil {
-		pm.logger.Error("Error getting current status for process %s: %v", proc.Name, err)
-		return
+		logger.Error("Error getting current status for process %s: %v", proc.Name, err)
+		return false
 	}
 
 	// Determine if status has changed
 	status := "down"
 	var currentMemory int64 = 0
+	var currentCPU float64
+	cpuSampled := false
 
 	if currentPID > 0 {
 		status = "up"
 		// Get memory usage if process is running
-		mem, err := pm.getProcessMemory(currentPID)
+		var mem int64
+		switch {
+		case proc.Container != "":
+			mem, err = pm.getCgroupMemory(currentPID)
+		case proc.Cgroup != "":
+			mem, err = cgroupMemory(proc.Cgroup)
+		default:
+			mem, err = pm.prober.Memory(pids)
+		}
+		if errors.Is(err, errProbeTimeout) {
+			pm.markUnknown(ctx, proc, err)
+			return false
+		}
 		if err != nil {
-			pm.logger.Error("Error getting memory usage for process %s: %v", proc.Name, err)
+			logger.Error("Error getting memory usage for process %s: %v", proc.Name, err)
 		} else {
 			currentMemory = mem
 		}
+
+		cpu, ok, err := pm.cpuUsage(proc.Name, pids)
+		if errors.Is(err, errProbeTimeout) {
+			pm.markUnknown(ctx, proc, err)
+			return false
+		}
+		if err != nil {
+			logger.Error("Error getting CPU usage for process %s: %v", proc.Name, err)
+		} else if ok {
+			currentCPU = cpu
+			cpuSampled = true
+		}
+	} else {
+		pm.forgetCPU(proc.Name)
+	}
+
+	// A running process whose health check keeps failing is unhealthy rather than up
+	var health *HealthResult
+	if currentPID > 0 && proc.HealthCheck != nil {
+		health = pm.checkHealth(ctx, proc, currentStatus.Health, currentPID != currentStatus.CurrentPID)
+		if proc.HealthCheck.unhealthy(health) {
+			status = "unhealthy"
+		}
+		if status != currentStatus.Status {
+			if status == "unhealthy" {
+				pm.alert(proc, logger.Error, "Process %s is unhealthy after %d failed health checks: %s",
+					proc.Name, health.ConsecutiveFailures, health.Error)
+			} else if currentStatus.Status == "unhealthy" {
+				logger.Info("Process %s is healthy again", proc.Name)
+			}
+		}
 	}
 
 	newStatus := &ProcessStatus{
-		Name:          proc.Name,
-		CurrentPID:    currentPID,
-		Status:        status,
-		LastChange:    currentStatus.LastChange,
-		MemoryStats:   currentStatus.MemoryStats,
-		CurrentMemory: currentMemory,
+		SchemaVersion:  processStatusSchemaVersion,
+		Name:           proc.Name,
+		CurrentPID:     currentPID,
+		PIDs:           pids,
+		InstanceCount:  len(pids),
+		Status:         status,
+		LastChange:     currentStatus.LastChange,
+		MemoryStats:    currentStatus.MemoryStats,
+		CPUStats:       currentStatus.CPUStats,
+		CurrentCPU:     currentCPU,
+		CurrentMemory:  currentMemory,
+		ContainerState: containerState,
+		Health:         health,
+		Labels:         proc.Labels,
+	}
+
+	// The start time only needs probing for a new PID
+	if currentPID > 0 {
+		newStatus.StartTime = currentStatus.StartTime
+		if currentPID != currentStatus.CurrentPID || newStatus.StartTime.IsZero() {
+			started, err := pm.prober.StartTime(currentPID)
+			if err != nil {
+				logger.Error("Error getting start time for process %s: %v", proc.Name, err)
+				started = time.Time{}
+			}
+			newStatus.StartTime = started
+		}
+		if !newStatus.StartTime.IsZero() {
+			newStatus.Uptime = int64(time.Since(newStatus.StartTime).Seconds())
+		}
 	}
 
 	// Update status if PID has changed
 	if currentPID != currentStatus.CurrentPID {
 		if currentStatus.CurrentPID > 0 && currentPID == 0 {
-			pm.logger.Critical("Process %s has stopped (previous PID: %d)", proc.Name, currentStatus.CurrentPID)
+			if output := pm.OutputTail(proc.Name, crashOutputLines); len(output) > 0 {
+				pm.alert(proc, logger.Critical, "Process %s has stopped (previous PID: %d), last output:\n%s",
+					proc.Name, currentStatus.CurrentPID, strings.Join(output, "\n"))
+			} else {
+				pm.alert(proc, logger.Critical, "Process %s has stopped (previous PID: %d)", proc.Name, currentStatus.CurrentPID)
+			}
 		} else if currentStatus.CurrentPID == 0 && currentPID > 0 {
-			pm.logger.Info("Process %s has started (PID: %d)", proc.Name, currentPID)
+			logger.Info("Process %s has started (PID: %d)", proc.Name, currentPID)
 		} else {
-			pm.logger.Info("Process %s PID changed: %d -> %d", proc.Name, currentStatus.CurrentPID, currentPID)
+			logger.Info("Process %s PID changed: %d -> %d", proc.Name, currentStatus.CurrentPID, currentPID)
 		}
 		newStatus.PreviousPID = &currentStatus.CurrentPID
 		newStatus.LastChange = time.Now()
 	} else {
 		newStatus.PreviousPID = currentStatus.PreviousPID
 	}
+	if currentPID > 0 && currentStatus.InstanceCount > 0 && len(pids) != currentStatus.InstanceCount {
+		logger.Info("Process %s instance count changed: %d -> %d", proc.Name, currentStatus.InstanceCount, len(pids))
+	}
+
+	if currentPID > 0 {
+		fds, err := pm.prober.OpenFDs(currentPID)
+		if err != nil {
+			logger.Error("Error getting open file descriptors for process %s: %v", proc.Name, err)
+			fds = currentStatus.OpenFDs
+		}
+		newStatus.OpenFDs = fds
+		pm.checkLimits(ctx, proc, currentStatus, newStatus, cpuSampled)
+		pm.checkMemoryTrend(ctx, proc, newStatus, currentPID != currentStatus.CurrentPID)
+	}
+
+	// An unhealthy process is restarted like a crashed one, as is a leaky one if configured
+	wasUp := currentStatus.CurrentPID > 0 && currentStatus.Status != "unhealthy"
+	up := status == "up" && !(newStatus.MemoryAlert && proc.RestartOnMemoryLimit)
+	restartDue := pm.decideRestart(ctx, proc, wasUp, up)
+	state := pm.runtimeState(ctx, proc.Name)
+	newStatus.RestartCount = state.RestartCount
+	newStatus.Flapping = !state.FlappingSince.IsZero()
+
+	// Check the thread count against the configured range
+	if currentPID > 0 {
+		threads, err := pm.prober.Threads(currentPID)
+		if err != nil {
+			logger.Error("Error getting thread count for process %s: %v", proc.Name, err)
+		} else {
+			newStatus.Threads = threads
+			newStatus.ThreadsAlert = (proc.MinThreads > 0 && threads < proc.MinThreads) ||
+				(proc.MaxThreads > 0 && threads > proc.MaxThreads)
+			if newStatus.ThreadsAlert && !currentStatus.ThreadsAlert {
+				pm.alert(proc, logger.Error, "Process %s thread count %d outside expected range (min: %d, max: %d)",
+					proc.Name, threads, proc.MinThreads, proc.MaxThreads)
+			} else if !newStatus.ThreadsAlert && currentStatus.ThreadsAlert {
+				logger.Info("Process %s thread count %d back within expected range", proc.Name, threads)
+			}
+		}
+	}
+
+	// Check whether the running process has picked up the expected environment
+	if currentPID > 0 && len(proc.ExpectedEnv) > 0 {
+		drift, err := pm.checkEnvDrift(currentPID, proc)
+		if err != nil {
+			logger.Error("Error checking environment for process %s: %v", proc.Name, err)
+		} else if len(drift) > 0 {
+			newStatus.EnvDrift = true
+			newStatus.DriftedEnv = drift
+			if !currentStatus.EnvDrift || currentPID != currentStatus.CurrentPID {
+				pm.alert(proc, logger.Error, "Process %s is running with a stale environment, restart needed to pick up changes: %s",
+					proc.Name, strings.Join(drift, "; "))
+			}
+		}
+	}
+
+	newStatus.UptimeRatio = pm.updateUptime(ctx, proc, currentPID > 0)
 
 	// Update memory stats if process is running
 	if currentMemory > 0 {
 		now := time.Now()
-		
+
 		// Initialize memory stats if needed
 		if newStatus.MemoryStats.MinMemory == 0 || currentMemory < newStatus.MemoryStats.MinMemory {
 			newStatus.MemoryStats.MinMemory = currentMemory
 			newStatus.MemoryStats.MinTimestamp = now
-			pm.logger.Info("New minimum memory for process %s: %.2f MB", proc.Name, float64(currentMemory)/(1024*1024))
+			logger.Info("New minimum memory for process %s: %.2f MB", proc.Name, float64(currentMemory)/(1024*1024))
 		}
 		if currentMemory > newStatus.MemoryStats.MaxMemory {
 			newStatus.MemoryStats.MaxMemory = currentMemory
 			newStatus.MemoryStats.MaxTimestamp = now
-			pm.logger.Info("New maximum memory for process %s: %.2f MB", proc.Name, float64(currentMemory)/(1024*1024))
+			logger.Info("New maximum memory for process %s: %.2f MB", proc.Name, float64(currentMemory)/(1024*1024))
+		}
+	}
+
+	// Update CPU stats only from real samples; a process that is down has no CPU usage to record
+	if cpuSampled {
+		now := time.Now()
+
+		if newStatus.CPUStats.MinTimestamp.IsZero() || currentCPU < newStatus.CPUStats.MinCPU {
+			newStatus.CPUStats.MinCPU = currentCPU
+			newStatus.CPUStats.MinTimestamp = now
+		}
+		if newStatus.CPUStats.MaxTimestamp.IsZero() || currentCPU > newStatus.CPUStats.MaxCPU {
+			newStatus.CPUStats.MaxCPU = currentCPU
+			newStatus.CPUStats.MaxTimestamp = now
 		}
 	}
 
 	// Convert to JSON and update Redis
 	statusJSON, err := json.Marshal(newStatus)
 	if err != nil {
-		pm.logger.Error("Error marshaling status for process %s: %v", proc.Name, err)
-		return
+		logger.Error("Error marshaling status for process %s: %v", proc.Name, err)
+		return false
 	}
 
 	if err := pm.redis.UpdateProcessStatus(ctx, proc.Name, string(statusJSON)); err != nil {
-		pm.logger.Error("Error updating Redis for process %s: %v", proc.Name, err)
-		return
+		logger.Error("Error updating Redis for process %s: %v", proc.Name, err)
+		return false
+	}
+	pm.metrics.ObserveProcess(newStatus)
+
+	if currentStatus.Status != status {
+		pm.publishTransition(ctx, proc, currentStatus.Status, status, currentPID, false)
 	}
 
-	pm.logger.Info("Process %s status: %s (PID: %d, Memory: %.2f MB)", 
-		proc.Name, status, currentPID, float64(currentMemory)/(1024*1024))
+	logger.Debug("Process %s status: %s (PID: %d, Instances: %d, Memory: %.2f MB, CPU: %.1f%%, Threads: %d)",
+		proc.Name, status, currentPID, len(pids), float64(currentMemory)/(1024*1024), currentCPU, newStatus.Threads)
+	return restartDue
 }
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// storedStatus returns the status of a process as written to the store
+func storedStatus(t *testing.T, store *MemoryStore, name string) ProcessStatus {
+	t.Helper()
+	data, err := store.Get("process:" + name + ":status")
+	if err != nil {
+		t.Fatalf("no status stored for %s: %v", name, err)
+	}
+	var status ProcessStatus
+	if err := json.Unmarshal([]byte(data), &status); err != nil {
+		t.Fatalf("stored status %q: %v", data, err)
+	}
+	return status
+}
+
+// transitions returns the status transitions published to the event stream, as old->new
+func transitions(store *MemoryStore) []string {
+	var result []string
+	for _, event := range store.Events() {
+		if newStatus, ok := event["new_status"]; ok {
+			result = append(result, event["old_status"].(string)+"->"+newStatus.(string))
+		}
+	}
+	return result
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRefreshProcStatusUpToDown(t *testing.T) {
+	ctx := context.Background()
+	proc := Process{Name: "api"}
+	pm, store, prober := newTestMonitor([]Process{proc}, MonitoringConfig{})
+
+	prober.set("api", 100)
+	pm.refreshProcStatus(ctx, proc)
+	if status := storedStatus(t, store, "api"); status.Status != "up" || status.CurrentPID != 100 {
+		t.Fatalf("after the first check status %s PID %d, want up with PID 100", status.Status, status.CurrentPID)
+	}
+	up := storedStatus(t, store, "api")
+
+	prober.set("api")
+	pm.refreshProcStatus(ctx, proc)
+	status := storedStatus(t, store, "api")
+	if status.Status != "down" || status.CurrentPID != 0 {
+		t.Errorf("status %s PID %d, want down with no PID", status.Status, status.CurrentPID)
+	}
+	if status.PreviousPID == nil || *status.PreviousPID != 100 {
+		t.Errorf("previous PID %v, want 100", status.PreviousPID)
+	}
+	if !status.LastChange.After(up.LastChange) {
+		t.Errorf("last change %v not after the start at %v", status.LastChange, up.LastChange)
+	}
+	if status.CurrentMemory != 0 || status.CurrentCPU != 0 {
+		t.Errorf("memory %d and CPU %.1f while down, want 0", status.CurrentMemory, status.CurrentCPU)
+	}
+	if got, want := transitions(store), []string{"unknown->up", "up->down"}; !equalStrings(got, want) {
+		t.Errorf("transitions %v, want %v", got, want)
+	}
+}
+
+func TestRefreshProcStatusDownToUp(t *testing.T) {
+	ctx := context.Background()
+	proc := Process{Name: "api"}
+	pm, store, prober := newTestMonitor([]Process{proc}, MonitoringConfig{})
+
+	pm.refreshProcStatus(ctx, proc)
+	if status := storedStatus(t, store, "api"); status.Status != "down" {
+		t.Fatalf("status %s with no instances, want down", status.Status)
+	}
+
+	prober.set("api", 200, 201)
+	pm.refreshProcStatus(ctx, proc)
+	status := storedStatus(t, store, "api")
+	if status.Status != "up" || status.CurrentPID != 200 || status.InstanceCount != 2 {
+		t.Errorf("status %s PID %d instances %d, want up with PID 200 and 2 instances",
+			status.Status, status.CurrentPID, status.InstanceCount)
+	}
+	if status.CurrentMemory != 2<<20 {
+		t.Errorf("memory %d, want both instances summed to %d", status.CurrentMemory, 2<<20)
+	}
+	if !status.StartTime.Equal(fakeStartTime) {
+		t.Errorf("start time %v, want %v", status.StartTime, fakeStartTime)
+	}
+	if got, want := transitions(store), []string{"unknown->down", "down->up"}; !equalStrings(got, want) {
+		t.Errorf("transitions %v, want %v", got, want)
+	}
+}
+
+func TestRefreshProcStatusPIDChange(t *testing.T) {
+	ctx := context.Background()
+	proc := Process{Name: "api"}
+	pm, store, prober := newTestMonitor([]Process{proc}, MonitoringConfig{})
+
+	prober.set("api", 100)
+	pm.refreshProcStatus(ctx, proc)
+	before := storedStatus(t, store, "api")
+
+	// Restarted behind hostd's back between two checks
+	prober.set("api", 101)
+	pm.refreshProcStatus(ctx, proc)
+	status := storedStatus(t, store, "api")
+	if status.Status != "up" || status.CurrentPID != 101 {
+		t.Errorf("status %s PID %d, want up with PID 101", status.Status, status.CurrentPID)
+	}
+	if status.PreviousPID == nil || *status.PreviousPID != 100 {
+		t.Errorf("previous PID %v, want 100", status.PreviousPID)
+	}
+	if !status.LastChange.After(before.LastChange) {
+		t.Errorf("last change %v not after %v", status.LastChange, before.LastChange)
+	}
+
+	// The status stayed up, so the only transition is the first check's
+	if got, want := transitions(store), []string{"unknown->up"}; !equalStrings(got, want) {
+		t.Errorf("transitions %v, want %v", got, want)
+	}
+}
+
+func TestRefreshProcStatusProbeTimeoutMarksUnknown(t *testing.T) {
+	ctx := context.Background()
+	proc := Process{Name: "api", Restart: true}
+	pm, store, prober := newTestMonitor([]Process{proc}, MonitoringConfig{})
+
+	prober.set("api", 100)
+	pm.refreshProcStatus(ctx, proc)
+
+	prober.fail("api", fmt.Errorf("%w: pgrep did not finish within 10ms", errProbeTimeout))
+	if pm.refreshProcStatus(ctx, proc) {
+		t.Error("a timed-out probe made a restart due")
+	}
+	status := storedStatus(t, store, "api")
+	if status.Status != "unknown" || status.CurrentPID != 100 {
+		t.Errorf("status %s PID %d, want unknown with the last known PID 100", status.Status, status.CurrentPID)
+	}
+
+	// The next check probes again and finds the process still up, without restarting it
+	prober.set("api", 100)
+	if pm.refreshProcStatus(ctx, proc) {
+		t.Error("the check after a timed-out probe made a restart due")
+	}
+	if status := storedStatus(t, store, "api"); status.Status != "up" {
+		t.Errorf("status %s after a successful check, want up", status.Status)
+	}
+	if got, want := transitions(store), []string{"unknown->up", "up->unknown", "unknown->up"}; !equalStrings(got, want) {
+		t.Errorf("transitions %v, want %v", got, want)
+	}
+}
+
+func TestUpdateAllSlowProbeDoesNotHoldUpOthers(t *testing.T) {
+	ctx := context.Background()
+	processes := []Process{{Name: "slow"}, {Name: "a"}, {Name: "b"}, {Name: "c"}}
+	pm, store, prober := newTestMonitor(processes, MonitoringConfig{CheckConcurrency: 2})
+	for i, proc := range processes {
+		prober.set(proc.Name, 100+i)
+	}
+	release := prober.block("slow")
+	defer release()
+
+	done := make(chan struct{})
+	go func() {
+		pm.UpdateAll(ctx)
+		close(done)
+	}()
+
+	// With one worker stuck on the slow probe, the other works through the rest
+	deadline := time.Now().Add(2 * time.Second)
+	for _, name := range []string{"a", "b", "c"} {
+		for {
+			if _, err := store.Get("process:" + name + ":status"); err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("status of %s not stored while the slow probe was blocked", name)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	select {
+	case <-done:
+		t.Fatal("UpdateAll returned before the slow probe finished")
+	default:
+	}
+	if _, err := store.Get("process:slow:status"); err == nil {
+		t.Error("status of the blocked process stored before its probe returned")
+	}
+
+	release()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("UpdateAll did not return once the slow probe finished")
+	}
+	if status := storedStatus(t, store, "slow"); status.Status != "up" {
+		t.Errorf("slow status %s, want up", status.Status)
+	}
+}
+
+func TestUpdateAllChecksRestartGroupInOrder(t *testing.T) {
+	ctx := context.Background()
+	processes := []Process{
+		{Name: "db", RestartGroup: "app"},
+		{Name: "api", RestartGroup: "app"},
+		{Name: "cache"},
+	}
+	pm, store, prober := newTestMonitor(processes, MonitoringConfig{CheckConcurrency: 3})
+	for i, proc := range processes {
+		prober.set(proc.Name, 100+i)
+	}
+	release := prober.block("db")
+	defer release()
+
+	done := make(chan struct{})
+	go func() {
+		pm.UpdateAll(ctx)
+		close(done)
+	}()
+
+	// The ungrouped process goes ahead, the group's second member waits for its first
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := store.Get("process:cache:status"); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("status of cache not stored while db was blocked")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if prober.calls("api") != 0 {
+		t.Error("api checked before db, the member ahead of it in its restart group")
+	}
+
+	release()
+	<-done
+	if prober.calls("api") != 1 {
+		t.Errorf("api checked %d times, want once", prober.calls("api"))
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Process probe backends
+const (
+	ProbeBackendAuto = "auto" // pgrep and ps if installed, /proc otherwise
+	ProbeBackendPS   = "ps"   // pgrep and ps, plus /proc for what they can't report
+	ProbeBackendProc = "proc" // /proc only, no external commands
+)
+
+// clockTicks is the kernel's USER_HZ, the unit of CPU times in /proc/<pid>/stat. It is
+// 100 on every mainstream Linux architecture and can't be queried without cgo.
+const clockTicks = 100
+
+// validProbeBackend reports whether backend is a known probe backend, empty meaning auto
+func validProbeBackend(backend string) bool {
+	switch backend {
+	case "", ProbeBackendAuto, ProbeBackendPS, ProbeBackendProc:
+		return true
+	}
+	return false
+}
+
+// resolveProbeBackend picks the backend to probe processes with. Auto prefers pgrep and
+// ps so existing setups keep their behavior, and uses /proc when either is missing. A
+// requested /proc backend falls back to ps where /proc isn't mounted.
+func resolveProbeBackend(backend string, logger *Logger) string {
+	switch backend {
+	case ProbeBackendPS:
+		return ProbeBackendPS
+	case ProbeBackendProc:
+		if !procMounted() {
+			logger.Error("Probe backend proc requested but /proc is not mounted, using pgrep and ps")
+			return ProbeBackendPS
+		}
+		return ProbeBackendProc
+	}
+
+	for _, tool := range []string{"pgrep", "ps"} {
+		if _, err := exec.LookPath(tool); err != nil && procMounted() {
+			logger.Info("%s not found, probing processes through /proc", tool)
+			return ProbeBackendProc
+		}
+	}
+	return ProbeBackendPS
+}
+
+// newProber creates the prober for a resolved backend, limiting each command it runs to timeout
+func newProber(backend string, timeout time.Duration) ProcessProber {
+	if backend == ProbeBackendProc {
+		return procProber{osProber{timeout: timeout}}
+	}
+	return osProber{timeout: timeout}
+}
+
+// procProber probes processes through /proc alone, for systems without pgrep and ps
+// such as minimal or musl-based images. Everything osProber already reads from /proc
+// is shared with it.
+type procProber struct {
+	osProber
+}
+
+// PIDs gets the PIDs of every running process matching proc, in ascending order. The
+// substring mode matches the name as a regular expression against the command line,
+// like pgrep -f.
+func (p procProber) PIDs(proc Process) ([]int, error) {
+	if proc.Cgroup != "" || (proc.MatchMode != "" && proc.MatchMode != MatchModeSubstring) {
+		return p.osProber.PIDs(proc)
+	}
+
+	pattern, err := regexp.Compile(proc.Name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid process pattern %q: %v", proc.Name, err)
+	}
+	return scanProcesses(func(pid int, argv []string) bool {
+		return pattern.MatchString(strings.Join(argv, " "))
+	})
+}
+
+// Memory gets the combined resident memory in bytes of a process's instances from
+// /proc/<pid>/statm. Instances that exited since they were found are skipped.
+func (procProber) Memory(pids []int) (int64, error) {
+	pageSize := int64(os.Getpagesize())
+
+	var total int64
+	for _, pid := range pids {
+		data, err := os.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error getting memory usage: %v", err)
+		}
+
+		fields := strings.Fields(string(data))
+		if len(fields) < 2 {
+			return 0, fmt.Errorf("error parsing memory value: unexpected statm %q", strings.TrimSpace(string(data)))
+		}
+		pages, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing memory value: %v", err)
+		}
+		total += pages * pageSize
+	}
+
+	return total, nil
+}
+
+// CPUTimes gets the CPU time used by each PID from utime and stime in /proc/<pid>/stat.
+// ps only reports CPU time averaged over the lifetime of a process, so both backends
+// read it from /proc and ProcessMonitor turns it into a rate between checks.
+func (osProber) CPUTimes(pids []int) (map[int]time.Duration, error) {
+	times := make(map[int]time.Duration, len(pids))
+	for _, pid := range pids {
+		stat, err := readProcStat(pid)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting CPU time: %v", err)
+		}
+		times[pid] = time.Duration(stat.cpuTicks) * time.Second / clockTicks
+	}
+	return times, nil
+}
+
+// StartTime gets the start time of a process from /proc, to the clock tick
+func (procProber) StartTime(pid int) (time.Time, error) {
+	boot, err := bootTime()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error getting start time: %v", err)
+	}
+	stat, err := readProcStat(pid)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error getting start time: %v", err)
+	}
+	return boot.Add(time.Duration(stat.startTicks) * time.Second / clockTicks), nil
+}
+
+// procStat holds the fields of /proc/<pid>/stat hostd uses, in clock ticks
+type procStat struct {
+	cpuTicks   int64 // user plus system time
+	startTicks int64 // start time after boot
+}
+
+// readProcStat parses /proc/<pid>/stat. The command name is in parentheses and may
+// contain spaces, so fields are counted from the last closing parenthesis.
+func readProcStat(pid int) (procStat, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return procStat{}, err
+	}
+
+	end := strings.LastIndexByte(string(data), ')')
+	if end < 0 {
+		return procStat{}, fmt.Errorf("unexpected /proc/%d/stat content", pid)
+	}
+	// Fields from the state on, so field N of proc(5) is at index N-3
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 20 {
+		return procStat{}, fmt.Errorf("unexpected /proc/%d/stat content", pid)
+	}
+
+	var values [3]int64
+	for i, index := range []int{11, 12, 19} { // utime, stime, starttime
+		if values[i], err = strconv.ParseInt(fields[index], 10, 64); err != nil {
+			return procStat{}, fmt.Errorf("error parsing /proc/%d/stat: %v", pid, err)
+		}
+	}
+	return procStat{cpuTicks: values[0] + values[1], startTicks: values[2]}, nil
+}
+
+// bootTime reads when the system booted from the btime line of /proc/stat
+func bootTime() (time.Time, error) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "btime "); ok {
+			secs, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("error parsing boot time: %v", err)
+			}
+			return time.Unix(secs, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no btime in /proc/stat")
+}
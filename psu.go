@@ -13,28 +13,48 @@ type PSUMetrics struct {
 	Current   float64 `json:"current"`
 	Power     float64 `json:"power"`
 	Timestamp string  `json:"timestamp"`
+
+	LastUpdated string `json:"last_updated"` // when the readings last changed
+	Stale       bool   `json:"stale"`        // readings unchanged for longer than the staleness thresholds allow
+
+	SchemaVersion int `json:"schema_version"` // hardwareMetricsSchemaVersion when stored
 }
 
 // PSU represents a Power Supply Unit
 type PSU struct {
-	name      string
-	logger    *Logger
-	redis     *RedisClient
-	voltage   float64
-	current   float64
-	power     float64
-	isPresent bool
-	instance  int
+	name         string
+	logger       *Logger
+	redis        StatusStore
+	metrics      *MetricsExporter // nil when the exporter is disabled
+	voltage      float64
+	current      float64
+	power        float64
+	isPresent    bool
+	presencePath string // file that exists while the FRU is present, empty to assume present
+	instance     int
+	thresholds   PSUThresholds
+	source       PSUSource // where voltage, current and power are read from
+	freshness    freshness
+	retry        readRetry
 }
 
-// NewPSU creates a new PSU instance
-func NewPSU(name string, instance int, logger *Logger, redis *RedisClient) *PSU {
+// PSU must keep satisfying HardwareInterface
+var _ HardwareInterface = (*PSU)(nil)
+
+// PSUSource reads a PSU's output voltage, current and power
+type PSUSource func(ctx context.Context) (PSUMetrics, error)
+
+// NewPSU creates a new PSU instance reading simulated values
+func NewPSU(name string, instance int, thresholds PSUThresholds, logger *Logger, redis StatusStore, metrics *MetricsExporter) *PSU {
 	return &PSU{
-		name:      name,
-		logger:    logger,
-		redis:     redis,
-		isPresent: true, // Initially assume PSU is present
-		instance:  instance,
+		name:       name,
+		logger:     logger.WithFields(map[string]interface{}{"fru": fmt.Sprintf("%s-%d", name, instance)}),
+		redis:      redis,
+		metrics:    metrics,
+		isPresent:  true, // Initially assume PSU is present
+		instance:   instance,
+		thresholds: thresholds,
+		source:     simulatedPSU,
 	}
 }
 
@@ -43,37 +63,55 @@ func (p *PSU) getName() string {
 }
 
 func (p *PSU) getStatus(ctx context.Context) (FruStatus, error) {
+	if p.presencePath != "" {
+		p.isPresent = fruPresent(p.presencePath)
+	}
 	if !p.isPresent {
 		return FruStatusRed, fmt.Errorf("PSU %d not present", p.instance)
 	}
 
-	if err := p.updateMetrics(ctx); err != nil {
+	if err := p.retry.read(ctx, p.redis, p.logger, "psu", p.instance, p.updateMetrics); err != nil {
 		return FruStatusRed, fmt.Errorf("failed to update PSU %d metrics", p.instance)
 	}
 
-	// Example thresholds - adjust based on actual requirements
-	if p.voltage < 10.8 || p.voltage > 13.2 { // ±10% of 12V
+	// Readings that stopped changing may come from a stuck sensor
+	stale := p.freshness.status(p.thresholds.StalenessThresholds, time.Now())
+	if stale == FruStatusRed {
+		return FruStatusRed, fmt.Errorf("PSU %d readings unchanged since %s", p.instance, p.freshness.lastUpdated.Format(time.RFC3339))
+	}
+
+	if p.voltage < p.thresholds.VoltageMin || p.voltage > p.thresholds.VoltageMax {
 		return FruStatusRed, nil
 	}
-	if p.power > 800 { // Example: 800W threshold
+	if p.power > p.thresholds.PowerWarn {
 		return FruStatusYellow, nil
 	}
-	return FruStatusGreen, nil
+	return stale, nil
 }
 
 func (p *PSU) updateMetrics(ctx context.Context) error {
-	// In a real implementation, this would read from hardware
-	// For now, using example values
-	p.voltage = 12.0  // 12V
-	p.current = 50.0  // 50A
-	p.power = 600.0   // 600W
+	reading, err := p.source(ctx)
+	if err != nil {
+		p.logger.Error("Failed to read PSU %d: %v", p.instance, err)
+		return err
+	}
+	p.voltage = reading.Voltage
+	p.current = reading.Current
+	p.power = reading.Power
+
+	now := time.Now()
+	p.freshness.observe(reading, now)
 
 	// Create metrics structure
 	metrics := PSUMetrics{
-		Voltage:   p.voltage,
-		Current:   p.current,
-		Power:     p.power,
-		Timestamp: time.Now().Format(time.RFC3339),
+		Voltage:     p.voltage,
+		Current:     p.current,
+		Power:       p.power,
+		Timestamp:   now.Format(time.RFC3339),
+		LastUpdated: p.freshness.lastUpdated.Format(time.RFC3339),
+		Stale:       p.freshness.status(p.thresholds.StalenessThresholds, now) != FruStatusGreen,
+
+		SchemaVersion: hardwareMetricsSchemaVersion,
 	}
 
 	// Convert metrics to JSON
@@ -84,22 +122,49 @@ func (p *PSU) updateMetrics(ctx context.Context) error {
 	}
 
 	// Store metrics in Redis
-	key := fmt.Sprintf("hardware:psu:%d:metrics", p.instance)
-	if err := p.redis.client.Set(ctx, key, string(metricsJSON), 0).Err(); err != nil {
+	if err := p.redis.UpdateHardwareMetrics(ctx, "psu", p.instance, string(metricsJSON)); err != nil {
 		p.logger.Error("Failed to store PSU %d metrics in Redis: %v", p.instance, err)
 		return err
 	}
 
-	p.logger.Info("Updated PSU %d metrics: Voltage=%.2fV, Current=%.2fA, Power=%.2fW",
+	p.metrics.ObservePSU(p.instance, metrics)
+
+	p.logger.Debug("Updated PSU %d metrics: Voltage=%.2fV, Current=%.2fA, Power=%.2fW",
 		p.instance, p.voltage, p.current, p.power)
 	return nil
 }
 
+// simulatedPSU reports fixed example values, for hosts without a PSU backend
+func simulatedPSU(ctx context.Context) (PSUMetrics, error) {
+	return PSUMetrics{
+		Voltage: 12.0,  // 12V
+		Current: 50.0,  // 50A
+		Power:   600.0, // 600W
+	}, nil
+}
+
 func (p *PSU) available() bool {
 	return p.isPresent
 }
 
 func (p *PSU) setInstance(instance int) {
 	p.instance = instance
+	p.logger = p.logger.WithFields(map[string]interface{}{"fru": p.getName()})
 	p.logger.Info("Set PSU instance to %d", instance)
 }
+
+// setReadRetry sets how failed metric reads are retried
+func (p *PSU) setReadRetry(config ReadRetryConfig) {
+	p.retry.config = config
+}
+
+// setPresencePath sets the file whose existence shows the PSU is present
+func (p *PSU) setPresencePath(path string) {
+	p.presencePath = path
+	p.logger.Info("Set PSU %d presence path to %s", p.instance, path)
+}
+
+// setSource replaces where the PSU's readings come from
+func (p *PSU) setSource(source PSUSource) {
+	p.source = source
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+const (
+	defaultReadAttempts  = 3
+	defaultReadBaseDelay = 100 * time.Millisecond
+)
+
+// ReadRetryConfig decides how often a failed FRU metric read is retried within a poll
+// before the FRU turns red, so a single transient failure doesn't flap its status
+type ReadRetryConfig struct {
+	Attempts  int      `json:"attempts"`  // reads per poll, default 3, 1 disables retries
+	BaseDelay Duration `json:"baseDelay"` // wait before the first retry, doubled for each further one, default 100ms
+}
+
+// withDefaults fills unset settings with the defaults
+func (c ReadRetryConfig) withDefaults() ReadRetryConfig {
+	if c.Attempts == 0 {
+		c.Attempts = defaultReadAttempts
+	}
+	if c.BaseDelay.Duration == 0 {
+		c.BaseDelay.Duration = defaultReadBaseDelay
+	}
+	return c
+}
+
+// validate checks that the settings are not negative
+func (c ReadRetryConfig) validate() error {
+	if c.Attempts < 0 {
+		return fmt.Errorf("readRetry attempts %d is negative", c.Attempts)
+	}
+	if c.BaseDelay.Duration < 0 {
+		return fmt.Errorf("readRetry baseDelay %v is negative", c.BaseDelay.Duration)
+	}
+	return nil
+}
+
+// FruReadFailures counts the failed metric reads of a FRU, stored in Redis
+type FruReadFailures struct {
+	ConsecutiveFailures int    `json:"consecutive_failures"` // failed reads since the last successful one
+	TotalFailures       int    `json:"total_failures"`       // failed reads since hostd started, including those a retry recovered
+	LastError           string `json:"last_error,omitempty"`
+	LastFailure         string `json:"last_failure,omitempty"`
+	Timestamp           string `json:"timestamp"`
+}
+
+// readRetry retries the metric reads of a FRU and counts their failures. The zero value
+// uses the default settings.
+type readRetry struct {
+	config   ReadRetryConfig
+	failures FruReadFailures
+}
+
+// read calls update until it succeeds or the attempts run out, backing off exponentially
+// between attempts. An error for a missing file, such as the sysfs input of a removed
+// device, is a hard failure and is not retried. The failure counts are stored whenever a
+// read failed and once more when reads succeed again.
+func (r *readRetry) read(ctx context.Context, redis StatusStore, logger *Logger, fruType string, instance int, update func(ctx context.Context) error) error {
+	config := r.config.withDefaults()
+	hadFailures := r.failures.ConsecutiveFailures > 0
+
+	var err error
+	delay := config.BaseDelay.Duration
+	for attempt := 1; ; attempt++ {
+		if err = update(ctx); err == nil {
+			if attempt > 1 {
+				logger.Info("Read of %s %d succeeded after %d attempts", fruType, instance, attempt)
+			}
+			break
+		}
+
+		now := time.Now()
+		r.failures.ConsecutiveFailures++
+		r.failures.TotalFailures++
+		r.failures.LastError = err.Error()
+		r.failures.LastFailure = now.Format(time.RFC3339)
+		hadFailures = true
+
+		if attempt >= config.Attempts || errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+
+	if err == nil {
+		r.failures.ConsecutiveFailures = 0
+	}
+	if hadFailures {
+		r.failures.Timestamp = time.Now().Format(time.RFC3339)
+		data, merr := json.Marshal(r.failures)
+		if merr == nil {
+			merr = redis.UpdateHardwareReadFailures(ctx, fruType, instance, string(data))
+		}
+		if merr != nil {
+			logger.Error("Failed to store read failures of %s %d: %v", fruType, instance, merr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// powerRedundancyName is the component the power redundancy appears as in the chassis status
+const powerRedundancyName = "power:redundancy"
+
+// PowerRedundancy is the power redundancy of the chassis stored in Redis
+type PowerRedundancy struct {
+	Status     FruStatus `json:"status"`     // green with all spares, yellow without a spare, red below the required count
+	Required   int       `json:"required"`   // PSUs needed to carry the load
+	Redundancy int       `json:"redundancy"` // spare PSUs configured on top of the required ones
+	Healthy    int       `json:"healthy"`    // present PSUs that are not red
+	Timestamp  string    `json:"timestamp"`
+}
+
+// powerRedundancy evaluates whether enough PSUs are healthy to carry the load with the
+// configured number of spares, as in N+1
+type powerRedundancy struct {
+	required   int
+	redundancy int
+	psus       []string // names of the polled PSUs
+	status     FruStatus
+}
+
+// newPowerRedundancy returns the evaluator for config, or nil if no required PSU count is configured
+func newPowerRedundancy(config PSUGroupConfig, psus []string) *powerRedundancy {
+	if config.Required <= 0 {
+		return nil
+	}
+	return &powerRedundancy{
+		required:   config.Required,
+		redundancy: config.Redundancy,
+		psus:       psus,
+		status:     FruStatusGreen,
+	}
+}
+
+// evaluate computes the power redundancy from the statuses of this poll and logs a change
+func (p *powerRedundancy) evaluate(frus map[string]FruStatus, now time.Time, logger *Logger) PowerRedundancy {
+	healthy := 0
+	for _, name := range p.psus {
+		if status, ok := frus[name]; ok && status != FruStatusRed {
+			healthy++
+		}
+	}
+
+	result := PowerRedundancy{
+		Status:     FruStatusGreen,
+		Required:   p.required,
+		Redundancy: p.redundancy,
+		Healthy:    healthy,
+		Timestamp:  now.Format(time.RFC3339),
+	}
+	switch {
+	case healthy < p.required:
+		result.Status = FruStatusRed
+	case healthy < p.required+p.redundancy:
+		result.Status = FruStatusYellow
+	}
+
+	if result.Status != p.status {
+		msg := fmt.Sprintf("Power redundancy changed: %s -> %s (%d healthy PSUs, %d+%d required)",
+			p.status, result.Status, healthy, p.required, p.redundancy)
+		switch result.Status {
+		case FruStatusRed:
+			logger.Critical("%s", msg)
+		case FruStatusYellow:
+			logger.Error("%s", msg)
+		default:
+			logger.Info("%s", msg)
+		}
+		p.status = result.Status
+	}
+	return result
+}
+
+// store writes the power redundancy to Redis
+func (p *powerRedundancy) store(ctx context.Context, redis StatusStore, result PowerRedundancy, logger *Logger) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		logger.Error("Error marshaling power redundancy: %v", err)
+		return
+	}
+	if err := redis.UpdatePowerRedundancy(ctx, string(data)); err != nil {
+		logger.Error("Error updating power redundancy in Redis: %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultRestartStablePeriod = 5 * time.Minute
+
+const (
+	defaultFlapThreshold = 5
+	defaultFlapWindow    = 5 * time.Minute
+	defaultFlapCooldown  = 15 * time.Minute
+)
+
+// decideRestart updates the automatic restart bookkeeping of a process after a status
+// check and reports whether a restart should be attempted now. The caller holds the
+// process lock.
+func (pm *ProcessMonitor) decideRestart(ctx context.Context, proc Process, wasUp, up bool) bool {
+	state := pm.runtimeState(ctx, proc.Name)
+	defer pm.saveRuntimeState(ctx, proc.Name)
+
+	if up {
+		state.CrashPending = false
+		state.ManualStop = false
+		state.FlappingSince = time.Time{}
+		if state.RestartCount > 0 && time.Since(state.LastRestart) >= pm.config.RestartStablePeriod.Duration {
+			pm.logger.Info("Process %s stable for %v, resetting restart count (was %d)",
+				proc.Name, pm.config.RestartStablePeriod.Duration, state.RestartCount)
+			state.RestartCount = 0
+			state.RetriesExhausted = false
+		}
+		return false
+	}
+
+	// A managed process is kept running, even if it was never seen up, unless it was
+	// stopped by a command
+	reconcile := proc.Managed && !wasUp && !state.CrashPending
+	if (wasUp || proc.Managed) && !state.ManualStop {
+		state.CrashPending = true
+	}
+	if !proc.Restart || !state.CrashPending || state.restarting {
+		return false
+	}
+
+	// Children hostd started report an exit code, which the restart policy can act on
+	if code, ok := pm.takeChildExit(proc.Name); ok && !shouldRestartOnExit(proc, code) {
+		pm.logger.Info("Process %s exited with code %d, not restarting (restartOn: %s)",
+			proc.Name, code, proc.RestartOn)
+		state.CrashPending = false
+		return false
+	}
+
+	if !pm.alertsArmed(proc) {
+		pm.logger.Info("Deferring restart of process %s until the startup delay has elapsed", proc.Name)
+		return false
+	}
+
+	if pm.flapping(proc, state) {
+		return false
+	}
+
+	if state.RestartCount >= proc.MaxRetries {
+		if !state.RetriesExhausted {
+			pm.logger.Critical("Process %s restart retries exhausted (%d of %d), giving up",
+				proc.Name, state.RestartCount, proc.MaxRetries)
+			state.RetriesExhausted = true
+		}
+		return false
+	}
+
+	// Starting a managed process before its dependencies are up would only use up retries
+	if reconcile {
+		if err := pm.checkDependencies(ctx, proc); err != nil {
+			pm.logger.Debug("Not starting managed process %s yet: %v", proc.Name, err)
+			state.CrashPending = false
+			return false
+		}
+		pm.logger.Info("Managed process %s is not running, starting it", proc.Name)
+	}
+
+	// CrashPending stays set, so the restart is retried on the next check
+	if !pm.restartLimit.take(time.Now()) {
+		pm.logger.Info("Deferring restart of process %s, global limit of %d restarts per minute reached",
+			proc.Name, pm.config.MaxRestartsPerMinute)
+		return false
+	}
+
+	state.RestartCount++
+	state.LastRestart = time.Now()
+	state.RestartTimes = append(state.RestartTimes, state.LastRestart)
+	state.restarting = true
+	return true
+}
+
+// restartLimiter is a token bucket shared by all processes that paces automatic restarts,
+// so a correlated outage doesn't relaunch every process at once. A nil limiter allows
+// every restart.
+type restartLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second
+	burst  float64 // most tokens held at once
+	tokens float64
+	last   time.Time
+}
+
+// newRestartLimiter returns a limiter allowing perMinute restarts per minute, up to
+// perMinute at once after a quiet period, or nil if perMinute is not positive
+func newRestartLimiter(perMinute int) *restartLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &restartLimiter{
+		rate:   float64(perMinute) / 60,
+		burst:  float64(perMinute),
+		tokens: float64(perMinute),
+		last:   time.Now(),
+	}
+}
+
+// take uses up a token if one is available, reporting whether a restart may go ahead
+func (l *restartLimiter) take(now time.Time) bool {
+	if l == nil {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elapsed := now.Sub(l.last).Seconds(); elapsed > 0 {
+		l.tokens += elapsed * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+	}
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// flapping reports whether restarts of a process are on hold because it restarted too
+// often within the flap window. Once the cooldown has passed, restarts are allowed again.
+func (pm *ProcessMonitor) flapping(proc Process, state *ProcessRuntimeState) bool {
+	now := time.Now()
+
+	// Drop restarts that have left the window
+	recent := state.RestartTimes[:0]
+	for _, t := range state.RestartTimes {
+		if now.Sub(t) < pm.config.FlapWindow.Duration {
+			recent = append(recent, t)
+		}
+	}
+	state.RestartTimes = recent
+
+	if !state.FlappingSince.IsZero() {
+		if now.Sub(state.FlappingSince) < pm.config.FlapCooldown.Duration {
+			return true
+		}
+		pm.logger.Info("Process %s flap cooldown of %v elapsed, resuming restarts", proc.Name, pm.config.FlapCooldown.Duration)
+		state.FlappingSince = time.Time{}
+		state.RestartTimes = nil
+		return false
+	}
+
+	if len(state.RestartTimes) >= pm.config.FlapThreshold {
+		pm.logger.Critical("Process %s is flapping (%d restarts within %v), pausing restarts for %v",
+			proc.Name, len(state.RestartTimes), pm.config.FlapWindow.Duration, pm.config.FlapCooldown.Duration)
+		state.FlappingSince = now
+		return true
+	}
+	return false
+}
+
+// autoRestart relaunches a crashed or unhealthy process, together with its restart group if it has one
+func (pm *ProcessMonitor) autoRestart(ctx context.Context, proc Process) {
+	defer func() {
+		unlock := pm.lockProcess(proc.Name)
+		pm.runtimeState(ctx, proc.Name).restarting = false
+		unlock()
+	}()
+
+	unlock := pm.lockProcess(proc.Name)
+	attempt := pm.runtimeState(ctx, proc.Name).RestartCount
+	unlock()
+
+	pm.notifier.Notify(WebhookEvent{
+		Event:   WebhookEventProcessRestart,
+		Process: proc.Name,
+		Message: fmt.Sprintf("Restarting process %s (attempt %d of %d)", proc.Name, attempt, proc.MaxRetries),
+	})
+
+	members := pm.restartGroupMembers(proc)
+	if len(members) > 1 {
+		pm.logger.Info("Restarting group %s after process %s crashed (attempt %d of %d)",
+			proc.RestartGroup, proc.Name, attempt, proc.MaxRetries)
+		for _, result := range pm.restartGroup(ctx, members) {
+			if result.Error != "" {
+				pm.logger.Error("Restart of process %s in group %s failed: %s", result.Process, proc.RestartGroup, result.Error)
+			}
+		}
+	} else if pid, _ := pm.getProcessPID(proc); pid > 0 {
+		// Still running, so it was found unhealthy rather than crashed
+		pm.logger.Info("Restarting unhealthy process %s (attempt %d of %d)", proc.Name, attempt, proc.MaxRetries)
+		if err := pm.restartProcess(ctx, proc); err != nil {
+			pm.logger.Error("Restart of process %s failed: %v", proc.Name, err)
+		}
+	} else {
+		pm.logger.Info("Restarting crashed process %s (attempt %d of %d)", proc.Name, attempt, proc.MaxRetries)
+		if err := pm.startProcess(ctx, proc); err != nil {
+			pm.logger.Error("Restart of process %s failed: %v", proc.Name, err)
+		}
+	}
+
+	// Refresh status without triggering another restart; a failed attempt is retried next check
+	for _, member := range members {
+		pm.refreshProcStatus(ctx, member)
+	}
+}
+
+// setManualStop records whether a process was deliberately stopped by a command
+func (pm *ProcessMonitor) setManualStop(ctx context.Context, proc Process, stopped bool) {
+	unlock := pm.lockProcess(proc.Name)
+	defer unlock()
+
+	state := pm.runtimeState(ctx, proc.Name)
+	if state.ManualStop == stopped {
+		return
+	}
+	state.ManualStop = stopped
+	if stopped {
+		state.CrashPending = false
+	}
+	pm.saveRuntimeState(ctx, proc.Name)
+}
+
+// recordChildExit stores the exit code of a child hostd started
+func (pm *ProcessMonitor) recordChildExit(name string, code int) {
+	pm.mu.Lock()
+	pm.childExits[name] = code
+	pm.mu.Unlock()
+}
+
+// takeChildExit returns and clears the recorded exit code of a child hostd started
+func (pm *ProcessMonitor) takeChildExit(name string) (int, bool) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	code, ok := pm.childExits[name]
+	delete(pm.childExits, name)
+	return code, ok
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// runtimeStateVersion is the current shape of ProcessRuntimeState. Bump it when fields
+// change meaning; adding fields that default sensibly to their zero value does not need a bump.
+const runtimeStateVersion = 1
+
+// ProcessRuntimeState holds the per-process state accumulated by the monitor's stateful
+// mechanisms. It is stored as a single Redis key per process so all of them survive
+// a daemon restart together.
+type ProcessRuntimeState struct {
+	Version int         `json:"version"`
+	Uptime  UptimeStats `json:"uptime"`
+
+	// Automatic restart bookkeeping
+	RestartCount     int       `json:"restart_count"`
+	LastRestart      time.Time `json:"last_restart"`
+	CrashPending     bool      `json:"crash_pending"`     // down after a crash and waiting to be restarted
+	RetriesExhausted bool      `json:"retries_exhausted"` // MaxRetries reached, no more automatic restarts
+	ManualStop       bool      `json:"manual_stop"`       // stopped by a command, so not restarted automatically
+	restarting       bool      // an automatic restart is in progress
+
+	// Flap detection
+	RestartTimes  []time.Time `json:"restart_times,omitempty"` // automatic restarts within the flap window
+	FlappingSince time.Time   `json:"flapping_since"`          // restarts paused since then, zero if not flapping
+
+	// Memory leak detection
+	MemorySamples []MemorySample `json:"memory_samples,omitempty"` // recent readings of the current PID, oldest first
+	SuspectedLeak bool           `json:"suspected_leak"`
+
+	stored   map[string]json.RawMessage // record written by a newer hostd, whose unknown fields are kept on save
+	readOnly bool                       // stored record couldn't be parsed, so it is never overwritten
+}
+
+// LoadRuntimeState loads the persisted runtime state of every configured process from Redis
+func (pm *ProcessMonitor) LoadRuntimeState(ctx context.Context) {
+	for _, proc := range pm.Processes() {
+		pm.runtimeState(ctx, proc.Name)
+	}
+}
+
+// runtimeState returns the in-memory runtime state of a process, loading it from Redis
+// the first time it is needed. If Redis can't be read, a blank state is returned without
+// being kept, so it is never saved over the stored one and the next call loads again.
+// Callers mutating it must hold the process lock.
+func (pm *ProcessMonitor) runtimeState(ctx context.Context, processName string) *ProcessRuntimeState {
+	pm.mu.Lock()
+	state, ok := pm.runtime[processName]
+	pm.mu.Unlock()
+	if ok {
+		return state
+	}
+
+	state, err := pm.loadRuntimeState(ctx, processName)
+	if err != nil {
+		pm.logger.Error("Error loading runtime state for process %s, retrying on the next check: %v", processName, err)
+		return &ProcessRuntimeState{Version: runtimeStateVersion}
+	}
+
+	// Another check may have loaded it meanwhile
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if loaded, ok := pm.runtime[processName]; ok {
+		return loaded
+	}
+	pm.runtime[processName] = state
+	return state
+}
+
+// loadRuntimeState reads the runtime state of a process from Redis, migrating the uptime
+// accumulators stored before runtime state was centralized. Only missing keys count as
+// no state; any other error is returned.
+func (pm *ProcessMonitor) loadRuntimeState(ctx context.Context, processName string) (*ProcessRuntimeState, error) {
+	state := &ProcessRuntimeState{Version: runtimeStateVersion}
+
+	data, err := pm.redis.GetRuntimeState(ctx, processName)
+	if err == nil {
+		if err := json.Unmarshal([]byte(data), state); err != nil {
+			pm.logger.Error("Error parsing runtime state for process %s, leaving the stored record untouched: %v", processName, err)
+			return &ProcessRuntimeState{Version: runtimeStateVersion, readOnly: true}, nil
+		}
+		switch {
+		case state.Version > runtimeStateVersion:
+			pm.logger.Info("Runtime state for process %s has newer version %d, keeping its unknown fields",
+				processName, state.Version)
+			json.Unmarshal([]byte(data), &state.stored)
+		case state.Version < runtimeStateVersion:
+			state.Version = runtimeStateVersion
+		}
+		return state, nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	data, err = pm.redis.GetUptimeStats(ctx, processName)
+	if errors.Is(err, redis.Nil) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(data), &state.Uptime); err != nil {
+		pm.logger.Error("Error parsing uptime stats for process %s: %v", processName, err)
+		state.Uptime = UptimeStats{}
+	}
+	return state, nil
+}
+
+// saveRuntimeState stores the runtime state of a process in Redis
+func (pm *ProcessMonitor) saveRuntimeState(ctx context.Context, processName string) {
+	pm.mu.Lock()
+	state, ok := pm.runtime[processName]
+	if !ok {
+		pm.mu.Unlock()
+		return
+	}
+	if state.readOnly {
+		pm.mu.Unlock()
+		return
+	}
+	stateJSON, err := marshalRuntimeState(state)
+	pm.mu.Unlock()
+
+	if err != nil {
+		pm.logger.Error("Error marshaling runtime state for process %s: %v", processName, err)
+		return
+	}
+
+	if err := pm.redis.UpdateRuntimeState(ctx, processName, string(stateJSON)); err != nil {
+		pm.logger.Error("Error updating runtime state in Redis for process %s: %v", processName, err)
+	}
+}
+
+// marshalRuntimeState encodes state, keeping the fields a newer hostd stored that this
+// version doesn't know about
+func marshalRuntimeState(state *ProcessRuntimeState) ([]byte, error) {
+	data, err := json.Marshal(state)
+	if err != nil || state.stored == nil {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	// Known fields left out as empty must not come back from the stored record
+	known := make(map[string]bool)
+	stateType := reflect.TypeOf(*state)
+	for i := 0; i < stateType.NumField(); i++ {
+		if name, _, _ := strings.Cut(stateType.Field(i).Tag.Get("json"), ","); name != "" {
+			known[name] = true
+		}
+	}
+	merged := make(map[string]json.RawMessage, len(state.stored)+len(fields))
+	for key, value := range state.stored {
+		if !known[key] {
+			merged[key] = value
+		}
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return json.Marshal(merged)
+}
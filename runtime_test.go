@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRuntimeStateMigratesLegacyUptime(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	lastSample := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Before runtime state was centralized, uptime had a key of its own and no version
+	legacy := `{"up_seconds":3600,"down_seconds":60,"last_sample":"2024-05-01T12:00:00Z","last_up":true,"below_target":true}`
+	store.set("process:api:uptime", legacy)
+
+	pm := NewProcessMonitor([]Process{{Name: "api"}}, MonitoringConfig{}, store, nil, newTestLogger())
+	pm.LoadRuntimeState(ctx)
+	state := pm.runtimeState(ctx, "api")
+
+	want := UptimeStats{UpSeconds: 3600, DownSeconds: 60, LastSample: lastSample, LastUp: true, BelowTarget: true}
+	if state.Uptime != want {
+		t.Errorf("migrated uptime = %+v, want %+v", state.Uptime, want)
+	}
+	if state.Version != runtimeStateVersion {
+		t.Errorf("version = %d, want %d", state.Version, runtimeStateVersion)
+	}
+
+	// Once saved, the accumulators live in the versioned runtime key
+	pm.saveRuntimeState(ctx, "api")
+	data, err := store.GetRuntimeState(ctx, "api")
+	if err != nil {
+		t.Fatalf("runtime state not saved: %v", err)
+	}
+	var saved ProcessRuntimeState
+	if err := json.Unmarshal([]byte(data), &saved); err != nil {
+		t.Fatalf("saved runtime state %q: %v", data, err)
+	}
+	if saved.Version != runtimeStateVersion || saved.Uptime != want {
+		t.Errorf("saved state version %d uptime %+v, want version %d uptime %+v",
+			saved.Version, saved.Uptime, runtimeStateVersion, want)
+	}
+}
+
+func TestRuntimeStateLoadsUnversionedRecord(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	// A runtime record written without a version field reads as version 0
+	store.set("process:api:runtime", `{"uptime":{"up_seconds":120,"down_seconds":30,"last_up":false},"restart_count":2,"crash_pending":true}`)
+
+	pm := NewProcessMonitor([]Process{{Name: "api"}}, MonitoringConfig{}, store, nil, newTestLogger())
+	state := pm.runtimeState(ctx, "api")
+
+	if state.Version != runtimeStateVersion {
+		t.Errorf("version = %d, want %d", state.Version, runtimeStateVersion)
+	}
+	if state.Uptime.UpSeconds != 120 || state.Uptime.DownSeconds != 30 {
+		t.Errorf("uptime = %+v, want 120s up and 30s down", state.Uptime)
+	}
+	if state.RestartCount != 2 || !state.CrashPending {
+		t.Errorf("restart count %d, crash pending %v; want 2 and true", state.RestartCount, state.CrashPending)
+	}
+}
+
+func TestRuntimeStatePrefersRuntimeKeyOverLegacyUptime(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.set("process:api:uptime", `{"up_seconds":1,"down_seconds":1}`)
+	store.set("process:api:runtime", `{"version":1,"uptime":{"up_seconds":500,"down_seconds":5}}`)
+
+	pm := NewProcessMonitor([]Process{{Name: "api"}}, MonitoringConfig{}, store, nil, newTestLogger())
+	state := pm.runtimeState(ctx, "api")
+
+	if state.Uptime.UpSeconds != 500 || state.Uptime.DownSeconds != 5 {
+		t.Errorf("uptime = %+v, want the runtime key's 500s up and 5s down", state.Uptime)
+	}
+}
+
+// flakyRuntimeStore is a MemoryStore whose runtime state reads fail with err while it is set
+type flakyRuntimeStore struct {
+	*MemoryStore
+	err error
+}
+
+func (s *flakyRuntimeStore) GetRuntimeState(ctx context.Context, processName string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	return s.MemoryStore.GetRuntimeState(ctx, processName)
+}
+
+func TestRuntimeStateRedisErrorKeepsStoredState(t *testing.T) {
+	ctx := context.Background()
+	stored := `{"version":1,"uptime":{"up_seconds":500,"down_seconds":5},"restart_count":4}`
+	store := &flakyRuntimeStore{MemoryStore: NewMemoryStore(), err: errors.New("i/o timeout")}
+	store.set("process:api:runtime", stored)
+
+	pm := NewProcessMonitor([]Process{{Name: "api"}}, MonitoringConfig{}, store, nil, newTestLogger())
+	state := pm.runtimeState(ctx, "api")
+	if state.RestartCount != 0 {
+		t.Errorf("restart count %d from a failed read, want a blank state", state.RestartCount)
+	}
+	state.RestartCount = 1
+	pm.saveRuntimeState(ctx, "api")
+	if data, _ := store.Get("process:api:runtime"); data != stored {
+		t.Errorf("stored runtime state overwritten after a failed read: %s", data)
+	}
+
+	// The next call loads again once Redis answers
+	store.err = nil
+	if state := pm.runtimeState(ctx, "api"); state.RestartCount != 4 || state.Uptime.UpSeconds != 500 {
+		t.Errorf("restart count %d uptime %+v, want the stored 4 restarts and 500s up", state.RestartCount, state.Uptime)
+	}
+}
+
+func TestRuntimeStateKeepsNewerVersionFields(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.set("process:api:runtime", `{"version":2,"restart_count":3,"restart_times":["2024-05-01T12:00:00Z"],"budget":{"left":7}}`)
+
+	pm := NewProcessMonitor([]Process{{Name: "api"}}, MonitoringConfig{}, store, nil, newTestLogger())
+	state := pm.runtimeState(ctx, "api")
+	if state.Version != 2 {
+		t.Errorf("version = %d, want the stored 2", state.Version)
+	}
+	state.RestartCount = 5
+	state.RestartTimes = nil
+	pm.saveRuntimeState(ctx, "api")
+
+	data, err := store.Get("process:api:runtime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &saved); err != nil {
+		t.Fatalf("saved runtime state %q: %v", data, err)
+	}
+	if string(saved["version"]) != "2" || string(saved["restart_count"]) != "5" || string(saved["budget"]) != `{"left":7}` {
+		t.Errorf("saved %s, want version 2, the new restart count and the unknown budget field", data)
+	}
+	if _, ok := saved["restart_times"]; ok {
+		t.Errorf("saved %s, want the cleared restart times left out", data)
+	}
+}
+
+func TestRuntimeStateUnparsableRecordNotOverwritten(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	store.set("process:api:runtime", `{"version":1,"restart_count":"three"`)
+
+	pm := NewProcessMonitor([]Process{{Name: "api"}}, MonitoringConfig{}, store, nil, newTestLogger())
+	pm.runtimeState(ctx, "api").RestartCount = 1
+	pm.saveRuntimeState(ctx, "api")
+
+	if data, _ := store.Get("process:api:runtime"); data != `{"version":1,"restart_count":"three"` {
+		t.Errorf("unparsable runtime state overwritten with %s", data)
+	}
+}
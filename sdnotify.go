@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sdNotify sends a state such as "READY=1" to systemd over $NOTIFY_SOCKET. It reports
+// false without error when hostd wasn't started by systemd with a notify socket.
+func sdNotify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// A leading @ names a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sdWatchdogInterval returns how often systemd expects a WATCHDOG=1 keepalive, from
+// $WATCHDOG_USEC, or 0 if its watchdog is not enabled for this process
+func sdWatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// notifySystemd tells systemd that hostd is ready once every periodic task has completed
+// a run, and while systemd's watchdog is enabled sends a keepalive at half its timeout
+// for as long as every task keeps completing. A hung check loop thus stops the
+// keepalives and systemd restarts hostd. It does nothing when not run under systemd.
+func (pr *PeriodicRunner) notifySystemd(ctx context.Context) {
+	defer pr.wg.Done()
+
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return
+	}
+	interval := sdWatchdogInterval()
+
+	var keepalive <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+		keepalive = ticker.C
+		pr.logger.Info("Sending systemd watchdog keepalives every %v", interval/2)
+	}
+
+	ready := pr.ready
+	for {
+		select {
+		case <-ctx.Done():
+			pr.sdNotify("STOPPING=1")
+			return
+		case <-ready:
+			pr.sdNotify("READY=1")
+			pr.logger.Info("Notified systemd that hostd is ready")
+			ready = nil
+			if keepalive == nil {
+				return
+			}
+		case <-keepalive:
+			if pr.Alive() {
+				pr.sdNotify("WATCHDOG=1")
+			} else {
+				pr.logger.Error("Withholding systemd watchdog keepalive, a periodic task is stalled")
+			}
+		}
+	}
+}
+
+// sdNotify sends a state to systemd, logging failures
+func (pr *PeriodicRunner) sdNotify(state string) {
+	if _, err := sdNotify(state); err != nil {
+		pr.logger.Error("Error notifying systemd of %s: %v", state, err)
+	}
+}
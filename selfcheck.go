@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// toolProbe describes an external command hostd depends on and how to check that it works
+type toolProbe struct {
+	name   string
+	args   []string
+	verify func(output string) error
+}
+
+// requiredTools returns the external commands needed by the process probes of a
+// backend. The /proc backend needs none.
+func requiredTools(backend string) []toolProbe {
+	if backend == ProbeBackendProc {
+		return nil
+	}
+	pid := os.Getpid()
+
+	return []toolProbe{
+		{
+			// hostd's own command line must be found
+			name: "pgrep",
+			args: []string{"-f", regexp.QuoteMeta(os.Args[0])},
+			verify: func(output string) error {
+				for _, field := range strings.Fields(output) {
+					if _, err := strconv.Atoi(field); err != nil {
+						return fmt.Errorf("unexpected output %q", field)
+					}
+					if field == strconv.Itoa(pid) {
+						return nil
+					}
+				}
+				return fmt.Errorf("did not find own PID %d", pid)
+			},
+		},
+		{
+			// hostd's own RSS must parse as a number
+			name: "ps",
+			args: []string{"-o", "rss=", "-p", strconv.Itoa(pid)},
+			verify: func(output string) error {
+				if _, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64); err != nil {
+					return fmt.Errorf("unexpected output %q", strings.TrimSpace(output))
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// runSelfCheck verifies that every external command the probe backend requires exists
+// and returns parseable output, logging a Critical for each one that doesn't
+func runSelfCheck(backend string, logger *Logger) error {
+	var failed []string
+
+	for _, tool := range requiredTools(backend) {
+		if err := probeTool(tool); err != nil {
+			logger.Critical("Self-check failed for required command %s: %v", tool.name, err)
+			failed = append(failed, tool.name)
+			continue
+		}
+		logger.Info("Self-check passed for required command %s", tool.name)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("required commands not working: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// probeTool runs a single tool probe
+func probeTool(tool toolProbe) error {
+	path, err := exec.LookPath(tool.name)
+	if err != nil {
+		return fmt.Errorf("not found in PATH: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, path, tool.args...).Output()
+	if err != nil {
+		return fmt.Errorf("probe %s %s failed: %v", path, strings.Join(tool.args, " "), err)
+	}
+
+	return tool.verify(string(output))
+}
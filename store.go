@@ -0,0 +1,31 @@
+package main
+
+import "context"
+
+// StatusStore persists process and hardware state. ProcessMonitor and the hardware
+// types depend on it rather than on RedisClient. Getters return redis.Nil when
+// nothing is stored under the requested key.
+type StatusStore interface {
+	UpdateProcessStatus(ctx context.Context, processName string, status string) error
+	GetProcessStatus(ctx context.Context, processName string) (string, error)
+	GetProcessStatusFresh(ctx context.Context, processName string) (string, error)
+	GetAllProcessStatuses(ctx context.Context, processNames []string) (map[string]*ProcessStatus, error)
+	GetUptimeStats(ctx context.Context, processName string) (string, error)
+	UpdateUptimeRatio(ctx context.Context, processName string, ratio float64) error
+	GetRuntimeState(ctx context.Context, processName string) (string, error)
+	UpdateRuntimeState(ctx context.Context, processName string, state string) error
+	DeleteProcessKeys(ctx context.Context, processName string) error
+	AddEvent(ctx context.Context, values map[string]interface{}) error
+	UpdateHardwareMetrics(ctx context.Context, fruType string, instance int, metrics string) error
+	UpdateHardwareReadFailures(ctx context.Context, fruType string, instance int, failures string) error
+	UpdateChassisStatus(ctx context.Context, status string) error
+	UpdatePowerRedundancy(ctx context.Context, status string) error
+	UpdateDiskStatus(ctx context.Context, mount string, status string) error
+	UpdateSystemStatus(ctx context.Context, name string, status string) error
+}
+
+var (
+	_ StatusStore = (*RedisClient)(nil)
+
+	_ hardwareMetricsBatcher = (*RedisClient)(nil)
+)
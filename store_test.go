@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// TestStatusPipeline runs checks of several processes through UpdateAll into a
+// MemoryStore, one row per sequence of probe results
+func TestStatusPipeline(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks [][]int // running PIDs at each check
+		want   string  // status stored after the last check
+		events int     // transitions published
+	}{
+		{"stays up", [][]int{{10}, {10}, {10}}, "up", 1},
+		{"stays down", [][]int{nil, nil}, "down", 1},
+		{"crashes", [][]int{{10}, nil}, "down", 2},
+		{"recovers", [][]int{nil, {11}}, "up", 2},
+		{"flaps", [][]int{{10}, nil, {12}, nil}, "down", 4},
+		{"restarts unseen", [][]int{{10}, {13}}, "up", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			pm, store, prober := newTestMonitor([]Process{{Name: "api"}, {Name: "worker"}}, MonitoringConfig{})
+
+			for _, pids := range tt.checks {
+				prober.set("api", pids...)
+				prober.set("worker", 99)
+				pm.UpdateAll(ctx)
+			}
+
+			if status := storedStatus(t, store, "api"); status.Status != tt.want {
+				t.Errorf("api status %s, want %s", status.Status, tt.want)
+			}
+			if status := storedStatus(t, store, "worker"); status.Status != "up" {
+				t.Errorf("worker status %s, want up", status.Status)
+			}
+			events := 0
+			for _, event := range store.Events() {
+				if event["process"] == "api" && event["new_status"] != nil {
+					events++
+				}
+			}
+			if events != tt.events {
+				t.Errorf("%d api transitions published, want %d", events, tt.events)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreMissingKeys(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	if _, err := store.GetProcessStatus(ctx, "api"); err != redis.Nil {
+		t.Errorf("GetProcessStatus of an unknown process returned %v, want redis.Nil", err)
+	}
+	if _, err := store.GetRuntimeState(ctx, "api"); err != redis.Nil {
+		t.Errorf("GetRuntimeState of an unknown process returned %v, want redis.Nil", err)
+	}
+	statuses, err := store.GetAllProcessStatuses(ctx, []string{"api"})
+	if err != nil || len(statuses) != 0 {
+		t.Errorf("GetAllProcessStatuses = %v, %v; want nothing", statuses, err)
+	}
+}
+
+func TestReloadClearsRemovedProcessKeys(t *testing.T) {
+	ctx := context.Background()
+	pm, store, prober := newTestMonitor([]Process{{Name: "api"}, {Name: "worker"}}, MonitoringConfig{})
+	prober.set("api", 10)
+	prober.set("worker", 20)
+	pm.UpdateAll(ctx)
+
+	_, removed := pm.ReloadProcesses(ctx, []Process{{Name: "worker"}}, true)
+	if len(removed) != 1 || removed[0] != "api" {
+		t.Fatalf("removed %v, want [api]", removed)
+	}
+	for _, suffix := range []string{"status", "runtime"} {
+		if _, err := store.Get("process:api:" + suffix); err != redis.Nil {
+			t.Errorf("process:api:%s still stored after removal", suffix)
+		}
+	}
+	if _, err := store.Get("process:worker:status"); err != nil {
+		t.Errorf("status of the remaining process was cleared: %v", err)
+	}
+}
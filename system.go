@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SystemConfig holds the thresholds of the system load and memory monitor
+type SystemConfig struct {
+	Disabled          bool    `json:"disabled"`
+	LoadWarn          float64 `json:"loadWarn"`          // log an Error when the 1-minute load per CPU is above this, default 2
+	MemoryWarnPercent float64 `json:"memoryWarnPercent"` // log an Error when more than this percentage of memory is in use, default 90
+}
+
+// withDefaults fills unset thresholds with the defaults
+func (c SystemConfig) withDefaults() SystemConfig {
+	if c.LoadWarn == 0 {
+		c.LoadWarn = 2
+	}
+	if c.MemoryWarnPercent == 0 {
+		c.MemoryWarnPercent = 90
+	}
+	return c
+}
+
+// validate checks that the thresholds are in range
+func (c SystemConfig) validate() error {
+	if c.LoadWarn < 0 {
+		return fmt.Errorf("loadWarn %.2f is negative", c.LoadWarn)
+	}
+	if c.MemoryWarnPercent < 0 || c.MemoryWarnPercent > 100 {
+		return fmt.Errorf("memoryWarnPercent %.1f is outside 0-100", c.MemoryWarnPercent)
+	}
+	return nil
+}
+
+// SystemLoad is the load average stored in Redis under system:load
+type SystemLoad struct {
+	Load1     float64 `json:"load1"`
+	Load5     float64 `json:"load5"`
+	Load15    float64 `json:"load15"`
+	CPUs      int     `json:"cpus"`
+	Timestamp string  `json:"timestamp"`
+}
+
+// SystemMemory is the overall memory usage stored in Redis under system:memory
+type SystemMemory struct {
+	TotalBytes     int64   `json:"total_bytes"`
+	AvailableBytes int64   `json:"available_bytes"`
+	UsedPercent    float64 `json:"used_percent"`
+	Timestamp      string  `json:"timestamp"`
+}
+
+// SystemMonitor tracks system-wide load and memory pressure, which per-process metrics miss.
+// It reads /proc and does nothing on platforms without it.
+type SystemMonitor struct {
+	config  SystemConfig
+	redis   StatusStore
+	metrics *MetricsExporter // nil when the exporter is disabled
+	logger  *Logger
+
+	supported  bool
+	loadHigh   bool // load was above the threshold at the last check
+	memoryHigh bool // memory usage was above the threshold at the last check
+}
+
+// NewSystemMonitor creates a system monitor, disabled unless running on Linux
+func NewSystemMonitor(config SystemConfig, redis StatusStore, metrics *MetricsExporter, logger *Logger) *SystemMonitor {
+	sm := &SystemMonitor{
+		config:    config.withDefaults(),
+		redis:     redis,
+		metrics:   metrics,
+		logger:    logger,
+		supported: runtime.GOOS == "linux",
+	}
+	if !sm.supported {
+		logger.Info("System load and memory monitoring is not supported on %s", runtime.GOOS)
+	}
+	return sm
+}
+
+// readLoadAvg reads the 1, 5 and 15 minute load averages from /proc/loadavg
+func readLoadAvg() (SystemLoad, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return SystemLoad{}, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return SystemLoad{}, fmt.Errorf("unexpected /proc/loadavg content %q", strings.TrimSpace(string(data)))
+	}
+	var loads [3]float64
+	for i := range loads {
+		if loads[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+			return SystemLoad{}, fmt.Errorf("error parsing /proc/loadavg: %v", err)
+		}
+	}
+	return SystemLoad{Load1: loads[0], Load5: loads[1], Load15: loads[2], CPUs: runtime.NumCPU()}, nil
+}
+
+// readMemInfo reads the total and available memory from /proc/meminfo. Kernels older
+// than 3.14 lack MemAvailable, so it is estimated from free memory and caches there.
+func readMemInfo() (SystemMemory, error) {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return SystemMemory{}, err
+	}
+	defer file.Close()
+
+	values := make(map[string]int64)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		kb, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[name] = kb * 1024
+	}
+	if err := scanner.Err(); err != nil {
+		return SystemMemory{}, err
+	}
+
+	memory := SystemMemory{TotalBytes: values["MemTotal"]}
+	if memory.TotalBytes == 0 {
+		return SystemMemory{}, fmt.Errorf("no MemTotal in /proc/meminfo")
+	}
+	if available, ok := values["MemAvailable"]; ok {
+		memory.AvailableBytes = available
+	} else {
+		memory.AvailableBytes = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+	memory.UsedPercent = float64(memory.TotalBytes-memory.AvailableBytes) / float64(memory.TotalBytes) * 100
+	return memory, nil
+}
+
+// Check reads the load average and memory usage, stores them in Redis and the metrics
+// exporter and logs when either crosses its threshold
+func (sm *SystemMonitor) Check(ctx context.Context) {
+	if sm.config.Disabled || !sm.supported {
+		return
+	}
+	now := time.Now().Format(time.RFC3339)
+
+	if load, err := readLoadAvg(); err != nil {
+		sm.logger.Error("Error reading system load: %v", err)
+	} else {
+		load.Timestamp = now
+		perCPU := load.Load1 / float64(load.CPUs)
+		high := perCPU > sm.config.LoadWarn
+		if high && !sm.loadHigh {
+			sm.logger.Error("System load %.2f is %.2f per CPU, above %.2f", load.Load1, perCPU, sm.config.LoadWarn)
+		} else if !high && sm.loadHigh {
+			sm.logger.Info("System load is back to %.2f per CPU", perCPU)
+		}
+		sm.loadHigh = high
+		sm.metrics.ObserveSystemLoad(load)
+		sm.store(ctx, "load", load)
+	}
+
+	if memory, err := readMemInfo(); err != nil {
+		sm.logger.Error("Error reading system memory: %v", err)
+	} else {
+		memory.Timestamp = now
+		high := memory.UsedPercent > sm.config.MemoryWarnPercent
+		if high && !sm.memoryHigh {
+			sm.logger.Error("System memory is %.1f%% used, above %.1f%% (%.2f MB available)",
+				memory.UsedPercent, sm.config.MemoryWarnPercent, float64(memory.AvailableBytes)/(1024*1024))
+		} else if !high && sm.memoryHigh {
+			sm.logger.Info("System memory usage is back to %.1f%%", memory.UsedPercent)
+		}
+		sm.memoryHigh = high
+		sm.metrics.ObserveSystemMemory(memory)
+		sm.store(ctx, "memory", memory)
+	}
+}
+
+// store writes a reading to Redis under system:<name>
+func (sm *SystemMonitor) store(ctx context.Context, name string, reading interface{}) {
+	readingJSON, err := json.Marshal(reading)
+	if err != nil {
+		sm.logger.Error("Error marshaling system %s: %v", name, err)
+		return
+	}
+	if err := sm.redis.UpdateSystemStatus(ctx, name, string(readingJSON)); err != nil {
+		sm.logger.Error("Error updating system %s in Redis: %v", name, err)
+	}
+}
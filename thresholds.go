@@ -0,0 +1,102 @@
+package main
+
+import "fmt"
+
+// ThresholdsConfig holds the status thresholds of each hardware type.
+// Zero values use the defaults.
+type ThresholdsConfig struct {
+	Fan FanThresholds `json:"fan"`
+	PSU PSUThresholds `json:"psu"`
+	NPU NPUThresholds `json:"npu"`
+}
+
+// FanThresholds decide the status of a fan
+type FanThresholds struct {
+	MinSpeed int `json:"minSpeed"` // red below this RPM, default 100
+	MaxDuty  int `json:"maxDuty"`  // yellow above this duty percentage, default 90
+
+	StalenessThresholds
+}
+
+// PSUThresholds decide the status of a PSU
+type PSUThresholds struct {
+	VoltageMin float64 `json:"voltageMin"` // red below this voltage, default 10.8
+	VoltageMax float64 `json:"voltageMax"` // red above this voltage, default 13.2
+	PowerWarn  float64 `json:"powerWarn"`  // yellow above this many watts, default 800
+
+	StalenessThresholds
+}
+
+// NPUThresholds decide the status of an NPU, as usage percentages
+type NPUThresholds struct {
+	BufferWarn        float64 `json:"bufferWarn"`        // yellow above this, default 80
+	BufferCritical    float64 `json:"bufferCritical"`    // red above this, default 95
+	ProcessorWarn     float64 `json:"processorWarn"`     // yellow above this, default 85
+	ProcessorCritical float64 `json:"processorCritical"` // red above this, default 95
+
+	StalenessThresholds
+}
+
+// withDefaults fills unset thresholds with the defaults
+func (t ThresholdsConfig) withDefaults() ThresholdsConfig {
+	if t.Fan.MinSpeed == 0 {
+		t.Fan.MinSpeed = 100 // Fan almost stopped
+	}
+	if t.Fan.MaxDuty == 0 {
+		t.Fan.MaxDuty = 90 // Fan working too hard
+	}
+
+	if t.PSU.VoltageMin == 0 {
+		t.PSU.VoltageMin = 10.8 // -10% of 12V
+	}
+	if t.PSU.VoltageMax == 0 {
+		t.PSU.VoltageMax = 13.2 // +10% of 12V
+	}
+	if t.PSU.PowerWarn == 0 {
+		t.PSU.PowerWarn = 800
+	}
+
+	if t.NPU.BufferWarn == 0 {
+		t.NPU.BufferWarn = 80
+	}
+	if t.NPU.BufferCritical == 0 {
+		t.NPU.BufferCritical = 95
+	}
+	if t.NPU.ProcessorWarn == 0 {
+		t.NPU.ProcessorWarn = 85
+	}
+	if t.NPU.ProcessorCritical == 0 {
+		t.NPU.ProcessorCritical = 95
+	}
+
+	return t
+}
+
+// validate checks that every range is well-formed once defaults are applied
+func (t ThresholdsConfig) validate() error {
+	t = t.withDefaults()
+
+	if t.PSU.VoltageMin >= t.PSU.VoltageMax {
+		return fmt.Errorf("psu voltageMin %.2f must be below voltageMax %.2f", t.PSU.VoltageMin, t.PSU.VoltageMax)
+	}
+	if t.NPU.BufferWarn >= t.NPU.BufferCritical {
+		return fmt.Errorf("npu bufferWarn %.1f must be below bufferCritical %.1f", t.NPU.BufferWarn, t.NPU.BufferCritical)
+	}
+	if t.NPU.ProcessorWarn >= t.NPU.ProcessorCritical {
+		return fmt.Errorf("npu processorWarn %.1f must be below processorCritical %.1f", t.NPU.ProcessorWarn, t.NPU.ProcessorCritical)
+	}
+	if t.Fan.MaxDuty > 100 {
+		return fmt.Errorf("fan maxDuty %d must not exceed 100", t.Fan.MaxDuty)
+	}
+	if err := t.Fan.StalenessThresholds.validate("fan"); err != nil {
+		return err
+	}
+	if err := t.PSU.StalenessThresholds.validate("psu"); err != nil {
+		return err
+	}
+	if err := t.NPU.StalenessThresholds.validate("npu"); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+// fanStatus reads one sample through a fan and returns its status
+func fanStatus(t *testing.T, speed, duty int) FruStatus {
+	t.Helper()
+	fan := NewFan("fan", 0, ThresholdsConfig{}.withDefaults().Fan, newTestLogger(), NewMemoryStore(), nil)
+	fan.setSource(func(ctx context.Context) (FanMetrics, error) {
+		return FanMetrics{RotorSpeeds: []int{speed}, Duty: duty}, nil
+	})
+	status, err := fan.getStatus(context.Background())
+	if err != nil {
+		t.Fatalf("getStatus: %v", err)
+	}
+	return status
+}
+
+func TestFanBands(t *testing.T) {
+	limits := ThresholdsConfig{}.withDefaults().Fan
+	tests := []struct {
+		name        string
+		speed, duty int
+		want        FruStatus
+	}{
+		{"normal", 3000, 50, FruStatusGreen},
+		{"at min speed", limits.MinSpeed, 50, FruStatusGreen},
+		{"below min speed", limits.MinSpeed - 1, 50, FruStatusRed},
+		{"at max duty", 3000, limits.MaxDuty, FruStatusGreen},
+		{"above max duty", 3000, limits.MaxDuty + 1, FruStatusYellow},
+		{"stopped and working hard", 0, 100, FruStatusRed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fanStatus(t, tt.speed, tt.duty); got != tt.want {
+				t.Errorf("fan at %d RPM and %d%% duty is %s, want %s", tt.speed, tt.duty, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPSUBands(t *testing.T) {
+	limits := ThresholdsConfig{}.withDefaults().PSU
+	tests := []struct {
+		name           string
+		voltage, power float64
+		want           FruStatus
+	}{
+		{"normal", 12, 400, FruStatusGreen},
+		{"at min voltage", limits.VoltageMin, 400, FruStatusGreen},
+		{"below min voltage", limits.VoltageMin - 0.01, 400, FruStatusRed},
+		{"at max voltage", limits.VoltageMax, 400, FruStatusGreen},
+		{"above max voltage", limits.VoltageMax + 0.01, 400, FruStatusRed},
+		{"at power warn", 12, limits.PowerWarn, FruStatusGreen},
+		{"above power warn", 12, limits.PowerWarn + 0.1, FruStatusYellow},
+		{"undervolt at high power", limits.VoltageMin - 1, limits.PowerWarn + 100, FruStatusRed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			psu := NewPSU("psu", 0, limits, newTestLogger(), NewMemoryStore(), nil)
+			psu.setSource(func(ctx context.Context) (PSUMetrics, error) {
+				return PSUMetrics{Voltage: tt.voltage, Power: tt.power}, nil
+			})
+			got, err := psu.getStatus(context.Background())
+			if err != nil {
+				t.Fatalf("getStatus: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("PSU at %.2fV and %.1fW is %s, want %s", tt.voltage, tt.power, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNPUBands(t *testing.T) {
+	limits := ThresholdsConfig{}.withDefaults().NPU
+	tests := []struct {
+		name              string
+		buffer, processor float64
+		want              FruStatus
+	}{
+		{"idle", 10, 10, FruStatusGreen},
+		{"at buffer warn", limits.BufferWarn, 10, FruStatusGreen},
+		{"above buffer warn", limits.BufferWarn + 0.1, 10, FruStatusYellow},
+		{"at buffer critical", limits.BufferCritical, 10, FruStatusYellow},
+		{"above buffer critical", limits.BufferCritical + 0.1, 10, FruStatusRed},
+		{"at processor warn", 10, limits.ProcessorWarn, FruStatusGreen},
+		{"above processor warn", 10, limits.ProcessorWarn + 0.1, FruStatusYellow},
+		{"at processor critical", 10, limits.ProcessorCritical, FruStatusYellow},
+		{"above processor critical", 10, limits.ProcessorCritical + 0.1, FruStatusRed},
+		{"buffer warn, processor critical", limits.BufferWarn + 1, limits.ProcessorCritical + 1, FruStatusRed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			npu := NewNPU("npu", 0, limits, newTestLogger(), NewMemoryStore(), nil)
+			npu.setSource(func(ctx context.Context) (NPUMetrics, error) {
+				return NPUMetrics{BufferUsage: tt.buffer, ProcessorUsage: tt.processor}, nil
+			})
+			got, err := npu.getStatus(context.Background())
+			if err != nil {
+				t.Fatalf("getStatus: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("NPU at %.1f%% buffer and %.1f%% processor is %s, want %s", tt.buffer, tt.processor, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFanBandSequence feeds one fan a sequence of readings crossing every band and back
+func TestFanBandSequence(t *testing.T) {
+	limits := ThresholdsConfig{}.withDefaults().Fan
+	readings := []struct {
+		speed, duty int
+		want        FruStatus
+	}{
+		{3000, 50, FruStatusGreen},
+		{3000, limits.MaxDuty + 5, FruStatusYellow},
+		{limits.MinSpeed - 10, limits.MaxDuty + 5, FruStatusRed},
+		{3000, limits.MaxDuty + 5, FruStatusYellow},
+		{3000, 50, FruStatusGreen},
+	}
+
+	next := 0
+	fan := NewFan("fan", 0, limits, newTestLogger(), NewMemoryStore(), nil)
+	fan.setSource(func(ctx context.Context) (FanMetrics, error) {
+		r := readings[next]
+		return FanMetrics{RotorSpeeds: []int{r.speed}, Duty: r.duty}, nil
+	})
+	for ; next < len(readings); next++ {
+		got, err := fan.getStatus(context.Background())
+		if err != nil {
+			t.Fatalf("reading %d: %v", next, err)
+		}
+		if got != readings[next].want {
+			t.Errorf("reading %d (%d RPM, %d%% duty) is %s, want %s",
+				next, readings[next].speed, readings[next].duty, got, readings[next].want)
+		}
+	}
+}
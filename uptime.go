@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+const defaultUptimeWindow = 24 * time.Hour
+
+// UptimeStats accumulates how long a process has been up and down over the uptime window
+type UptimeStats struct {
+	UpSeconds   float64   `json:"up_seconds"`
+	DownSeconds float64   `json:"down_seconds"`
+	LastSample  time.Time `json:"last_sample"`
+	LastUp      bool      `json:"last_up"`
+	BelowTarget bool      `json:"below_target"`
+}
+
+// ratio returns the fraction of the tracked time the process was up
+func (s *UptimeStats) ratio() float64 {
+	total := s.UpSeconds + s.DownSeconds
+	if total == 0 {
+		if s.LastUp {
+			return 1
+		}
+		return 0
+	}
+	return s.UpSeconds / total
+}
+
+// updateUptime attributes the time since the last sample to the state observed at that
+// sample, persists the accumulators with the runtime state and returns the current uptime ratio.
+//
+// The window is approximated by scaling both accumulators down once their sum exceeds
+// it, so older samples decay rather than being dropped individually.
+func (pm *ProcessMonitor) updateUptime(ctx context.Context, proc Process, up bool) float64 {
+	stats := &pm.runtimeState(ctx, proc.Name).Uptime
+	window := pm.config.UptimeWindow.Duration
+
+	now := time.Now()
+	if !stats.LastSample.IsZero() {
+		elapsed := now.Sub(stats.LastSample)
+		// A gap longer than the window means hostd itself was down; the state is unknown
+		if elapsed > 0 && elapsed <= window {
+			if stats.LastUp {
+				stats.UpSeconds += elapsed.Seconds()
+			} else {
+				stats.DownSeconds += elapsed.Seconds()
+			}
+		}
+	}
+	stats.LastSample = now
+	stats.LastUp = up
+
+	if total := stats.UpSeconds + stats.DownSeconds; total > window.Seconds() {
+		scale := window.Seconds() / total
+		stats.UpSeconds *= scale
+		stats.DownSeconds *= scale
+	}
+
+	ratio := stats.ratio()
+	if target := pm.config.UptimeTarget; target > 0 {
+		below := ratio*100 < target
+		if below && !stats.BelowTarget {
+			pm.alert(proc, pm.logger.Critical, "Process %s uptime %.3f%% is below target %.3f%% over %v",
+				proc.Name, ratio*100, target, window)
+		} else if !below && stats.BelowTarget {
+			pm.logger.Info("Process %s uptime %.3f%% is back above target %.3f%%", proc.Name, ratio*100, target)
+		}
+		stats.BelowTarget = below
+	}
+
+	pm.saveRuntimeState(ctx, proc.Name)
+	if err := pm.redis.UpdateUptimeRatio(ctx, proc.Name, ratio); err != nil {
+		pm.logger.Error("Error updating uptime ratio in Redis for process %s: %v", proc.Name, err)
+	}
+
+	return ratio
+}
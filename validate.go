@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Validate checks the daemon config for semantic errors, returning one error that
+// lists every problem found
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, v ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, v...))
+	}
+
+	if sentinel := c.Redis.Sentinel; sentinel != nil {
+		if c.Redis.Host != "" {
+			add("redis.host and redis.sentinel are both set, use one or the other")
+		}
+		if sentinel.MasterName == "" {
+			add("redis.sentinel.masterName is empty")
+		}
+		if len(sentinel.SentinelAddrs) == 0 {
+			add("redis.sentinel.sentinelAddrs is empty")
+		}
+		for _, addr := range sentinel.SentinelAddrs {
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				add("redis.sentinel.sentinelAddrs %q is not host:port", addr)
+			}
+		}
+	} else {
+		if c.Redis.Host == "" {
+			add("redis.host is empty")
+		}
+		if c.Redis.Port <= 0 || c.Redis.Port > 65535 {
+			add("redis.port %d is not a valid port", c.Redis.Port)
+		}
+	}
+	if c.Redis.DB < 0 {
+		add("redis.db %d is negative", c.Redis.DB)
+	}
+	if c.Redis.ReadReplica != nil && (c.Redis.ReadReplica.Port <= 0 || c.Redis.ReadReplica.Port > 65535) {
+		add("redis.readReplica.port %d is not a valid port", c.Redis.ReadReplica.Port)
+	}
+	if c.Redis.EventStreamMaxLen < 0 {
+		add("redis.eventStreamMaxLen %d is negative", c.Redis.EventStreamMaxLen)
+	}
+	if c.Redis.HardwareHistoryLength < 0 {
+		add("redis.hardwareHistoryLength %d is negative", c.Redis.HardwareHistoryLength)
+	}
+	if c.Redis.OpTimeout.Duration < 0 {
+		add("redis.opTimeout %v is negative", c.Redis.OpTimeout.Duration)
+	}
+	if strings.ContainsAny(c.Redis.KeyPrefix, "*?[]\\") {
+		add("redis.keyPrefix %q contains a glob character", c.Redis.KeyPrefix)
+	}
+
+	if c.CheckInterval.Duration < 0 {
+		add("checkInterval %v is negative", c.CheckInterval.Duration)
+	}
+	if c.HardwareInterval.Duration < 0 {
+		add("hardwareInterval %v is negative", c.HardwareInterval.Duration)
+	}
+	if ttl := c.Redis.KeyTTL.Duration; ttl < 0 {
+		add("redis.keyTTL %v is negative", ttl)
+	} else if ttl > 0 {
+		// Keys rewritten less often than they expire would keep disappearing
+		checkInterval := c.CheckInterval.Duration
+		if checkInterval == 0 {
+			checkInterval = defaultCheckInterval
+		}
+		hardwareInterval := c.HardwareInterval.Duration
+		if hardwareInterval == 0 {
+			hardwareInterval = checkInterval
+		}
+		if ttl <= checkInterval || ttl <= hardwareInterval {
+			add("redis.keyTTL %v must be longer than checkInterval %v and hardwareInterval %v", ttl, checkInterval, hardwareInterval)
+		}
+	}
+	if c.Redis.CleanupInterval.Duration < 0 {
+		add("redis.cleanupInterval %v is negative", c.Redis.CleanupInterval.Duration)
+	}
+	if c.Redis.CleanupInterval.Duration > 0 && c.Redis.KeyPrefix == "" {
+		add("redis.cleanupInterval needs a redis.keyPrefix, or cleanup could delete the keys of other instances")
+	}
+	if c.Jitter.Fraction < 0 || c.Jitter.Fraction > 0.5 {
+		add("jitter.fraction %.2f is outside 0-0.5", c.Jitter.Fraction)
+	}
+	if c.Monitoring.UptimeTarget < 0 || c.Monitoring.UptimeTarget > 100 {
+		add("monitoring.uptimeTarget %.2f is outside 0-100", c.Monitoring.UptimeTarget)
+	}
+	if c.Monitoring.CheckConcurrency < 0 {
+		add("monitoring.checkConcurrency %d is negative", c.Monitoring.CheckConcurrency)
+	}
+	if c.Monitoring.FlapThreshold < 0 {
+		add("monitoring.flapThreshold %d is negative", c.Monitoring.FlapThreshold)
+	}
+	if c.Monitoring.MaxRestartsPerMinute < 0 {
+		add("monitoring.maxRestartsPerMinute %d is negative", c.Monitoring.MaxRestartsPerMinute)
+	}
+	if c.Monitoring.ProbeTimeout.Duration < 0 {
+		add("monitoring.probeTimeout %v is negative", c.Monitoring.ProbeTimeout.Duration)
+	}
+	if !validProbeBackend(c.Monitoring.ProbeBackend) {
+		add("monitoring.probeBackend %q is not auto, ps or proc", c.Monitoring.ProbeBackend)
+	}
+	if c.Monitoring.LeakSlopeBytesPerHour < 0 {
+		add("monitoring.leakSlopeBytesPerHour %.0f is negative", c.Monitoring.LeakSlopeBytesPerHour)
+	}
+	if c.Monitoring.LeakSamples == 1 || c.Monitoring.LeakSamples < 0 {
+		add("monitoring.leakSamples %d must be at least 2", c.Monitoring.LeakSamples)
+	}
+
+	if _, ok := levelNames[strings.ToLower(c.Logging.Level)]; c.Logging.Level != "" && !ok {
+		add("logging.level %q is not one of debug, info, error or critical", c.Logging.Level)
+	}
+	if c.Logging.Format != "" && c.Logging.Format != LogFormatText && c.Logging.Format != LogFormatJSON {
+		add("logging.format %q is not text or json", c.Logging.Format)
+	}
+	if c.Logging.DedupWindow.Duration < 0 {
+		add("logging.dedupWindow %v is negative", c.Logging.DedupWindow.Duration)
+	}
+	if c.Logging.File != nil && c.Logging.File.Path == "" {
+		add("logging.file.path is empty")
+	}
+
+	for _, endpoint := range []struct{ name, path string }{
+		{"http.healthPath", c.HTTP.HealthPath},
+		{"http.readyPath", c.HTTP.ReadyPath},
+	} {
+		name, path := endpoint.name, endpoint.path
+		switch path {
+		case "":
+		case "/", "/processes", "/processes/", "/hardware", "/hardware/", "/status":
+			add("%s %q clashes with a status API path", name, path)
+		default:
+			if !strings.HasPrefix(path, "/") {
+				add("%s %q must start with /", name, path)
+			}
+		}
+	}
+	if c.HTTP.HealthPath != "" && c.HTTP.HealthPath == c.HTTP.ReadyPath {
+		add("http.healthPath and http.readyPath are both %q", c.HTTP.HealthPath)
+	}
+
+	if c.Watchdog.Multiplier < 0 {
+		add("watchdog.multiplier %d is negative", c.Watchdog.Multiplier)
+	}
+	if c.Watchdog.Action != "" && c.Watchdog.Action != WatchdogActionLog && c.Watchdog.Action != WatchdogActionExit {
+		add("watchdog.action %q is not log or exit", c.Watchdog.Action)
+	}
+
+	for slot := range c.Hardware.Fans.Sysfs {
+		if slot < 0 || slot >= c.Hardware.Fans.Count {
+			add("hardware.fans.sysfs slot %d is outside 0-%d", slot, c.Hardware.Fans.Count-1)
+		}
+	}
+	if err := c.Hardware.validate(); err != nil {
+		add("hardware: %v", err)
+	}
+	if err := c.Disk.validate(); err != nil {
+		add("disk: %v", err)
+	}
+	if err := c.System.validate(); err != nil {
+		add("system: %v", err)
+	}
+	for i, webhook := range c.Webhooks {
+		if err := webhook.validate(); err != nil {
+			add("webhooks[%d]: %v", i, err)
+		}
+	}
+	if err := c.Kafka.validate(); err != nil {
+		add("kafka: %v", err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate checks the process config for semantic errors, returning one error that
+// lists every problem found
+func (c *ProcessConfig) Validate() error {
+	var errs []error
+	add := func(format string, v ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, v...))
+	}
+
+	seen := make(map[string]bool, len(c.Processes))
+	for i, proc := range c.Processes {
+		if proc.Name == "" {
+			add("process %d has no name", i)
+			continue
+		}
+		if seen[proc.Name] {
+			add("process %s is defined more than once", proc.Name)
+		}
+		seen[proc.Name] = true
+
+		if proc.MaxRetries < 0 {
+			add("process %s has negative maxRetries %d", proc.Name, proc.MaxRetries)
+		}
+		if proc.Managed && !proc.Restart {
+			add("process %s is managed but restart is not enabled", proc.Name)
+		}
+		if proc.Managed && proc.RestartOn != "" && proc.RestartOn != RestartOnAlways {
+			add("process %s is managed, so restartOn %q would be ignored", proc.Name, proc.RestartOn)
+		}
+		if proc.Restart && proc.Container != "" {
+			add("process %s runs in container %s, which hostd can't restart, use a Docker restart policy instead", proc.Name, proc.Container)
+		} else if proc.Restart && proc.Command == "" {
+			add("process %s has restart enabled but no command to start it", proc.Name)
+		}
+		switch proc.RestartOn {
+		case "", RestartOnAlways, RestartOnFailure, RestartOnNever:
+		default:
+			add("process %s has unknown restartOn %q", proc.Name, proc.RestartOn)
+		}
+		if proc.MatchMode == MatchModeCgroup && proc.Cgroup == "" {
+			add("process %s has matchMode cgroup but no cgroup", proc.Name)
+		}
+		if proc.Cgroup != "" && proc.MatchMode != "" && proc.MatchMode != MatchModeCgroup {
+			add("process %s has a cgroup, so matchMode %q would be ignored", proc.Name, proc.MatchMode)
+		}
+		if proc.Cgroup != "" && proc.Container != "" {
+			add("process %s has both a cgroup and a container, use one or the other", proc.Name)
+		}
+		if !validMatchMode(proc.MatchMode) {
+			add("process %s has unknown matchMode %q", proc.Name, proc.MatchMode)
+		}
+		if proc.MinThreads < 0 || proc.MaxThreads < 0 {
+			add("process %s has a negative thread limit", proc.Name)
+		} else if proc.MinThreads > 0 && proc.MaxThreads > 0 && proc.MinThreads > proc.MaxThreads {
+			add("process %s has minThreads %d above maxThreads %d", proc.Name, proc.MinThreads, proc.MaxThreads)
+		}
+		if proc.MemoryLimitBytes < 0 || proc.CPULimitPercent < 0 || proc.MaxOpenFDs < 0 {
+			add("process %s has a negative resource limit", proc.Name)
+		}
+		if proc.StartupGrace.Duration < 0 {
+			add("process %s has negative startupGrace %v", proc.Name, proc.StartupGrace.Duration)
+		}
+		if proc.RestartOnMemoryLimit && proc.MemoryLimitBytes == 0 {
+			add("process %s has restartOnMemoryLimit but no memoryLimitBytes", proc.Name)
+		}
+		for name := range proc.Labels {
+			if !validLabelName(name) {
+				add("process %s has invalid label name %q", proc.Name, name)
+			}
+		}
+		if proc.HealthCheck != nil {
+			if err := proc.HealthCheck.validate(); err != nil {
+				add("process %s: %v", proc.Name, err)
+			}
+		}
+	}
+
+	for _, proc := range c.Processes {
+		for _, dep := range proc.DependsOn {
+			switch {
+			case dep == proc.Name:
+				add("process %s depends on itself", proc.Name)
+			case !seen[dep]:
+				add("process %s depends on unknown process %s", proc.Name, dep)
+			}
+		}
+	}
+	if cycle := dependencyCycle(c.Processes); len(cycle) > 2 {
+		add("dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig returns a daemon config that passes Validate, for tests to break one setting of
+func validConfig() Config {
+	return Config{Redis: RedisConfig{Host: "localhost", Port: 6379}}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(c *Config)
+		wantErr string // substring of the error, empty for a valid config
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"sentinel with host", func(c *Config) {
+			c.Redis.Sentinel = &RedisSentinelConfig{MasterName: "mymaster", SentinelAddrs: []string{"s1:26379"}}
+		}, "redis.host and redis.sentinel are both set"},
+		{"sentinel without master", func(c *Config) {
+			c.Redis.Host = ""
+			c.Redis.Sentinel = &RedisSentinelConfig{SentinelAddrs: []string{"s1:26379"}}
+		}, "redis.sentinel.masterName is empty"},
+		{"sentinel without addresses", func(c *Config) {
+			c.Redis.Host = ""
+			c.Redis.Sentinel = &RedisSentinelConfig{MasterName: "mymaster"}
+		}, "redis.sentinel.sentinelAddrs is empty"},
+		{"sentinel address without port", func(c *Config) {
+			c.Redis.Host = ""
+			c.Redis.Sentinel = &RedisSentinelConfig{MasterName: "mymaster", SentinelAddrs: []string{"s1"}}
+		}, `redis.sentinel.sentinelAddrs "s1" is not host:port`},
+		{"no host", func(c *Config) { c.Redis.Host = "" }, "redis.host is empty"},
+		{"port zero", func(c *Config) { c.Redis.Port = 0 }, "redis.port 0 is not a valid port"},
+		{"port too high", func(c *Config) { c.Redis.Port = 65536 }, "redis.port 65536 is not a valid port"},
+		{"negative db", func(c *Config) { c.Redis.DB = -1 }, "redis.db -1 is negative"},
+		{"bad replica port", func(c *Config) { c.Redis.ReadReplica = &RedisReplicaConfig{Host: "replica"} }, "redis.readReplica.port 0"},
+		{"negative stream length", func(c *Config) { c.Redis.EventStreamMaxLen = -1 }, "redis.eventStreamMaxLen -1 is negative"},
+		{"negative history", func(c *Config) { c.Redis.HardwareHistoryLength = -1 }, "redis.hardwareHistoryLength -1 is negative"},
+		{"negative op timeout", func(c *Config) { c.Redis.OpTimeout.Duration = -time.Second }, "redis.opTimeout -1s is negative"},
+		{"glob in key prefix", func(c *Config) { c.Redis.KeyPrefix = "web*" }, `redis.keyPrefix "web*" contains a glob character`},
+		{"negative check interval", func(c *Config) { c.CheckInterval.Duration = -time.Second }, "checkInterval -1s is negative"},
+		{"negative hardware interval", func(c *Config) { c.HardwareInterval.Duration = -time.Second }, "hardwareInterval -1s is negative"},
+		{"negative key TTL", func(c *Config) { c.Redis.KeyTTL.Duration = -time.Second }, "redis.keyTTL -1s is negative"},
+		{"key TTL within check interval", func(c *Config) {
+			c.Redis.KeyTTL.Duration = 30 * time.Second
+			c.CheckInterval.Duration = time.Minute
+		}, "redis.keyTTL 30s must be longer than checkInterval"},
+		{"key TTL within hardware interval", func(c *Config) {
+			c.Redis.KeyTTL.Duration = 2 * time.Minute
+			c.CheckInterval.Duration = time.Minute
+			c.HardwareInterval.Duration = 5 * time.Minute
+		}, "redis.keyTTL 2m0s must be longer than checkInterval 1m0s and hardwareInterval 5m0s"},
+		{"negative cleanup interval", func(c *Config) { c.Redis.CleanupInterval.Duration = -time.Second }, "redis.cleanupInterval -1s is negative"},
+		{"cleanup without key prefix", func(c *Config) { c.Redis.CleanupInterval.Duration = time.Hour }, "redis.cleanupInterval needs a redis.keyPrefix"},
+		{"cleanup with key prefix", func(c *Config) {
+			c.Redis.CleanupInterval.Duration = time.Hour
+			c.Redis.KeyPrefix = "node1"
+		}, ""},
+		{"jitter above half", func(c *Config) { c.Jitter.Fraction = 0.6 }, "jitter.fraction 0.60 is outside 0-0.5"},
+		{"negative jitter", func(c *Config) { c.Jitter.Fraction = -0.1 }, "jitter.fraction -0.10 is outside 0-0.5"},
+		{"uptime target above 100", func(c *Config) { c.Monitoring.UptimeTarget = 101 }, "monitoring.uptimeTarget 101.00 is outside 0-100"},
+		{"negative concurrency", func(c *Config) { c.Monitoring.CheckConcurrency = -1 }, "monitoring.checkConcurrency -1 is negative"},
+		{"negative flap threshold", func(c *Config) { c.Monitoring.FlapThreshold = -1 }, "monitoring.flapThreshold -1 is negative"},
+		{"negative restart rate", func(c *Config) { c.Monitoring.MaxRestartsPerMinute = -1 }, "monitoring.maxRestartsPerMinute -1 is negative"},
+		{"negative probe timeout", func(c *Config) { c.Monitoring.ProbeTimeout.Duration = -time.Second }, "monitoring.probeTimeout -1s is negative"},
+		{"unknown probe backend", func(c *Config) { c.Monitoring.ProbeBackend = "wmi" }, `monitoring.probeBackend "wmi" is not auto, ps or proc`},
+		{"negative leak slope", func(c *Config) { c.Monitoring.LeakSlopeBytesPerHour = -1 }, "monitoring.leakSlopeBytesPerHour -1 is negative"},
+		{"one leak sample", func(c *Config) { c.Monitoring.LeakSamples = 1 }, "monitoring.leakSamples 1 must be at least 2"},
+		{"unknown log level", func(c *Config) { c.Logging.Level = "verbose" }, `logging.level "verbose" is not one of`},
+		{"unknown log format", func(c *Config) { c.Logging.Format = "xml" }, `logging.format "xml" is not text or json`},
+		{"negative dedup window", func(c *Config) { c.Logging.DedupWindow.Duration = -time.Second }, "logging.dedupWindow -1s is negative"},
+		{"log file without path", func(c *Config) { c.Logging.File = &LogFileConfig{} }, "logging.file.path is empty"},
+		{"health path clashes", func(c *Config) { c.HTTP.HealthPath = "/status" }, `http.healthPath "/status" clashes with a status API path`},
+		{"relative ready path", func(c *Config) { c.HTTP.ReadyPath = "ready" }, `http.readyPath "ready" must start with /`},
+		{"same health and ready path", func(c *Config) {
+			c.HTTP.HealthPath = "/probe"
+			c.HTTP.ReadyPath = "/probe"
+		}, `http.healthPath and http.readyPath are both "/probe"`},
+		{"negative watchdog multiplier", func(c *Config) { c.Watchdog.Multiplier = -1 }, "watchdog.multiplier -1 is negative"},
+		{"unknown watchdog action", func(c *Config) { c.Watchdog.Action = "reboot" }, `watchdog.action "reboot" is not log or exit`},
+		{"sysfs slot outside fans", func(c *Config) {
+			c.Hardware.Fans.Count = 2
+			c.Hardware.Fans.Sysfs = map[int]FanSysfsConfig{2: {Hwmon: "hwmon0"}}
+		}, "hardware.fans.sysfs slot 2 is outside 0-1"},
+		{"hardware", func(c *Config) { c.Hardware.Fans.Source = "ipmi" }, "hardware: "},
+		{"disk", func(c *Config) { c.Disk.Mounts = []string{"data"} }, `disk: mount "data" is not an absolute path`},
+		{"system", func(c *Config) { c.System.LoadWarn = -1 }, "system: loadWarn -1.00 is negative"},
+		{"webhook", func(c *Config) { c.Webhooks = []WebhookConfig{{URL: "ftp://hooks"}} }, `webhooks[0]: url "ftp://hooks" is not an http or https URL`},
+		{"kafka", func(c *Config) { c.Kafka = KafkaConfig{RestProxy: "http://kafka-rest:8082"} }, `kafka: topic "" is not a valid topic name`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig()
+			tt.mutate(&config)
+			err := config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate error %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigValidateListsEveryError(t *testing.T) {
+	config := Config{Redis: RedisConfig{DB: -1}, Jitter: JitterConfig{Fraction: 1}}
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted an invalid config")
+	}
+	for _, want := range []string{"redis.host is empty", "redis.port 0", "redis.db -1", "jitter.fraction"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate error %q is missing %q", err, want)
+		}
+	}
+}
+
+func TestProcessConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		procs   []Process
+		wantErr string
+	}{
+		{"valid", []Process{{Name: "api", Restart: true, Command: "/usr/bin/api"}, {Name: "nginx"}}, ""},
+		{"no name", []Process{{}}, "process 0 has no name"},
+		{"duplicate", []Process{{Name: "api"}, {Name: "api"}}, "process api is defined more than once"},
+		{"negative retries", []Process{{Name: "api", MaxRetries: -1}}, "process api has negative maxRetries -1"},
+		{"managed without restart", []Process{{Name: "api", Managed: true, Command: "/usr/bin/api"}}, "process api is managed but restart is not enabled"},
+		{"managed with restartOn", []Process{{Name: "api", Managed: true, Restart: true, Command: "/usr/bin/api", RestartOn: RestartOnFailure}},
+			`process api is managed, so restartOn "on-failure" would be ignored`},
+		{"restart without command", []Process{{Name: "api", Restart: true}}, "process api has restart enabled but no command to start it"},
+		{"restart of container", []Process{{Name: "api", Restart: true, Container: "api-server"}},
+			"process api runs in container api-server, which hostd can't restart, use a Docker restart policy instead"},
+		{"unknown restartOn", []Process{{Name: "api", RestartOn: "sometimes"}}, `process api has unknown restartOn "sometimes"`},
+		{"cgroup mode without cgroup", []Process{{Name: "api", MatchMode: MatchModeCgroup}}, "process api has matchMode cgroup but no cgroup"},
+		{"cgroup with other mode", []Process{{Name: "api", Cgroup: "/system.slice/api.service", MatchMode: MatchModeExact}},
+			`process api has a cgroup, so matchMode "exact" would be ignored`},
+		{"cgroup and container", []Process{{Name: "api", Cgroup: "/system.slice/api.service", Container: "api"}},
+			"process api has both a cgroup and a container"},
+		{"unknown match mode", []Process{{Name: "api", MatchMode: "fuzzy"}}, `process api has unknown matchMode "fuzzy"`},
+		{"negative thread limit", []Process{{Name: "api", MinThreads: -1}}, "process api has a negative thread limit"},
+		{"thread limits reversed", []Process{{Name: "api", MinThreads: 10, MaxThreads: 5}}, "process api has minThreads 10 above maxThreads 5"},
+		{"negative resource limit", []Process{{Name: "api", MaxOpenFDs: -1}}, "process api has a negative resource limit"},
+		{"negative startup grace", []Process{{Name: "api", StartupGrace: Duration{-time.Second}}}, "process api has negative startupGrace -1s"},
+		{"memory restart without limit", []Process{{Name: "api", RestartOnMemoryLimit: true}}, "process api has restartOnMemoryLimit but no memoryLimitBytes"},
+		{"invalid label", []Process{{Name: "api", Labels: map[string]string{"1team": "x"}}}, `process api has invalid label name "1team"`},
+		{"health check", []Process{{Name: "api", HealthCheck: &HealthCheck{}}}, "process api: health check needs exactly one of command, http or tcp"},
+		{"depends on itself", []Process{{Name: "api", DependsOn: []string{"api"}}}, "process api depends on itself"},
+		{"unknown dependency", []Process{{Name: "api", DependsOn: []string{"db"}}}, "process api depends on unknown process db"},
+		{"dependency cycle", []Process{{Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"a"}}}, "dependency cycle: "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := ProcessConfig{Processes: tt.procs}
+			err := config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate error %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
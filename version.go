@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Build information, set at build time with
+//
+//	go build -ldflags "-X main.version=1.2.3 -X main.commit=$(git rev-parse --short HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// versionString describes the running build
+func versionString() string {
+	return fmt.Sprintf("hostd %s (commit %s, built %s, %s)", version, commit, buildDate, runtime.Version())
+}
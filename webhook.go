@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	defaultWebhookTimeout = 5 * time.Second
+	defaultWebhookRetries = 3
+	webhookQueueLength    = 100             // events waiting per webhook before new ones are dropped
+	webhookRetryBaseDelay = 1 * time.Second // first delay between delivery attempts, doubled on every retry
+)
+
+// Webhook event types
+const (
+	WebhookEventProcessTransition = "process_transition" // a process changed status
+	WebhookEventProcessRestart    = "process_restart"    // hostd is restarting a crashed or unhealthy process
+	WebhookEventFruRed            = "fru_red"            // a FRU or other chassis component turned red
+	WebhookEventFruRecovered      = "fru_recovered"      // a red FRU is no longer red
+)
+
+// webhookEvents lists the valid event types
+var webhookEvents = map[string]bool{
+	WebhookEventProcessTransition: true,
+	WebhookEventProcessRestart:    true,
+	WebhookEventFruRed:            true,
+	WebhookEventFruRecovered:      true,
+}
+
+// WebhookConfig is an HTTP endpoint notified of events, such as a PagerDuty or Slack bridge
+type WebhookConfig struct {
+	URL     string   `json:"url"`
+	Events  []string `json:"events,omitempty"` // event types to send, all if empty
+	Timeout Duration `json:"timeout"`          // limit on each delivery attempt, default 5s
+	Retries int      `json:"retries"`          // further attempts after a failed delivery, default 3, negative for none
+}
+
+// validate checks the URL and event filter
+func (c WebhookConfig) validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url %q is not an http or https URL", c.URL)
+	}
+	for _, event := range c.Events {
+		if !webhookEvents[event] {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	if c.Timeout.Duration < 0 {
+		return fmt.Errorf("timeout %v is negative", c.Timeout.Duration)
+	}
+	return nil
+}
+
+// WebhookEvent is the JSON payload posted to webhooks
+type WebhookEvent struct {
+	Event     string `json:"event"`
+	Host      string `json:"host"`
+	Process   string `json:"process,omitempty"`
+	FRU       string `json:"fru,omitempty"`
+	OldStatus string `json:"old_status,omitempty"`
+	NewStatus string `json:"new_status,omitempty"`
+	Synthetic bool   `json:"synthetic,omitempty"` // injected to test alerting, not a real change
+	Message   string `json:"message"`
+	Timestamp string `json:"timestamp"`
+}
+
+// webhook delivers the events one endpoint subscribed to, in order, from its own queue
+type webhook struct {
+	config WebhookConfig
+	events map[string]bool // nil for all
+	queue  chan WebhookEvent
+	client *http.Client
+}
+
+// Notifier posts events to the configured webhooks, and optionally Kafka, without
+// blocking the caller. Each webhook has its own queue and goroutine, so a slow endpoint
+// only delays itself. A nil notifier ignores all events.
+type Notifier struct {
+	webhooks []*webhook
+	kafka    *kafkaProducer // nil when Kafka is not configured
+	host     string
+	logger   *Logger
+}
+
+// NewNotifier creates a notifier for the given webhooks and Kafka sink; nil if there are none
+func NewNotifier(configs []WebhookConfig, kafka KafkaConfig, metrics *MetricsExporter, logger *Logger) *Notifier {
+	producer := newKafkaProducer(kafka, metrics, logger)
+	if len(configs) == 0 && producer == nil {
+		return nil
+	}
+
+	host, _ := os.Hostname()
+	n := &Notifier{kafka: producer, host: host, logger: logger}
+	for _, config := range configs {
+		if config.Timeout.Duration == 0 {
+			config.Timeout.Duration = defaultWebhookTimeout
+		}
+		if config.Retries == 0 {
+			config.Retries = defaultWebhookRetries
+		} else if config.Retries < 0 {
+			config.Retries = 0
+		}
+
+		w := &webhook{
+			config: config,
+			queue:  make(chan WebhookEvent, webhookQueueLength),
+			client: &http.Client{Timeout: config.Timeout.Duration},
+		}
+		if len(config.Events) > 0 {
+			w.events = make(map[string]bool, len(config.Events))
+			for _, event := range config.Events {
+				w.events[event] = true
+			}
+		}
+		n.webhooks = append(n.webhooks, w)
+	}
+	if len(n.webhooks) > 0 {
+		logger.Info("Notifying %d webhooks of events", len(n.webhooks))
+	}
+	return n
+}
+
+// Start delivers queued events until ctx is cancelled; events still queued then are dropped
+func (n *Notifier) Start(ctx context.Context) {
+	if n == nil {
+		return
+	}
+	for _, w := range n.webhooks {
+		go n.deliverLoop(ctx, w)
+	}
+	if n.kafka != nil {
+		go n.kafka.deliverLoop(ctx)
+	}
+}
+
+// Notify queues an event for every webhook subscribed to its type. If a queue is full
+// the event is dropped for that webhook and logged.
+func (n *Notifier) Notify(event WebhookEvent) {
+	if n == nil {
+		return
+	}
+
+	event.Host = n.host
+	if event.Timestamp == "" {
+		event.Timestamp = time.Now().Format(time.RFC3339Nano)
+	}
+	for _, w := range n.webhooks {
+		if w.events != nil && !w.events[event.Event] {
+			continue
+		}
+		select {
+		case w.queue <- event:
+		default:
+			n.logger.Error("Webhook %s queue is full, dropping %s event", w.config.URL, event.Event)
+		}
+	}
+}
+
+// PublishEvent queues an event stream entry of the given type for Kafka, if configured
+func (n *Notifier) PublishEvent(event string, values map[string]interface{}) {
+	if n == nil {
+		return
+	}
+	n.kafka.publish(n.host, event, values)
+}
+
+// deliverLoop sends the events queued for a webhook, retrying each failed delivery with
+// exponential backoff up to the configured number of times
+func (n *Notifier) deliverLoop(ctx context.Context, w *webhook) {
+	for {
+		var event WebhookEvent
+		select {
+		case <-ctx.Done():
+			return
+		case event = <-w.queue:
+		}
+
+		body, err := json.Marshal(event)
+		if err != nil {
+			n.logger.Error("Error marshaling %s webhook event: %v", event.Event, err)
+			continue
+		}
+
+		delay := webhookRetryBaseDelay
+		for attempt := 0; ; attempt++ {
+			err = w.post(ctx, body)
+			if err == nil || ctx.Err() != nil {
+				break
+			}
+			if attempt == w.config.Retries {
+				n.logger.Error("Giving up delivering %s event to webhook %s after %d attempts: %v",
+					event.Event, w.config.URL, attempt+1, err)
+				break
+			}
+			n.logger.Debug("Delivering %s event to webhook %s failed, retrying in %v: %v", event.Event, w.config.URL, delay, err)
+			if !sleepContext(ctx, delay) {
+				return
+			}
+			delay *= 2
+		}
+	}
+}
+
+// post sends one JSON payload, treating any non-2xx response as a failure
+func (w *webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return nil
+}